@@ -0,0 +1,201 @@
+// Package cidr normalizes addresses, prefixes and ranges of blocklists into the minimal list of
+// non-overlapping prefixes, ready to be added to hash:net sets.
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// Parse parses addresses (ip), prefixes (ip/cidr, host bits are cleared) and ranges (fromip-toip) of IPv4 and IPv6,
+// and returns them as prefixes; ranges are converted to the minimal list of prefixes that cover them.
+// Returned prefixes are not aggregated.
+func Parse(in ...string) ([]netip.Prefix, error) {
+	out := []netip.Prefix{}
+	for _, value := range in {
+		value = strings.TrimSpace(value)
+
+		var err error
+		if from, to, found := strings.Cut(value, "-"); found {
+			var fromAddr, toAddr netip.Addr
+			var prefixes []netip.Prefix
+			if fromAddr, err = netip.ParseAddr(strings.TrimSpace(from)); err == nil {
+				if toAddr, err = netip.ParseAddr(strings.TrimSpace(to)); err == nil {
+					prefixes, err = Range(fromAddr, toAddr)
+				}
+			}
+			out = append(out, prefixes...)
+		} else if strings.Contains(value, "/") {
+			var prefix netip.Prefix
+			if prefix, err = netip.ParsePrefix(value); err == nil {
+				out = append(out, prefix.Masked())
+			}
+		} else {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(value); err == nil {
+				out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid address, prefix or range %s: %w", value, err)
+		}
+	}
+	return out, nil
+}
+
+// Range returns the minimal list of prefixes that cover all addresses from from to to, included.
+func Range(from, to netip.Addr) ([]netip.Prefix, error) {
+	if from.Is4() != to.Is4() {
+		return nil, fmt.Errorf("addresses %v and %v have different families", from, to)
+	} else if from.Zone() != "" || to.Zone() != "" {
+		return nil, fmt.Errorf("addresses with zones are not supported")
+	} else if to.Less(from) {
+		return nil, fmt.Errorf("address %v is greater than %v", from, to)
+	}
+
+	r := addrRange{start: newUint128(from), end: newUint128(to)}
+	return r.prefixes(from.BitLen(), nil), nil
+}
+
+// Aggregate returns the minimal list of non-overlapping prefixes that cover the same addresses of prefixes:
+// overlapping prefixes are merged and adjacent ones are combined. Prefixes are sorted, IPv4 before IPv6;
+// invalid prefixes are skipped.
+func Aggregate(prefixes []netip.Prefix) []netip.Prefix {
+	return Subtract(prefixes, nil)
+}
+
+// Subtract returns the minimal list of non-overlapping prefixes that cover the addresses of prefixes
+// that are not covered by exceptions, sorted as returned by Aggregate.
+func Subtract(prefixes, exceptions []netip.Prefix) []netip.Prefix {
+	out := []netip.Prefix{}
+	for _, bitLen := range []int{32, 128} {
+		ranges := mergeRanges(prefixes, bitLen)
+		if len(exceptions) > 0 {
+			ranges = subtractRanges(ranges, mergeRanges(exceptions, bitLen))
+		}
+
+		for _, r := range ranges {
+			out = r.prefixes(bitLen, out)
+		}
+	}
+	return out
+}
+
+// Entries returns prefixes as entries of hash:net sets, to be added with commands.NewAddTypedEntry.
+// hash:net sets do not accept prefixes of length 0, which are split in two halves.
+func Entries(prefixes []netip.Prefix) []set.HashNetEntry {
+	out := make([]set.HashNetEntry, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if bitLen := prefix.Addr().BitLen(); prefix.Bits() == 0 {
+			out = append(out,
+				set.NewHashNetEntry(netip.PrefixFrom(prefix.Masked().Addr(), 1)),
+				set.NewHashNetEntry(netip.PrefixFrom(lowMask(bitLen-1).addOne().addr(bitLen), 1)),
+			)
+		} else {
+			out = append(out, set.NewHashNetEntry(prefix))
+		}
+	}
+	return out
+}
+
+// Strings returns prefixes formatted as entries of hash:net sets, as listed by ipset (ex.: 1.1.1.1 for 1.1.1.1/32).
+func Strings(prefixes []netip.Prefix) []string {
+	entries := Entries(prefixes)
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.String()
+	}
+	return out
+}
+
+// Support.
+
+// addrRange is a range of addresses of the same family, from start to end included.
+type addrRange struct {
+	start, end uint128
+}
+
+// prefixes appends to out the minimal list of prefixes that cover r, for addresses of bitLen bits.
+func (r addrRange) prefixes(bitLen int, out []netip.Prefix) []netip.Prefix {
+	start := r.start
+	for {
+		// Largest block aligned to start that does not exceed end.
+		size := start.trailingZeros()
+		if size > bitLen {
+			size = bitLen
+		}
+		for size > 0 && start.or(lowMask(size)).cmp(r.end) > 0 {
+			size--
+		}
+
+		out = append(out, netip.PrefixFrom(start.addr(bitLen), bitLen-size))
+		last := start.or(lowMask(size))
+		if last.cmp(r.end) >= 0 {
+			return out
+		}
+		start = last.addOne()
+	}
+}
+
+// mergeRanges returns the sorted and merged ranges of valid prefixes of addresses of bitLen bits.
+func mergeRanges(prefixes []netip.Prefix, bitLen int) []addrRange {
+	ranges := []addrRange{}
+	for _, prefix := range prefixes {
+		if !prefix.IsValid() || prefix.Addr().BitLen() != bitLen {
+			continue
+		}
+
+		start := newUint128(prefix.Masked().Addr())
+		ranges = append(ranges, addrRange{start: start, end: start.or(lowMask(bitLen - prefix.Bits()))})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.cmp(ranges[j].start) < 0 })
+
+	out := []addrRange{}
+	maxAddr := lowMask(bitLen)
+	for _, r := range ranges {
+		if n := len(out); n > 0 && (out[n-1].end == maxAddr || r.start.cmp(out[n-1].end.addOne()) <= 0) {
+			if r.end.cmp(out[n-1].end) > 0 {
+				out[n-1].end = r.end
+			}
+		} else {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// subtractRanges returns the parts of sorted and merged ranges not covered by sorted and merged exceptions.
+func subtractRanges(ranges, exceptions []addrRange) []addrRange {
+	out := []addrRange{}
+	first := 0 // First exception that may overlap the current range.
+	for _, r := range ranges {
+		for first < len(exceptions) && exceptions[first].end.cmp(r.start) < 0 {
+			first++
+		}
+
+		start, covered := r.start, false
+		for _, e := range exceptions[first:] {
+			if e.start.cmp(r.end) > 0 {
+				break
+			} else if e.start.cmp(start) > 0 {
+				out = append(out, addrRange{start: start, end: e.start.subOne()})
+			}
+
+			if e.end.cmp(r.end) >= 0 {
+				covered = true
+				break
+			}
+			start = e.end.addOne()
+		}
+
+		if !covered {
+			out = append(out, addrRange{start: start, end: r.end})
+		}
+	}
+	return out
+}
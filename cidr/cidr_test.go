@@ -0,0 +1,108 @@
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":                  `["1.1.1.1/32"]`,
+		"10.1.2.3/8":               `["10.0.0.0/8"]`,
+		"2001:db8::1/32":           `["2001:db8::/32"]`,
+		"10.0.0.1-10.0.0.6":        `["10.0.0.1/32" "10.0.0.2/31" "10.0.0.4/31" "10.0.0.6/32"]`,
+		" 0.0.0.0-255.255.255.255": `["0.0.0.0/0"]`,
+		"2001:db8::-2001:db8::ff":  `["2001:db8::/120"]`,
+		"::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": `["::/0"]`,
+		"10.0.0.6-10.0.0.1":                          "error",
+		"10.0.0.1-2001:db8::1":                       "error",
+		"10.0.0.0/33":                                "error",
+		"1.1.0":                                      "error",
+	}
+
+	for in, expects := range tests {
+		prefixes, err := Parse(in)
+		if err != nil {
+			if expects != "error" {
+				t.Errorf("expectation failed for %s: %v", in, err)
+			}
+		} else if result := fmt.Sprintf("%q", prefixes); result != expects {
+			t.Errorf("expectation failed for %s: %s != %s (expected)", in, result, expects)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	type test struct {
+		prefixes   []string
+		exceptions []string
+		expects    string
+	}
+
+	tests := []test{
+		{
+			prefixes: []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.128/25", "10.0.2.5", "2001:db8::/33", "2001:db8:8000::/33", "192.168.0.0-192.168.0.255"},
+			expects:  `["10.0.0.0/23" "10.0.2.5" "192.168.0.0/24" "2001:db8::/32"]`,
+		},
+		{
+			prefixes: []string{"0.0.0.0/1", "128.0.0.0/1", "1.1.1.1"},
+			expects:  `["0.0.0.0/1" "128.0.0.0/1"]`,
+		},
+		{
+			prefixes: []string{"::/0"},
+			expects:  `["::/1" "8000::/1"]`,
+		},
+		{
+			prefixes:   []string{"10.0.0.0/8", "192.168.0.0/16"},
+			exceptions: []string{"10.0.0.0/9", "10.192.0.0/10", "10.128.0.1", "192.168.0.0/16", "2001:db8::/32"},
+			expects:    `["10.128.0.0" "10.128.0.2/31" "10.128.0.4/30" "10.128.0.8/29" "10.128.0.16/28" "10.128.0.32/27" "10.128.0.64/26" "10.128.0.128/25" "10.128.1.0/24" "10.128.2.0/23" "10.128.4.0/22" "10.128.8.0/21" "10.128.16.0/20" "10.128.32.0/19" "10.128.64.0/18" "10.128.128.0/17" "10.129.0.0/16" "10.130.0.0/15" "10.132.0.0/14" "10.136.0.0/13" "10.144.0.0/12" "10.160.0.0/11"]`,
+		},
+		{
+			prefixes:   []string{"10.0.0.0/30"},
+			exceptions: []string{"0.0.0.0/0"},
+			expects:    `[]`,
+		},
+	}
+
+	for i, test := range tests {
+		prefixes, err := Parse(test.prefixes...)
+		if err != nil {
+			t.Fatalf("expectation %d failed: %v", i+1, err)
+		}
+		exceptions, err := Parse(test.exceptions...)
+		if err != nil {
+			t.Fatalf("expectation %d failed: %v", i+1, err)
+		}
+
+		if result := fmt.Sprintf("%q", Strings(Subtract(prefixes, exceptions))); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		}
+	}
+}
+
+func TestRangeCoversAddresses(t *testing.T) {
+	from := netip.MustParseAddr("10.0.0.3")
+	for to := from; to.Compare(netip.MustParseAddr("10.0.1.10")) <= 0; to = to.Next() {
+		prefixes, err := Range(from, to)
+		if err != nil {
+			t.Fatalf("expectation failed for %v-%v: %v", from, to, err)
+		}
+
+		next := from
+		for _, prefix := range prefixes {
+			if prefix.Addr() != next || prefix != prefix.Masked() {
+				t.Fatalf("expectation failed for %v-%v: unexpected prefix %v", from, to, prefix)
+			}
+			next = lastAddr(prefix).Next()
+		}
+		if next != to.Next() {
+			t.Fatalf("expectation failed for %v-%v: prefixes %v end before %v", from, to, prefixes, to)
+		}
+	}
+}
+
+// lastAddr returns the last address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	return newUint128(prefix.Addr()).or(lowMask(prefix.Addr().BitLen() - prefix.Bits())).addr(prefix.Addr().BitLen())
+}
@@ -0,0 +1,88 @@
+package cidr
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+)
+
+// uint128 is an unsigned integer of 128 bits, used to compute ranges of IPv4 (in the lower 32 bits) and IPv6 addresses.
+type uint128 struct {
+	hi, lo uint64
+}
+
+// newUint128 returns the integer value of addr.
+func newUint128(addr netip.Addr) uint128 {
+	if addr.Is4() {
+		b := addr.As4()
+		return uint128{lo: uint64(binary.BigEndian.Uint32(b[:]))}
+	}
+
+	b := addr.As16()
+	return uint128{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+// lowMask returns the integer with the lower n bits set.
+func lowMask(n int) uint128 {
+	switch {
+	case n <= 0:
+		return uint128{}
+	case n < 64:
+		return uint128{lo: 1<<n - 1}
+	case n < 128:
+		return uint128{hi: 1<<(n-64) - 1, lo: ^uint64(0)}
+	default:
+		return uint128{hi: ^uint64(0), lo: ^uint64(0)}
+	}
+}
+
+// addr returns u as an address of bitLen bits.
+func (u uint128) addr(bitLen int) netip.Addr {
+	if bitLen == 32 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(u.lo))
+		return netip.AddrFrom4(b)
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	return netip.AddrFrom16(b)
+}
+
+// cmp returns -1, 0 or 1 if u is lower than, equal to or greater than v.
+func (u uint128) cmp(v uint128) int {
+	switch {
+	case u.hi < v.hi || u.hi == v.hi && u.lo < v.lo:
+		return -1
+	case u == v:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// or returns the bitwise or of u and v.
+func (u uint128) or(v uint128) uint128 {
+	return uint128{hi: u.hi | v.hi, lo: u.lo | v.lo}
+}
+
+// addOne returns u+1, wrapping around at the maximum value.
+func (u uint128) addOne() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{hi: u.hi + carry, lo: lo}
+}
+
+// subOne returns u-1, wrapping around at 0.
+func (u uint128) subOne() uint128 {
+	lo, borrow := bits.Sub64(u.lo, 1, 0)
+	return uint128{hi: u.hi - borrow, lo: lo}
+}
+
+// trailingZeros returns the number of trailing zero bits of u, 128 if u is 0.
+func (u uint128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	return 64 + bits.TrailingZeros64(u.hi)
+}
@@ -0,0 +1,89 @@
+// Command goipset-server serves the HTTP API of package rest, and metrics of sets exported by package metrics.
+//
+// Usage:
+//
+//	goipset-server [options]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/metrics"
+	"github.com/francescocolleoni/go-ipset/netlink"
+	"github.com/francescocolleoni/go-ipset/rest"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// shutdownTimeout is the maximum time given to pending requests when the server is stopped.
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "address to listen on")
+	prefix := flag.String("prefix", "", "path prefix of the API (ex.: /ipset)")
+	exist := flag.Bool("exist", false, "ignore errors when entries already exist or are missing")
+	ipsetPath := flag.String("ipset", "", "path of the ipset executable")
+	useNetlink := flag.Bool("netlink", false, "talk to the kernel through netlink instead of running ipset")
+	metricsPath := flag.String("metrics", "/metrics", "path of Prometheus metrics, empty to disable them")
+	metricsFilter := flag.String("metrics-filter", "", "regular expression matching names of sets exported as metrics")
+	metricsMaxEntries := flag.Int("metrics-max-entries", metrics.DefaultMaxEntrySeries, "maximum number of entries of each set exported with per-entry metrics")
+	flag.Parse()
+
+	var executor utilities.Executor
+	if *useNetlink {
+		executor = netlink.NewExecutor()
+	} else if *ipsetPath != "" {
+		executor = utilities.NewBinaryExecutor(*ipsetPath)
+	} else {
+		executor = utilities.DefaultExecutor
+	}
+
+	client := commands.NewClient(executor)
+	client.Exist = *exist
+
+	mux := http.NewServeMux()
+	if *prefix != "" {
+		mux.Handle(*prefix+"/", http.StripPrefix(*prefix, rest.NewHandler(client)))
+	} else {
+		mux.Handle("/", rest.NewHandler(client))
+	}
+	if *metricsPath != "" {
+		exporter := metrics.NewExporter(executor)
+		exporter.MaxEntrySeries = *metricsMaxEntries
+		if *metricsFilter != "" {
+			filter, err := regexp.Compile(*metricsFilter)
+			if err != nil {
+				log.Fatalf("goipset-server: invalid metrics filter: %v", err)
+			}
+			exporter.Filter = filter
+		}
+		mux.Handle(*metricsPath, exporter)
+	}
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("goipset-server: listening on %s", *listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("goipset-server: %v", err)
+	}
+	<-done // Pending requests are completed.
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// cli runs goipset commands with a given client, printing results in a given output format.
+type cli struct {
+	ctx    context.Context
+	client *commands.Client
+	output string // outputTable or outputJSON.
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// commands returns the commands of goipset by name.
+func (c *cli) commands() map[string]func(args []string) error {
+	return map[string]func(args []string) error{
+		"create":  c.create,
+		"add":     func(args []string) error { return c.addDelete(commands.CommandNameAdd, args) },
+		"del":     func(args []string) error { return c.addDelete(commands.CommandNameDelete, args) },
+		"test":    c.test,
+		"list":    c.list,
+		"flush":   c.flush,
+		"destroy": c.destroy,
+	}
+}
+
+// create runs command create.
+func (c *cli) create(args []string) error {
+	flags := c.newFlagSet("create", "[options] <set> <type>")
+	family := flags.String("family", "", "protocol family, inet or inet6 (hash sets)")
+	ipRange := flags.String("range", "", "range of bitmap sets, as fromip-toip, ip/cidr or fromport-toport")
+	netMask := flags.Int("netmask", 0, "netmask (bitmap:ip and hash:ip sets)")
+	markMask := flags.Int("markmask", 0, "markmask (hash:ip,mark sets)")
+	hashSize := flags.Int("hashsize", 0, "initial hash size (hash sets)")
+	maxElements := flags.Int("maxelem", 0, "maximum number of elements (hash sets)")
+	size := flags.Int("size", 0, "size (list:set sets)")
+	timeout := flags.Int("timeout", 0, "default timeout of entries, in seconds")
+	counters := flags.Bool("counters", false, "enable packet and byte counters")
+	comment := flags.Bool("comment", false, "enable entry comments")
+	skbInfo := flags.Bool("skbinfo", false, "enable skbinfo extension")
+	forceAdd := flags.Bool("forceadd", false, "overwrite random entries when the set is full (hash sets)")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 2 {
+		return usageError(flags, "create requires a set name and a set type")
+	}
+
+	setType := set.SetTypeWithString(positional[1])
+	options := []commands.CreateOption{}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "family":
+			if *family == "inet6" {
+				options = append(options, commands.WithFamily(commands.ProtocolFamilyINet6))
+			} else {
+				options = append(options, commands.WithFamily(commands.ProtocolFamilyINet))
+			}
+		case "range":
+			if setType == set.SetTypeBitmapPort {
+				options = append(options, commands.WithPortRange(*ipRange))
+			} else {
+				options = append(options, commands.WithIPRange(*ipRange))
+			}
+		case "netmask":
+			options = append(options, commands.WithNetMask(*netMask))
+		case "markmask":
+			options = append(options, commands.WithMarkMask(*markMask))
+		case "hashsize":
+			options = append(options, commands.WithHashSize(*hashSize))
+		case "maxelem":
+			options = append(options, commands.WithMaxElem(*maxElements))
+		case "size":
+			options = append(options, commands.WithSize(*size))
+		case "timeout":
+			options = append(options, commands.WithTimeout(*timeout))
+		case "counters":
+			options = appendFlagOption(options, *counters, commands.WithCounters())
+		case "comment":
+			options = appendFlagOption(options, *comment, commands.WithComments())
+		case "skbinfo":
+			options = appendFlagOption(options, *skbInfo, commands.WithSKBInfo())
+		case "forceadd":
+			options = appendFlagOption(options, *forceAdd, commands.WithForceAdd())
+		}
+	})
+	if *family != "" && *family != "inet" && *family != "inet6" {
+		return usageError(flags, fmt.Sprintf("unsupported family %s", *family))
+	}
+	options = appendFlagOption(options, c.client.Exist, commands.WithExist())
+
+	create, err := commands.NewCreate(positional[0], setType, options...)
+	if err != nil {
+		return err
+	} else if err := create.RunWith(c.ctx, c.client.Executor); err != nil {
+		return err
+	}
+	return c.printResult(result{Command: "create", Set: create.Name})
+}
+
+// addDelete runs commands add and del.
+// Entries are read from arguments and from the file set by option -file, one per line ("-" reads stdin);
+// multiple entries are applied with a single batch.
+func (c *cli) addDelete(command commands.CommandName, args []string) error {
+	flags := c.newFlagSet(command.String(), "[options] <set> [entry...]")
+	file := flags.String("file", "", `file listing one entry per line, "-" for stdin`)
+	setTypeName := flags.String("type", "", "type of the set, listed by ipset if empty")
+	var timeout *int
+	var comment *string
+	var noMatch *bool
+	if command == commands.CommandNameAdd {
+		timeout = flags.Int("timeout", 0, "timeout of entries, in seconds")
+		comment = flags.String("comment", "", "comment of entries")
+		noMatch = flags.Bool("nomatch", false, "add entries as exceptions (hash:net* sets)")
+	}
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) == 0 {
+		return usageError(flags, fmt.Sprintf("%s requires a set name", command.String()))
+	}
+
+	name, entries := positional[0], positional[1:]
+	if *file != "" {
+		fileEntries, err := c.readEntriesFile(*file)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	if len(entries) == 0 {
+		return usageError(flags, fmt.Sprintf("%s requires at least one entry", command.String()))
+	}
+
+	setType, err := c.setType(name, *setTypeName)
+	if err != nil {
+		return err
+	}
+
+	batch := commands.NewBatch()
+	for _, entry := range entries {
+		e := &commands.AddTestDeleteEntry{Command: command, Name: name, Type: setType, Entry: entry}
+		if command == commands.CommandNameAdd {
+			e.Timeout = *timeout
+			e.Comment = *comment
+			e.NoMatch = *noMatch
+		}
+
+		if err := e.Validate(); err != nil {
+			return err // Nothing is applied if any entry is invalid.
+		}
+		batch.Entry(e)
+	}
+
+	if err := c.client.RunBatch(c.ctx, batch); err != nil {
+		return err
+	}
+	return c.printResult(result{Command: command.String(), Set: name, Entries: len(entries)})
+}
+
+// test runs command test; entries that are not in the set exit with the code of ErrIPSetElementMissing.
+func (c *cli) test(args []string) error {
+	flags := c.newFlagSet("test", "[options] <set> <entry>")
+	setTypeName := flags.String("type", "", "type of the set, listed by ipset if empty")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 2 {
+		return usageError(flags, "test requires a set name and an entry")
+	}
+
+	setType, err := c.setType(positional[0], *setTypeName)
+	if err != nil {
+		return err
+	}
+
+	contains, err := commands.NewTestEntry(positional[0], setType, positional[1]).ContainsWith(c.ctx, c.client.Executor)
+	if err != nil {
+		return err
+	} else if err := c.printTest(positional[0], positional[1], contains); err != nil {
+		return err
+	} else if !contains {
+		return errNotInSet
+	}
+	return nil
+}
+
+// list runs command list; all sets are listed if no set name is given.
+func (c *cli) list(args []string) error {
+	flags := c.newFlagSet("list", "[options] [set]")
+	terse := flags.Bool("terse", false, "list only headers, without members")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) > 1 {
+		return usageError(flags, "list accepts at most one set name")
+	}
+
+	list := commands.NewListSet("")
+	if len(positional) == 1 {
+		list.Name = positional[0]
+	}
+	list.Terse = *terse
+
+	sets, err := list.SetsWith(c.ctx, c.client.Executor)
+	if err != nil {
+		return err
+	}
+	return c.printSets(sets, list.Name != "")
+}
+
+// flush runs command flush.
+func (c *cli) flush(args []string) error {
+	flags := c.newFlagSet("flush", "<set>")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 1 {
+		return usageError(flags, "flush requires a set name")
+	}
+
+	if err := commands.NewFlushSet(positional[0]).RunWith(c.ctx, c.client.Executor); err != nil {
+		return err
+	}
+	return c.printResult(result{Command: "flush", Set: positional[0]})
+}
+
+// destroy runs command destroy.
+func (c *cli) destroy(args []string) error {
+	flags := c.newFlagSet("destroy", "<set>")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	} else if len(positional) != 1 {
+		return usageError(flags, "destroy requires a set name")
+	}
+
+	if err := commands.NewDestroySet(positional[0]).RunWith(c.ctx, c.client.Executor); err != nil {
+		return err
+	}
+	return c.printResult(result{Command: "destroy", Set: positional[0]})
+}
+
+// Support.
+
+// newFlagSet returns the flag set of a command.
+func (c *cli) newFlagSet(command, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: goipset %s %s\n", command, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// setType returns the type named typeName, or the type of the set named name as listed by ipset if typeName is empty.
+func (c *cli) setType(name, typeName string) (set.SetType, error) {
+	if typeName != "" {
+		return set.SetTypeWithString(typeName), nil // Unsupported types are reported by validation.
+	}
+
+	list := commands.NewListSet(name)
+	list.Terse = true
+
+	info, err := list.InfoWith(c.ctx, c.client.Executor)
+	if err != nil {
+		return set.SetTypeUnsupported, err
+	}
+	return info.Type, nil
+}
+
+// readEntriesFile returns the entries listed by a file, or by stdin if path is "-".
+func (c *cli) readEntriesFile(path string) ([]string, error) {
+	if path == "-" {
+		return readEntries(c.stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readEntries(f)
+}
+
+// readEntries returns the entries listed by r, one per line; empty lines and lines starting with # are skipped.
+func readEntries(r io.Reader) ([]string, error) {
+	out := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// parseFlags parses flags interspersed with positional arguments, and returns the positional ones.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	out := []string{}
+	for {
+		if err := flags.Parse(args); err == flag.ErrHelp {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		} else if flags.NArg() == 0 {
+			return out, nil
+		}
+
+		out = append(out, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// usageError prints the usage of a command and returns an error describing the wrong usage.
+func usageError(flags *flag.FlagSet, message string) error {
+	flags.Usage()
+	return fmt.Errorf("%w: %s", errUsage, message)
+}
+
+// appendFlagOption appends option to options if flag is true.
+func appendFlagOption(options []commands.CreateOption, flag bool, option commands.CreateOption) []commands.CreateOption {
+	if flag {
+		return append(options, option)
+	} else {
+		return options
+	}
+}
@@ -0,0 +1,166 @@
+// Command goipset manages ipset sets through go-ipset.
+// Commands are validated before ipset is invoked, and each typed error of go-ipset exits with its own code.
+//
+// Usage:
+//
+//	goipset [global options] <command> [options] <arguments>
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/netlink"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// Exit codes.
+const (
+	exitOK = iota
+	exitFailure
+	exitInvalid
+	exitNoSuchSet
+	exitSetExists
+	exitSetInUse
+	exitIncompatibleSets
+	exitElementExists
+	exitElementMissing
+	exitSetIsFull
+	exitKernelModuleMissing
+	exitPermissionDenied
+	exitTimeout
+	exitCanceled
+)
+
+// exitCodes maps errors of go-ipset to exit codes; the first matching error is used.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{errUsage, exitInvalid},
+	{liberrors.ErrIPSetCommandIsInvalid, exitInvalid},
+	{liberrors.ErrIPSetOptionNotSupported, exitInvalid},
+	{liberrors.ErrIPSetNoSuchSet, exitNoSuchSet},
+	{liberrors.ErrIPSetSetExists, exitSetExists},
+	{liberrors.ErrIPSetSetInUse, exitSetInUse},
+	{liberrors.ErrIPSetIncompatibleSets, exitIncompatibleSets},
+	{liberrors.ErrIPSetElementExists, exitElementExists},
+	{liberrors.ErrIPSetElementMissing, exitElementMissing},
+	{liberrors.ErrIPSetSetIsFull, exitSetIsFull},
+	{liberrors.ErrIPSetKernelModuleMissing, exitKernelModuleMissing},
+	{liberrors.ErrIPSetPermissionDenied, exitPermissionDenied},
+	{liberrors.ErrIPSetTimeout, exitTimeout},
+	{liberrors.ErrIPSetCanceled, exitCanceled},
+}
+
+// errUsage is returned when goipset is invoked with wrong arguments.
+var errUsage = errors.New("invalid usage")
+
+// errNotInSet is returned by command test when the entry is not in the set, after printing the result.
+var errNotInSet = fmt.Errorf("%w: entry is not in set", liberrors.ErrIPSetElementMissing)
+
+const usage = `Usage: goipset [global options] <command> [options] <arguments>
+
+Commands:
+  create [options] <set> <type>     Create a set.
+  add [options] <set> [entry...]    Add entries to a set.
+  del [options] <set> [entry...]    Delete entries from a set.
+  test [options] <set> <entry>      Test if an entry is in a set.
+  list [options] [set]              List one or all sets.
+  flush <set>                       Flush a set.
+  destroy <set>                     Destroy a set.
+
+Run goipset <command> -h to list the options of a command.
+
+Global options:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, nil))
+}
+
+// run runs goipset with a list of arguments and returns its exit code.
+// If executor is nil, it is selected through global options.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, executor utilities.Executor) int {
+	flags := flag.NewFlagSet("goipset", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", outputTable, "output format, table or json")
+	exist := flags.Bool("exist", false, "ignore errors when sets or entries already exist or are missing")
+	ipsetPath := flags.String("ipset", "", "path of the ipset executable")
+	useNetlink := flags.Bool("netlink", false, "talk to the kernel through netlink instead of running ipset")
+	timeout := flags.Duration("timeout", 0, "maximum duration of the command, 0 for no limit")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitInvalid
+	} else if flags.NArg() == 0 {
+		flags.Usage()
+		return exitInvalid
+	} else if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "goipset: unsupported output %s\n", *output)
+		return exitInvalid
+	}
+
+	if executor != nil {
+		// Executor provided by the caller.
+	} else if *useNetlink {
+		executor = netlink.NewExecutor()
+	} else if *ipsetPath != "" {
+		executor = utilities.NewBinaryExecutor(*ipsetPath)
+	} else {
+		executor = utilities.DefaultExecutor
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	client := commands.NewClient(executor)
+	client.Exist = *exist
+	c := &cli{ctx: ctx, client: client, output: *output, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	command, found := c.commands()[flags.Arg(0)]
+	if !found {
+		fmt.Fprintf(stderr, "goipset: unknown command %s\n", flags.Arg(0))
+		flags.Usage()
+		return exitInvalid
+	}
+
+	if err := command(flags.Args()[1:]); err != nil {
+		if err != flag.ErrHelp && err != errNotInSet {
+			c.printError(err)
+		}
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+// exitCode returns the exit code matching err.
+func exitCode(err error) int {
+	if err == nil || err == flag.ErrHelp {
+		return exitOK
+	}
+
+	for _, code := range exitCodes {
+		if errors.Is(err, code.err) {
+			return code.code
+		}
+	}
+	return exitFailure
+}
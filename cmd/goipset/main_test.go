@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestRun(t *testing.T) {
+	type test struct {
+		args     []string
+		stdin    string
+		outputs  []string
+		errors   []error
+		calls    []string
+		input    string
+		stdout   string
+		exitCode int
+	}
+
+	exitErr := errors.New("exit status 1")
+	tests := []test{
+		{
+			args:  []string{"create", "-family", "inet6", "test", "hash:ip", "-maxelem", "10", "-timeout", "60"},
+			calls: []string{"create test hash:ip family inet6 maxelem 10 timeout 60"},
+		},
+		{
+			args:  []string{"-exist", "create", "-range", "0-1024", "ports", "bitmap:port"},
+			calls: []string{"create ports bitmap:port range 0-1024 -exist"},
+		},
+		{args: []string{"create", "-maxelem", "10", "test", "bitmap:ip", "-range", "10.0.0.0/8"}, exitCode: exitInvalid},
+		{args: []string{"create", "-netmask", "40", "test", "hash:ip"}, exitCode: exitInvalid},
+		{args: []string{"create", "test"}, exitCode: exitInvalid},
+		{
+			args:     []string{"create", "test", "hash:ip"},
+			outputs:  []string{"ipset v7.1: Set cannot be created: set with the same name already exists\n"},
+			errors:   []error{exitErr},
+			calls:    []string{"create test hash:ip"},
+			exitCode: exitSetExists,
+		},
+		{
+			args:    []string{"add", "test", "1.1.1.1", "-file", "-"},
+			stdin:   "# blocklist\n2.2.2.2\n\n3.3.3.3\n",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0)), ""},
+			calls:   []string{"list test -terse -output xml", "restore"},
+			input:   "add test 1.1.1.1\nadd test 2.2.2.2\nadd test 3.3.3.3\n",
+		},
+		{
+			args:  []string{"-exist", "add", "-type", "hash:ip,port", "-timeout", "10", "web", "1.1.1.1,tcp:80"},
+			calls: []string{"restore -exist"},
+			input: "add web 1.1.1.1,tcp:80 timeout 10\n",
+		},
+		{args: []string{"add", "-type", "hash:ip,port", "web", "1.1.1.1,tcp:80", "1.1.1.1,tcp:70000"}, exitCode: exitInvalid},
+		{args: []string{"add", "-type", "hash:ip", "web"}, exitCode: exitInvalid},
+		{
+			args:     []string{"del", "test", "1.1.1.1"},
+			outputs:  []string{"ipset v7.1: The set with the given name does not exist\n"},
+			errors:   []error{exitErr},
+			calls:    []string{"list test -terse -output xml"},
+			exitCode: exitNoSuchSet,
+		},
+		{
+			args:   []string{"test", "-type", "hash:ip", "test", "1.1.1.1"},
+			calls:  []string{"test test 1.1.1.1"},
+			stdout: "1.1.1.1 is in set test.\n",
+		},
+		{
+			args:     []string{"-o", "json", "test", "-type", "hash:ip", "test", "1.1.1.1"},
+			outputs:  []string{"ipset v7.1: 1.1.1.1 is NOT in set test.\n"},
+			errors:   []error{exitErr},
+			calls:    []string{"test test 1.1.1.1"},
+			stdout:   "{\n  \"set\": \"test\",\n  \"entry\": \"1.1.1.1\",\n  \"contains\": false\n}\n",
+			exitCode: exitElementMissing,
+		},
+		{
+			args:    []string{"list"},
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("a", "hash:ip", 0, utilitiestest.Members("1.1.1.1")...), utilitiestest.SetOutput("b", "list:set", 0))},
+			calls:   []string{"list -output xml"},
+			stdout:  "NAME  TYPE      FAMILY  ENTRIES  REFERENCES\na     hash:ip           0        0\nb     list:set          0        0\n",
+		},
+		{
+			args:    []string{"-o", "json", "list", "a"},
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("a", "hash:ip", 0, utilitiestest.Members("1.1.1.1")...))},
+			calls:   []string{"list a -output xml"},
+			stdout: "[\n  {\n    \"name\": \"a\",\n    \"type\": \"hash:ip\",\n    \"memsize\": 0,\n    \"references\": 0,\n    \"numentries\": 0,\n" +
+				"    \"members\": [\n      {\n        \"entry\": \"1.1.1.1\"\n      }\n    ]\n  }\n]\n",
+		},
+		{
+			args:     []string{"flush", "test"},
+			outputs:  []string{"ipset v7.1: Kernel error received: Operation not permitted\n"},
+			errors:   []error{exitErr},
+			calls:    []string{"flush test"},
+			exitCode: exitPermissionDenied,
+		},
+		{args: []string{"-o", "json", "destroy", "test"}, calls: []string{"destroy test"}, stdout: "{\n  \"command\": \"destroy\",\n  \"set\": \"test\"\n}\n"},
+		{args: []string{"destroy"}, exitCode: exitInvalid},
+		{args: []string{"unknown"}, exitCode: exitInvalid},
+		{args: []string{"-o", "xml", "list"}, exitCode: exitInvalid},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: test.outputs, Errors: test.errors}}
+		var stdout, stderr bytes.Buffer
+		exitCode := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr, executor)
+
+		calls := make([]string, len(executor.Calls))
+		for j, call := range executor.Calls {
+			calls[j] = strings.Join(call, " ")
+		}
+
+		if exitCode != test.exitCode {
+			t.Errorf("expectation %d failed: exit code %d != %d (expected), %s", i+1, exitCode, test.exitCode, stderr.String())
+		} else if result, expects := fmt.Sprintf("%q", calls), fmt.Sprintf("%q", test.calls); len(calls)+len(test.calls) > 0 && result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		} else if strings.Join(executor.Inputs, "") != test.input {
+			t.Errorf("expectation %d failed: input %q != %q (expected)", i+1, strings.Join(executor.Inputs, ""), test.input)
+		} else if stdout.String() != test.stdout {
+			t.Errorf("expectation %d failed: output %q != %q (expected)", i+1, stdout.String(), test.stdout)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := map[error]int{
+		nil:                                                      exitOK,
+		errors.New("unknown"):                                    exitFailure,
+		liberrors.ErrIPSetDidFail:                                exitFailure,
+		liberrors.ErrIPSetOptionNotSupported:                     exitInvalid,
+		liberrors.ErrIPSetSetInUse:                               exitSetInUse,
+		liberrors.ErrIPSetIncompatibleSets:                       exitIncompatibleSets,
+		liberrors.ErrIPSetElementExists:                          exitElementExists,
+		liberrors.ErrIPSetSetIsFull:                              exitSetIsFull,
+		liberrors.ErrIPSetKernelModuleMissing:                    exitKernelModuleMissing,
+		liberrors.ErrIPSetTimeout:                                exitTimeout,
+		liberrors.ErrIPSetCanceled:                               exitCanceled,
+		&liberrors.IPSetError{Kind: liberrors.ErrIPSetNoSuchSet}: exitNoSuchSet,
+		fmt.Errorf("batch: %w", &liberrors.IPSetError{}):         exitFailure,
+	}
+
+	for err, expects := range tests {
+		if result := exitCode(err); result != expects {
+			t.Errorf("expectation failed for %v: %d != %d (expected)", err, result, expects)
+		}
+	}
+}
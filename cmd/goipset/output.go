@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/rest"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// result describes the outcome of commands that change sets.
+type result struct {
+	Command string `json:"command"`
+	Set     string `json:"set"`
+	Entries int    `json:"entries,omitempty"`
+}
+
+// errorResult describes a failed command.
+type errorResult struct {
+	Error    string `json:"error"`
+	Command  string `json:"command,omitempty"` // ipset command, if ipset failed.
+	Set      string `json:"set,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// printResult prints the outcome of commands that change sets; nothing is printed with table output.
+func (c *cli) printResult(r result) error {
+	if c.output == outputJSON {
+		return c.printJSON(r)
+	}
+	return nil
+}
+
+// printTest prints the outcome of command test.
+func (c *cli) printTest(name, entry string, contains bool) error {
+	if c.output == outputJSON {
+		return c.printJSON(rest.TestResult{Set: name, Entry: entry, Contains: contains})
+	} else if contains {
+		_, err := fmt.Fprintf(c.stdout, "%s is in set %s.\n", entry, name)
+		return err
+	} else {
+		_, err := fmt.Fprintf(c.stdout, "%s is NOT in set %s.\n", entry, name)
+		return err
+	}
+}
+
+// printSets prints sets listed by ipset: a summary of all sets, or headers and members if a single set was listed.
+func (c *cli) printSets(sets []commands.SetInfo, details bool) error {
+	views := make([]rest.Set, len(sets))
+	for i, info := range sets {
+		views[i] = rest.NewSet(info)
+	}
+
+	if c.output == outputJSON {
+		return c.printJSON(views)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	if !details {
+		fmt.Fprintln(w, "NAME\tTYPE\tFAMILY\tENTRIES\tREFERENCES")
+		for _, view := range views {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", view.Name, view.Type, view.Family, view.NumEntries, view.References)
+		}
+		return w.Flush()
+	}
+
+	for i, view := range views {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Name:\t%s\n", view.Name)
+		fmt.Fprintf(w, "Type:\t%s\n", view.Type)
+		fmt.Fprintf(w, "Header:\t%s\n", headerDescription(view))
+		fmt.Fprintf(w, "Size in memory:\t%d\n", view.MemSize)
+		fmt.Fprintf(w, "References:\t%d\n", view.References)
+		fmt.Fprintf(w, "Number of entries:\t%d\n", view.NumEntries)
+		if len(view.Members) > 0 {
+			fmt.Fprintln(w, "\nENTRY\tTIMEOUT\tPACKETS\tBYTES\tCOMMENT")
+			for _, member := range view.Members {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", member.Entry, member.Timeout, member.Packets, member.Bytes, member.Comment)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// printError prints err to stderr, as a JSON object with JSON output.
+func (c *cli) printError(err error) {
+	if c.output != outputJSON {
+		fmt.Fprintf(c.stderr, "goipset: %v\n", err)
+		return
+	}
+
+	out := errorResult{Error: err.Error(), ExitCode: exitCode(err)}
+	var ipsetErr *liberrors.IPSetError
+	if errors.As(err, &ipsetErr) {
+		out.Command = ipsetErr.Command
+		out.Set = ipsetErr.Set
+	}
+
+	encoder := json.NewEncoder(c.stderr)
+	encoder.Encode(out)
+}
+
+// printJSON prints v as indented JSON.
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// headerDescription returns the header of a set formatted as listed by ipset.
+func headerDescription(view rest.Set) string {
+	out := []string{}
+	appendInt := func(name string, value int) {
+		if value != 0 {
+			out = append(out, fmt.Sprintf("%s %d", name, value))
+		}
+	}
+	appendFlag := func(name string, flag bool) {
+		if flag {
+			out = append(out, name)
+		}
+	}
+
+	if view.Family != "" {
+		out = append(out, "family "+view.Family)
+	}
+	if view.Range != "" {
+		out = append(out, "range "+view.Range)
+	}
+	appendInt("hashsize", view.HashSize)
+	appendInt("maxelem", view.MaxElem)
+	appendInt("size", view.Size)
+	appendInt("netmask", view.NetMask)
+	appendInt("markmask", view.MarkMask)
+	appendInt("timeout", view.Timeout)
+	appendFlag("counters", view.Counters)
+	appendFlag("comment", view.Comment)
+	appendFlag("skbinfo", view.SKBInfo)
+	appendFlag("forceadd", view.ForceAdd)
+	return strings.Join(out, " ")
+}
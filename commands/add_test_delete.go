@@ -1,8 +1,13 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/set"
 	"github.com/francescocolleoni/go-ipset/utilities"
 )
@@ -19,6 +24,21 @@ type AddTestDeleteEntry struct {
 	Entry     string // Parsing depends on the set type.
 	BeforeSet string // Used only for list:set sets.
 	AfterSet  string // Used only for list:set sets.
+
+	// Entry options, used only by add commands; each one requires a matching option of the target set.
+	Timeout     int    // Requires timeout; 0 uses the default timeout of the set.
+	Permanent   bool   // Requires timeout; the entry never expires (timeout 0).
+	Comment     string // Requires comment.
+	Packets     uint64 // Requires counters.
+	Bytes       uint64 // Requires counters.
+	SKBMark     uint32 // Requires skbinfo.
+	SKBMarkMask uint32 // Requires skbinfo, defaults to 0xffffffff.
+	SKBPrio     string // Requires skbinfo, formatted as major:minor.
+	SKBQueue    int    // Requires skbinfo.
+	NoMatch     bool   // Used only for hash:net* and hash:ip,port,net sets.
+
+	// Global options.
+	Exist bool // Do not fail if an added entry already exists (refreshing its options) or a deleted entry is missing.
 }
 
 // NewAddEntry returns an add entry command.
@@ -28,6 +48,11 @@ func NewAddEntry(name string, setType set.SetType, entry string) *AddTestDeleteE
 	return &AddTestDeleteEntry{Command: CommandNameAdd, Name: name, Type: setType, Entry: entry}
 }
 
+// NewAddTypedEntry returns an add entry command for a typed entry, whose type defines the type of the target set.
+func NewAddTypedEntry(name string, entry set.Entry) *AddTestDeleteEntry {
+	return NewAddEntry(name, entry.SetType(), entry.String())
+}
+
 // NewAddListEntry returns an add entry command for a list:set set.
 func NewAddListEntry(name string) *AddTestDeleteEntry {
 	return &AddTestDeleteEntry{Command: CommandNameAdd, Name: name, Type: set.SetTypeListSet}
@@ -50,6 +75,11 @@ func NewDeleteEntry(name string, setType set.SetType, entry string) *AddTestDele
 	return &AddTestDeleteEntry{Command: CommandNameDelete, Name: name, Type: setType, Entry: entry}
 }
 
+// NewDeleteTypedEntry returns a delete entry command for a typed entry, whose type defines the type of the target set.
+func NewDeleteTypedEntry(name string, entry set.Entry) *AddTestDeleteEntry {
+	return NewDeleteEntry(name, entry.SetType(), entry.String())
+}
+
 // NewDeleteListEntry returns a delete entry command for a list:set set.
 func NewDeleteListEntry(name string) *AddTestDeleteEntry {
 	return &AddTestDeleteEntry{Command: CommandNameDelete, Name: name, Type: set.SetTypeListSet}
@@ -71,6 +101,11 @@ func NewTestEntry(name string, setType set.SetType, entry string) *AddTestDelete
 	return &AddTestDeleteEntry{Command: CommandNameTest, Name: name, Type: setType, Entry: entry}
 }
 
+// NewTestTypedEntry returns a test entry command for a typed entry, whose type defines the type of the target set.
+func NewTestTypedEntry(name string, entry set.Entry) *AddTestDeleteEntry {
+	return NewTestEntry(name, entry.SetType(), entry.String())
+}
+
 // NewTestListEntry returns a test entry command for a list:set set.
 func NewTestListEntry(name string) *AddTestDeleteEntry {
 	return &AddTestDeleteEntry{Command: CommandNameTest, Name: name, Type: set.SetTypeListSet}
@@ -88,26 +123,33 @@ func NewTestListEntryAfter(name, afterSet string) *AddTestDeleteEntry {
 
 // AddTestDeleteEntry implementation of TranslateToIPSetArgs.
 func (c *AddTestDeleteEntry) TranslateToIPSetArgs() []string {
+	options := c.entryOptions()
+	if options == nil {
+		return []string{} // Invalid entry options.
+	}
+
 	makeArgs := func(args ...string) []string {
 		out := []string{c.Command.String(), c.Name}
-		return append(out, args...)
+		out = append(out, args...)
+		out = append(out, options...)
+		return append(out, existOption(c.Exist)...)
 	}
 
 	switch c.Type {
 	case set.SetTypeBitmapIP:
 		if c.Command == CommandNameTest {
-			if matchesTarget(c.Entry, "", ipMatch) {
+			if matchesTarget(c.Entry, "", ipv4Match) {
 				return makeArgs(c.Entry) // This is the only supported scenario for test command and bitmap:ip.
 			}
-		} else if matchesTarget(c.Entry, "", ipMatch) ||
-			matchesTarget(c.Entry, "-", ipMatch, ipMatch) ||
-			matchesTarget(c.Entry, "", ipCidrMatch) {
+		} else if matchesTarget(c.Entry, "", ipv4Match) ||
+			matchesTarget(c.Entry, "-", ipv4Match, ipv4Match) ||
+			matchesTarget(c.Entry, "", ipv4CidrMatch) {
 			return makeArgs(c.Entry)
 		}
 
 	case set.SetTypeBitmapIPMAC:
-		if matchesTarget(c.Entry, "", ipMatch) ||
-			matchesTarget(c.Entry, ",", ipMatch, macMatch) {
+		if matchesTarget(c.Entry, "", ipv4Match) ||
+			matchesTarget(c.Entry, ",", ipv4Match, macMatch) {
 			return makeArgs(c.Entry)
 		}
 
@@ -210,12 +252,17 @@ func (c *AddTestDeleteEntry) TranslateToIPSetArgs() []string {
 			return []string{} // Name is always required.
 		}
 
+		args := []string{}
+		if c.Entry != "" {
+			args = append(args, c.Entry) // Name of the set that is added, deleted or tested.
+		}
+
 		if c.BeforeSet != "" {
-			return makeArgs("before", c.BeforeSet)
+			return makeArgs(append(args, "before", c.BeforeSet)...)
 		} else if c.AfterSet != "" {
-			return makeArgs("after", c.AfterSet)
+			return makeArgs(append(args, "after", c.AfterSet)...)
 		} else {
-			return makeArgs() // No "before" or "after" args, defaults to plain "add" option.
+			return makeArgs(args...) // No "before" or "after" args, defaults to plain "add" option.
 		}
 	}
 
@@ -228,17 +275,84 @@ func (c *AddTestDeleteEntry) IncludesMandatoryOptions() bool {
 	return len(c.TranslateToIPSetArgs()) > 0
 }
 
+// AddTestDeleteEntry implementation of Validate.
+// Entries are validated according to the set type, checking port ranges and entry options;
+// options supported only by some sets are checked by ValidateOptionsFor.
+func (c *AddTestDeleteEntry) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameAdd, CommandNameDelete, CommandNameTest); err != nil {
+		return err
+	} else if err := validateSetName("name", c.Name); err != nil {
+		return err
+	} else if c.Type.Features() == 0 {
+		return fmt.Errorf("%w: set type %v is not supported", liberrors.ErrIPSetCommandIsInvalid, c.Type)
+	} else if strings.Trim(c.Entry, " \n") == "" {
+		return fmt.Errorf("%w: entry is missing", liberrors.ErrIPSetCommandIsInvalid)
+	} else if err := c.validateEntryOptions(); err != nil {
+		return err
+	} else if err := c.validatePorts(); err != nil {
+		return err
+	} else if len(c.TranslateToIPSetArgs()) == 0 {
+		return fmt.Errorf(`%w: entry "%s" is invalid for sets of type %v`, liberrors.ErrIPSetCommandIsInvalid, c.Entry, c.Type)
+	} else {
+		return nil
+	}
+}
+
+// ValidateOptionsFor returns an error if entry options of c are not supported by a set created by create
+// (ex.: a comment for a set created without comment); sets listed by ipset can be checked with NewCreateFromInfo.
+func (c *AddTestDeleteEntry) ValidateOptionsFor(create *CreateSet) error {
+	unsupported := func(option, requirement string) error {
+		return fmt.Errorf(`%w: option %s of entry %s requires set "%s" to be created with %s`,
+			liberrors.ErrIPSetOptionNotSupported, option, c.Entry, create.Name, requirement)
+	}
+
+	switch {
+	case c.Type != create.Type:
+		return fmt.Errorf(`%w: entry %s of type %v cannot be added to set "%s" of type %v`,
+			liberrors.ErrIPSetCommandIsInvalid, c.Entry, c.Type, create.Name, create.Type)
+	case c.Command != CommandNameAdd:
+		return nil // Entry options are used only by add commands.
+	case (c.Timeout > 0 || c.Permanent) && create.Timeout <= 0:
+		return unsupported("timeout", "timeout")
+	case len(commentOption(c.Comment)) > 0 && !create.AllowsComments:
+		return unsupported("comment", "comment")
+	case (c.Packets > 0 || c.Bytes > 0) && !create.UseCounters:
+		return unsupported("packets/bytes", "counters")
+	case (c.SKBMark > 0 || c.SKBMarkMask > 0 || c.SKBPrio != "" || c.SKBQueue > 0) && !create.UseSKBInfo:
+		return unsupported("skbmark/skbprio/skbqueue", "skbinfo")
+	case c.NoMatch && create.Type.Features()&set.FeatureNoMatch == 0:
+		return fmt.Errorf(`%w: option nomatch of entry %s is not supported by set "%s" of type %v`,
+			liberrors.ErrIPSetOptionNotSupported, c.Entry, create.Name, create.Type)
+	default:
+		return nil
+	}
+}
+
 // Run executes an AddTestDeleteEntry command.
 func (c *AddTestDeleteEntry) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes an AddTestDeleteEntry command; ipset is killed if ctx is done before it exits.
+func (c *AddTestDeleteEntry) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes an AddTestDeleteEntry command using a given executor.
+func (c *AddTestDeleteEntry) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
 	switch c.Command {
 	case CommandNameAdd, CommandNameDelete:
-		if out, err := utilities.RunIPSet(c.TranslateToIPSetArgs()...); err != nil {
+		if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err != nil {
 			return out.Error
 		}
 
 		return nil
 	case CommandNameTest:
-		if out, err := utilities.RunIPSet(c.TranslateToIPSetArgs()...); err != nil {
+		if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err != nil {
 			return out.Error
 		} else {
 			// Command ipset does not return an error if the target is contained in the given set.
@@ -248,3 +362,103 @@ func (c *AddTestDeleteEntry) Run() error {
 		return fmt.Errorf("command %s is not add, test or delete", c.Command.String())
 	}
 }
+
+// Contains executes a test command and returns true if the entry is in the set.
+// In contrast with Run, an entry that is not in the set is not reported as an error.
+func (c *AddTestDeleteEntry) Contains() (bool, error) {
+	return c.ContainsContext(context.Background())
+}
+
+// ContainsContext executes a test command and returns true if the entry is in the set;
+// ipset is killed if ctx is done before it exits.
+func (c *AddTestDeleteEntry) ContainsContext(ctx context.Context) (bool, error) {
+	return c.ContainsWith(ctx, utilities.DefaultExecutor)
+}
+
+// ContainsWith executes a test command using a given executor and returns true if the entry is in the set.
+// Any failure other than ErrIPSetElementMissing (ex.: ErrIPSetNoSuchSet) is returned as an error.
+func (c *AddTestDeleteEntry) ContainsWith(ctx context.Context, executor utilities.Executor) (bool, error) {
+	if err := validateCommandName(c.Command, CommandNameTest); err != nil {
+		return false, err
+	} else if err := c.Validate(); err != nil {
+		return false, err
+	}
+
+	if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err == nil {
+		return true, nil
+	} else if errors.Is(out.Error, liberrors.ErrIPSetElementMissing) {
+		return false, nil
+	} else {
+		return false, out.Error
+	}
+}
+
+// Support.
+
+// validateEntryOptions returns an error describing the first invalid entry option of an add command.
+func (c *AddTestDeleteEntry) validateEntryOptions() error {
+	switch {
+	case c.Command != CommandNameAdd:
+		return nil // Entry options are ignored by delete and test commands.
+	case c.Timeout < 0:
+		return fmt.Errorf("%w: timeout %d is negative", liberrors.ErrIPSetCommandIsInvalid, c.Timeout)
+	case c.Permanent && c.Timeout != 0:
+		return fmt.Errorf("%w: permanent entries cannot have timeout %d", liberrors.ErrIPSetCommandIsInvalid, c.Timeout)
+	case c.SKBQueue < 0 || c.SKBQueue > 65535:
+		return fmt.Errorf("%w: skbqueue %d out of range [0, 65535]", liberrors.ErrIPSetCommandIsInvalid, c.SKBQueue)
+	case c.SKBPrio != "" && len(skbPrioOption(c.SKBPrio)) == 0:
+		return fmt.Errorf(`%w: skbprio "%s" is not formatted as major:minor`, liberrors.ErrIPSetCommandIsInvalid, c.SKBPrio)
+	case c.NoMatch && c.Type.Features()&set.FeatureNoMatch == 0:
+		return fmt.Errorf("%w: option nomatch is not supported by sets of type %v", liberrors.ErrIPSetOptionNotSupported, c.Type)
+	default:
+		return nil
+	}
+}
+
+// validatePorts returns an error if a port of the entry of c is out of range.
+// Ports are the whole entry of bitmap:port sets and the second component of other sets with ports,
+// optionally prefixed by a protocol (ex.: tcp:80) or expressed as a range (ex.: 80-90).
+func (c *AddTestDeleteEntry) validatePorts() error {
+	var ports string
+	if c.Type == set.SetTypeBitmapPort {
+		ports = c.Entry
+	} else if components := strings.Split(c.Entry, ","); c.Type.Features()&set.FeaturePort != 0 && len(components) > 1 {
+		ports = components[1]
+	} else {
+		return nil
+	}
+
+	if i := strings.LastIndex(ports, ":"); i >= 0 {
+		ports = ports[i+1:]
+	}
+	for _, raw := range strings.Split(ports, "-") {
+		if port, err := strconv.Atoi(raw); err == nil && port > 65535 {
+			return fmt.Errorf("%w: port %d out of range [0, 65535]", liberrors.ErrIPSetCommandIsInvalid, port)
+		}
+	}
+	return nil
+}
+
+// entryOptions returns the entry options of an add command, or nil if any of them is invalid.
+func (c *AddTestDeleteEntry) entryOptions() []string {
+	if c.Command != CommandNameAdd {
+		return []string{} // Entry options are ignored by delete and test commands.
+	} else if c.Timeout < 0 || c.Permanent && c.Timeout != 0 || c.SKBQueue < 0 || c.SKBQueue > 65535 {
+		return nil
+	} else if c.SKBPrio != "" && len(skbPrioOption(c.SKBPrio)) == 0 {
+		return nil
+	}
+
+	out := timeoutOption(c.Timeout)
+	if c.Permanent {
+		out = []string{"timeout", "0"}
+	}
+	out = append(out, counterOption("packets", c.Packets)...)
+	out = append(out, counterOption("bytes", c.Bytes)...)
+	out = append(out, commentOption(c.Comment)...)
+	out = append(out, skbMarkOption(c.SKBMark, c.SKBMarkMask)...)
+	out = append(out, skbPrioOption(c.SKBPrio)...)
+	out = append(out, skbQueueOption(c.SKBQueue)...)
+	out = append(out, noMatchOption(c.NoMatch)...)
+	return out
+}
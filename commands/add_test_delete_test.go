@@ -1,12 +1,17 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/netip"
 	"strings"
 	"testing"
 
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/set"
 	"github.com/francescocolleoni/go-ipset/utilities"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
 )
 
 func TestAddSetTranslateToCommandLine(t *testing.T) {
@@ -45,6 +50,7 @@ func TestAddSetTranslateToCommandLine(t *testing.T) {
 		{NewAddEntry(setName, set.SetTypeHashIPPortNet, "1.1.1.1,tcp:56789,2.2.2.2/10"), []string{"1.1.1.1,tcp:56789,2.2.2.2/10"}},
 
 		{NewAddEntry(setName, set.SetTypeHashIPMark, "1.1.1.1,10"), []string{"1.1.1.1,10"}},
+		{NewAddEntry(setName, set.SetTypeHashIPMark, "1.1.1.1,0x0000000a"), []string{"1.1.1.1,0x0000000a"}},
 
 		{NewAddEntry(setName, set.SetTypeHashMAC, "aa:bb:cc:11:22:33"), []string{"aa:bb:cc:11:22:33"}},
 
@@ -78,6 +84,7 @@ func TestAddSetTranslateToCommandLine(t *testing.T) {
 		{NewAddListEntry(setName), []string{}},
 		{NewAddListEntryBefore(setName, "otherset"), []string{"before", "otherset"}},
 		{NewAddListEntryAfter(setName, "otherset"), []string{"after", "otherset"}},
+		{NewAddEntry(setName, set.SetTypeListSet, "memberset"), []string{"memberset"}},
 	}
 
 	for i, test := range tests {
@@ -325,6 +332,52 @@ func TestAddSetValidate(t *testing.T) {
 	}
 }
 
+func TestAddSetValidateIPv6(t *testing.T) {
+	type test struct {
+		command *AddTestDeleteEntry
+		expects bool
+	}
+
+	const setName = "testset"
+	tests := []test{
+		// Valid.
+		{NewAddEntry(setName, set.SetTypeHashIP, "2001:0db8:0000:0000:0000:ff00:0042:8329"), true},
+		{NewAddEntry(setName, set.SetTypeHashIP, "2001:db8::ff00:42:8329"), true},
+		{NewAddEntry(setName, set.SetTypeHashIP, "::1"), true},
+		{NewAddEntry(setName, set.SetTypeHashIP, "::"), true},
+		{NewAddEntry(setName, set.SetTypeHashIP, "fe80::"), true},
+		{NewAddEntry(setName, set.SetTypeHashIP, "::ffff:1.2.3.4"), true},
+
+		{NewAddEntry(setName, set.SetTypeHashIPPort, "2001:db8::1,tcp:443"), true},
+		{NewAddEntry(setName, set.SetTypeHashIPPortNet, "2001:db8::1,443,2001:db8::/32"), true},
+		{NewAddEntry(setName, set.SetTypeHashIPMark, "2001:db8::1,0xff"), true},
+
+		{NewAddEntry(setName, set.SetTypeHashNet, "2001:db8::/32"), true},
+		{NewAddEntry(setName, set.SetTypeHashNet, "2001:db8::1/128"), true},
+		{NewAddEntry(setName, set.SetTypeHashNetNet, "2001:db8::/64,2001:db8:1::/48"), true},
+		{NewAddEntry(setName, set.SetTypeHashNetIFace, "2001:db8::/64,physdev:eth0"), true},
+
+		// Not valid.
+		{NewAddEntry(setName, set.SetTypeHashIP, "2001:db8:::1"), false},
+		{NewAddEntry(setName, set.SetTypeHashIP, "2001:db8::g"), false},
+		{NewAddEntry(setName, set.SetTypeHashIP, "1:2:3:4:5:6:7:8:9"), false},
+		{NewAddEntry(setName, set.SetTypeHashNet, "2001:db8::/129"), false},
+		{NewAddEntry(setName, set.SetTypeHashNet, "1.1.1.1/33"), false},
+
+		// Bitmap sets only support IPv4.
+		{NewAddEntry(setName, set.SetTypeBitmapIP, "2001:db8::1"), false},
+		{NewAddEntry(setName, set.SetTypeBitmapIP, "2001:db8::/64"), false},
+		{NewAddEntry(setName, set.SetTypeBitmapIPMAC, "2001:db8::1,aa:bb:cc:11:22:33"), false},
+	}
+
+	for i, test := range tests {
+		result := test.command.IncludesMandatoryOptions()
+		if result != test.expects {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, result, test.expects)
+		}
+	}
+}
+
 func TestDeleteSetValidate(t *testing.T) {
 	type test struct {
 		command *AddTestDeleteEntry
@@ -731,3 +784,193 @@ func logTestAddTestDeleteCommandLine(t *testing.T, c *AddTestDeleteEntry, i int)
 
 	t.Logf("    - [%02d] running ipset %s", i+1, command)
 }
+
+func TestTypedEntryTranslateToCommandLine(t *testing.T) {
+	type test struct {
+		command *AddTestDeleteEntry
+		args    []string
+	}
+
+	const setName = "testset"
+	addr := netip.MustParseAddr("1.1.1.1")
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	tests := []test{
+		{NewAddTypedEntry(setName, set.NewHashIPEntry(addr)), []string{"add", setName, "1.1.1.1"}},
+		{NewAddTypedEntry(setName, set.NewHashIPPortEntry(addr, "tcp", 80)), []string{"add", setName, "1.1.1.1,tcp:80"}},
+		{NewDeleteTypedEntry(setName, set.NewHashNetNetEntry(prefix, netip.PrefixFrom(addr, 32))), []string{"del", setName, "10.0.0.0/8,1.1.1.1"}},
+		{NewTestTypedEntry(setName, set.NewHashIPMarkEntry(addr, 0xff)), []string{"test", setName, "1.1.1.1,0x000000ff"}},
+		{NewTestTypedEntry(setName, set.NewListSetEntry("other")), []string{"test", setName, "other"}},
+	}
+
+	for i, test := range tests {
+		if result := fmt.Sprintf("%v", test.command.TranslateToIPSetArgs()); result != fmt.Sprintf("%v", test.args) {
+			t.Errorf("expectation %d failed: %s != %v (expected)", i+1, result, test.args)
+		}
+	}
+}
+
+func TestEntryOptionsTranslateToCommandLine(t *testing.T) {
+	type test struct {
+		command *AddTestDeleteEntry
+		args    []string
+	}
+
+	const setName = "testset"
+	withOptions := func(c *AddTestDeleteEntry, options func(c *AddTestDeleteEntry)) *AddTestDeleteEntry {
+		options(c)
+		return c
+	}
+
+	tests := []test{
+		{
+			withOptions(NewAddEntry(setName, set.SetTypeHashNet, "10.0.0.0/8"), func(c *AddTestDeleteEntry) {
+				c.Timeout = 60
+				c.Packets = 10
+				c.Bytes = 840
+				c.Comment = `a "quoted" comment`
+				c.SKBMark = 0x10
+				c.SKBMarkMask = 0xff
+				c.SKBPrio = "1:2"
+				c.SKBQueue = 3
+				c.NoMatch = true
+			}),
+			[]string{"add", setName, "10.0.0.0/8", "timeout", "60", "packets", "10", "bytes", "840", "comment", "a quoted comment",
+				"skbmark", "0x10/0xff", "skbprio", "1:2", "skbqueue", "3", "nomatch"},
+		},
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Permanent = true }), []string{"add", setName, "1.1.1.1", "timeout", "0"}},
+		{withOptions(NewAddListEntryBefore(setName, "other"), func(c *AddTestDeleteEntry) { c.Entry = "member"; c.Timeout = 5 }), []string{"add", setName, "member", "before", "other", "timeout", "5"}},
+
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Timeout = 60; c.Exist = true }), []string{"add", setName, "1.1.1.1", "timeout", "60", "-exist"}},
+		{withOptions(NewDeleteEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Exist = true }), []string{"del", setName, "1.1.1.1", "-exist"}},
+
+		// Entry options are ignored by delete and test commands.
+		{withOptions(NewDeleteEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Timeout = 60 }), []string{"del", setName, "1.1.1.1"}},
+		{withOptions(NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Comment = "comment" }), []string{"test", setName, "1.1.1.1"}},
+
+		// Invalid options.
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Timeout = -1 }), []string{}},
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.Timeout = 5; c.Permanent = true }), []string{}},
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.SKBPrio = "invalid" }), []string{}},
+		{withOptions(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), func(c *AddTestDeleteEntry) { c.SKBQueue = 65536 }), []string{}},
+	}
+
+	for i, test := range tests {
+		if result := fmt.Sprintf("%v", test.command.TranslateToIPSetArgs()); result != fmt.Sprintf("%v", test.args) {
+			t.Errorf("expectation %d failed: %s != %v (expected)", i+1, result, test.args)
+		}
+	}
+}
+
+func TestValidateOptionsFor(t *testing.T) {
+	type test struct {
+		entry   *AddTestDeleteEntry
+		create  *CreateSet
+		expects error
+	}
+
+	const setName = "testset"
+	plain := NewCreateHashNet(setName, ProtocolFamilyDefault, 0, 0, 0, false, false, false)
+	full := NewCreateHashNet(setName, ProtocolFamilyDefault, 0, 0, 300, true, true, true)
+	withOptions := func(options func(c *AddTestDeleteEntry)) *AddTestDeleteEntry {
+		out := NewAddEntry(setName, set.SetTypeHashNet, "10.0.0.0/8")
+		options(out)
+		return out
+	}
+
+	tests := []test{
+		{withOptions(func(c *AddTestDeleteEntry) {}), plain, nil},
+		{withOptions(func(c *AddTestDeleteEntry) { c.NoMatch = true }), plain, nil},
+		{withOptions(func(c *AddTestDeleteEntry) { c.Timeout = 10; c.Comment = "c"; c.Packets = 1; c.SKBQueue = 1 }), full, nil},
+		{withOptions(func(c *AddTestDeleteEntry) { c.Timeout = 10 }), plain, liberrors.ErrIPSetOptionNotSupported},
+		{withOptions(func(c *AddTestDeleteEntry) { c.Permanent = true }), plain, liberrors.ErrIPSetOptionNotSupported},
+		{withOptions(func(c *AddTestDeleteEntry) { c.Comment = "c" }), plain, liberrors.ErrIPSetOptionNotSupported},
+		{withOptions(func(c *AddTestDeleteEntry) { c.Bytes = 1 }), plain, liberrors.ErrIPSetOptionNotSupported},
+		{withOptions(func(c *AddTestDeleteEntry) { c.SKBPrio = "1:1" }), plain, liberrors.ErrIPSetOptionNotSupported},
+		{NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), plain, liberrors.ErrIPSetCommandIsInvalid},
+		{NewDeleteEntry(setName, set.SetTypeHashNet, "10.0.0.0/8"), plain, nil},
+	}
+
+	nomatch := NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")
+	nomatch.NoMatch = true
+	tests = append(tests, test{nomatch, NewCreateHashIP(setName, ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), liberrors.ErrIPSetOptionNotSupported})
+
+	for i, test := range tests {
+		if err := test.entry.ValidateOptionsFor(test.create); !errors.Is(err, test.expects) || (err == nil) != (test.expects == nil) {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, err, test.expects)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	type test struct {
+		command  *AddTestDeleteEntry
+		output   string
+		err      error
+		contains bool
+		expects  error
+	}
+
+	const setName = "testset"
+	exitErr := errors.New("exit status 1")
+	tests := []test{
+		{NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1"), "1.1.1.1 is in set testset.\n", nil, true, nil},
+		{NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1"), "ipset v7.1: 1.1.1.1 is NOT in set testset.\n", exitErr, false, nil},
+		{NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1"), "ipset v7.1: The set with the given name does not exist\n", exitErr, false, liberrors.ErrIPSetNoSuchSet},
+		{NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1"), "ipset v7.1: Kernel error received: Operation not permitted\n", exitErr, false, liberrors.ErrIPSetPermissionDenied},
+		{NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1"), "", nil, false, liberrors.ErrIPSetCommandIsInvalid},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: []string{test.output}, Errors: []error{test.err}}
+		contains, err := test.command.ContainsWith(context.Background(), executor)
+
+		if contains != test.contains {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, contains, test.contains)
+		} else if test.expects == nil && err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+		} else if test.expects != nil && !errors.Is(err, test.expects) {
+			t.Errorf("expectation %d failed: %v does not match %v (expected)", i+1, err, test.expects)
+		}
+	}
+}
+
+func TestAddTestDeleteValidate(t *testing.T) {
+	type test struct {
+		command *AddTestDeleteEntry
+		expects error
+		message string
+	}
+
+	const setName = "testset"
+	tests := []test{
+		{NewAddEntry(setName, set.SetTypeHashIPPort, "1.1.1.1,tcp:80"), nil, ""},
+		{NewTestEntry(setName, set.SetTypeBitmapPort, "tcp:65535"), nil, ""},
+		{&AddTestDeleteEntry{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1", Timeout: 10, SKBQueue: 3}, nil, ""},
+		{NewAddEntry(setName, set.SetTypeHashIPPort, "1.1.1.1,tcp:70000"), liberrors.ErrIPSetCommandIsInvalid, "port 70000 out of range"},
+		{NewAddEntry(setName, set.SetTypeHashIPPortNet, "::1,80,::/64"), nil, ""},
+		{NewAddEntry(setName, set.SetTypeHashNetPort, "10.0.0.0/8,udp:1-70000"), liberrors.ErrIPSetCommandIsInvalid, "port 70000 out of range"},
+		{NewAddEntry(setName, set.SetTypeBitmapPort, "80-65536"), liberrors.ErrIPSetCommandIsInvalid, "port 65536 out of range"},
+		{NewAddEntry(setName, set.SetTypeHashIP, "1.1.1"), liberrors.ErrIPSetCommandIsInvalid, `entry "1.1.1" is invalid for sets of type hash:ip`},
+		{NewAddEntry(setName, set.SetTypeHashIP, ""), liberrors.ErrIPSetCommandIsInvalid, "entry is missing"},
+		{NewAddEntry("", set.SetTypeHashIP, "1.1.1.1"), liberrors.ErrIPSetCommandIsInvalid, "name is missing"},
+		{NewAddEntry(setName, set.SetTypeUnsupported, "1.1.1.1"), liberrors.ErrIPSetCommandIsInvalid, "is not supported"},
+		{&AddTestDeleteEntry{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1", Timeout: -1}, liberrors.ErrIPSetCommandIsInvalid, "timeout -1 is negative"},
+		{&AddTestDeleteEntry{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1", SKBQueue: 65536}, liberrors.ErrIPSetCommandIsInvalid, "skbqueue 65536 out of range"},
+		{&AddTestDeleteEntry{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1", SKBPrio: "1"}, liberrors.ErrIPSetCommandIsInvalid, `skbprio "1"`},
+		{&AddTestDeleteEntry{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1", NoMatch: true}, liberrors.ErrIPSetOptionNotSupported, "nomatch"},
+		{&AddTestDeleteEntry{Command: CommandNameFlush, Name: setName, Type: set.SetTypeHashIP, Entry: "1.1.1.1"}, liberrors.ErrIPSetCommandIsInvalid, `command "flush" is not add or del or test`},
+	}
+
+	for i, test := range tests {
+		err := test.command.Validate()
+		if test.expects == nil && err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+		} else if test.expects != nil && (!errors.Is(err, test.expects) || !strings.Contains(fmt.Sprintf("%v", err), test.message)) {
+			t.Errorf("expectation %d failed: %v does not match %v: %s (expected)", i+1, err, test.expects, test.message)
+		} else if executor := (&utilitiestest.Executor{}); test.expects != nil {
+			if err := test.command.RunWith(context.Background(), executor); !errors.Is(err, test.expects) || len(executor.Calls) > 0 {
+				t.Errorf("expectation %d failed: invalid command should not run", i+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// Batch accumulates create, add, delete, flush and destroy commands
+// and runs all of them with a single ipset restore invocation.
+type Batch struct {
+	commands []Command
+
+	// Global options.
+	Exist bool // Run all commands with -exist (ex.: existing entries are refreshed instead of failing).
+}
+
+// BatchError describes the failure of a command included in a batch.
+type BatchError struct {
+	Line int      // Line of the failed command, starting from 1.
+	Args []string // Arguments of the failed command.
+	Err  error
+}
+
+// Error returns a description of e.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch line %d (%s): %v", e.Line, strings.Join(e.Args, " "), e.Err)
+}
+
+// Unwrap returns the error returned by ipset for the failed command.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// NewBatch returns an empty batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Create appends a create command to b.
+func (b *Batch) Create(c *CreateSet) *Batch {
+	b.commands = append(b.commands, c)
+	return b
+}
+
+// Entry appends an add, delete or test entry command to b.
+func (b *Batch) Entry(c *AddTestDeleteEntry) *Batch {
+	b.commands = append(b.commands, c)
+	return b
+}
+
+// Flush appends a flush command to b.
+func (b *Batch) Flush(c *FlushSet) *Batch {
+	b.commands = append(b.commands, c)
+	return b
+}
+
+// Destroy appends a destroy command to b.
+func (b *Batch) Destroy(c *DestroySet) *Batch {
+	b.commands = append(b.commands, c)
+	return b
+}
+
+// Restore appends to b the commands that create each set of sets and add its entries.
+func (b *Batch) Restore(sets ...SavedSet) *Batch {
+	for _, s := range sets {
+		b.Create(s.Create)
+		for _, entry := range s.Entries {
+			b.Entry(entry)
+		}
+	}
+	return b
+}
+
+// Len returns the number of commands accumulated by b.
+func (b *Batch) Len() int {
+	return len(b.commands)
+}
+
+// TranslateToIPSetRestoreInput returns the input of ipset restore for all commands of b, one command per line.
+// An error is returned if any command fails validation, or if entries of sets
+// created by b use options that are not supported by their sets.
+func (b *Batch) TranslateToIPSetRestoreInput() (string, error) {
+	var out strings.Builder
+	creates := map[string]*CreateSet{}
+	for i, c := range b.commands {
+		args := c.TranslateToIPSetArgs()
+		if err := c.Validate(); err != nil {
+			return "", &BatchError{Line: i + 1, Args: args, Err: err}
+		} else if len(args) == 0 || !c.IncludesMandatoryOptions() {
+			return "", &BatchError{Line: i + 1, Args: args, Err: liberrors.ErrIPSetCommandIsInvalid}
+		}
+
+		switch c := c.(type) {
+		case *CreateSet:
+			creates[c.Name] = c
+		case *AddTestDeleteEntry:
+			if create, found := creates[c.Name]; found {
+				if err := c.ValidateOptionsFor(create); err != nil {
+					return "", &BatchError{Line: i + 1, Args: args, Err: err}
+				}
+			}
+		}
+
+		out.WriteString(restoreLine(args))
+		out.WriteString("\n")
+	}
+
+	return out.String(), nil
+}
+
+// Run executes all commands of a Batch.
+func (b *Batch) Run() error {
+	return b.RunContext(context.Background())
+}
+
+// RunContext executes all commands of a Batch; ipset is killed if ctx is done before it exits.
+func (b *Batch) RunContext(ctx context.Context) error {
+	return b.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes all commands of a Batch using a given executor.
+// Commands are run with a single ipset restore invocation if executor implements utilities.InputExecutor,
+// otherwise they are run one by one; in both cases, execution stops at the first failed command,
+// which is reported by a BatchError.
+func (b *Batch) RunWith(ctx context.Context, executor utilities.Executor) error {
+	input, err := b.TranslateToIPSetRestoreInput()
+	if err != nil {
+		return err
+	} else if len(b.commands) == 0 {
+		return nil
+	}
+
+	inputExecutor, ok := executor.(utilities.InputExecutor)
+	if !ok {
+		for i, c := range b.commands {
+			args := c.TranslateToIPSetArgs()
+			if b.Exist && !containsString(args, "-exist") {
+				args = append(args, existOption(true)...)
+			}
+
+			if out, err := utilities.RunIPSetWith(ctx, executor, args...); err != nil {
+				return &BatchError{Line: i + 1, Args: c.TranslateToIPSetArgs(), Err: out.Error}
+			}
+		}
+
+		return nil
+	}
+
+	args := append([]string{"restore"}, existOption(b.Exist)...)
+	if out, err := utilities.RunIPSetWithInput(ctx, inputExecutor, strings.NewReader(input), args...); err != nil {
+		if line := restoreErrorLine(out.Out); line > 0 && line <= len(b.commands) {
+			return &BatchError{Line: line, Args: b.commands[line-1].TranslateToIPSetArgs(), Err: out.Error}
+		}
+		return out.Error
+	}
+
+	return nil
+}
+
+// Support.
+
+// restoreLineMatch matches the line reported by ipset restore when it aborts.
+var restoreLineMatch = regexp.MustCompile(`Error in line ([0-9]+):`)
+
+// restoreErrorLine returns the line reported by ipset restore in a given output, or 0 if not found.
+func restoreErrorLine(out string) int {
+	match := restoreLineMatch.FindStringSubmatch(out)
+	if match == nil {
+		return 0
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
+
+// restoreLine returns a line of ipset restore input, quoting arguments that include white spaces or quotes.
+func restoreLine(args []string) string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			out[i] = strconv.Quote(arg)
+		} else {
+			out[i] = arg
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// containsString returns true if in contains s.
+func containsString(in []string, s string) bool {
+	for _, item := range in {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestBatchRestoreInput(t *testing.T) {
+	const setName = "testset"
+	b := NewBatch().
+		Create(NewCreateHashIP(setName, ProtocolFamilyINet, 0, 0, 0, 0, false, false, false)).
+		Entry(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")).
+		Entry(NewDeleteEntry(setName, set.SetTypeHashIP, "2.2.2.2")).
+		Flush(NewFlushSet(setName)).
+		Destroy(NewDestroySet(setName))
+
+	expects := "create testset hash:ip family inet\n" +
+		"add testset 1.1.1.1\n" +
+		"del testset 2.2.2.2\n" +
+		"flush testset\n" +
+		"destroy testset\n"
+
+	if input, err := b.TranslateToIPSetRestoreInput(); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	} else if input != expects {
+		t.Errorf("unexpected restore input: %s != %s (expected)", input, expects)
+	}
+
+	b.Entry(NewAddEntry(setName, set.SetTypeHashIP, "invalid"))
+	var batchErr *BatchError
+	if _, err := b.TranslateToIPSetRestoreInput(); !errors.As(err, &batchErr) {
+		t.Errorf("expectation failed: invalid command should return a BatchError, received %v", err)
+	} else if batchErr.Line != 6 || !errors.Is(err, liberrors.ErrIPSetCommandIsInvalid) {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+}
+
+func TestBatchRestoreInputEntryOptions(t *testing.T) {
+	const setName = "testset"
+	entry := NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")
+	entry.Comment = "a comment"
+
+	b := NewBatch().
+		Create(NewCreateHashIP(setName, ProtocolFamilyDefault, 0, 0, 0, 0, false, true, false)).
+		Entry(entry)
+
+	expects := "create testset hash:ip comment\n" +
+		"add testset 1.1.1.1 comment \"a comment\"\n"
+	if input, err := b.TranslateToIPSetRestoreInput(); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	} else if input != expects {
+		t.Errorf("unexpected restore input: %s != %s (expected)", input, expects)
+	}
+
+	// Options are checked only against sets created by the batch.
+	entry = NewAddEntry("otherset", set.SetTypeHashIP, "1.1.1.1")
+	entry.Timeout = 10
+	if _, err := NewBatch().Entry(entry).TranslateToIPSetRestoreInput(); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	}
+
+	entry = NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")
+	entry.Timeout = 10
+	b.Entry(entry)
+
+	var batchErr *BatchError
+	if _, err := b.TranslateToIPSetRestoreInput(); !errors.As(err, &batchErr) {
+		t.Errorf("expectation failed: unsupported option should return a BatchError, received %v", err)
+	} else if batchErr.Line != 3 || !errors.Is(err, liberrors.ErrIPSetOptionNotSupported) {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+}
+
+func TestBatchRunWith(t *testing.T) {
+	const setName = "testset"
+	b := NewBatch().
+		Entry(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")).
+		Entry(NewAddEntry(setName, set.SetTypeHashIP, "2.2.2.2"))
+
+	// Single restore invocation.
+	executor := &utilitiestest.InputExecutor{}
+	if err := b.RunWith(context.Background(), executor); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	}
+
+	result := fmt.Sprintf("%v %q", executor.Calls, executor.Inputs)
+	expects := fmt.Sprintf("%v %q", [][]string{{"restore"}}, []string{"add testset 1.1.1.1\nadd testset 2.2.2.2\n"})
+	if result != expects {
+		t.Errorf("unexpected restore invocation: %s != %s (expected)", result, expects)
+	}
+
+	// Restore aborts at line 2.
+	executor = &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{
+		Outputs: []string{"ipset v7.1: Error in line 2: Element cannot be added to the set: it's already added\n"},
+		Errors:  []error{errors.New("exit status 1")},
+	}}
+
+	var batchErr *BatchError
+	if err := b.RunWith(context.Background(), executor); !errors.As(err, &batchErr) {
+		t.Errorf("expectation failed: batch should return a BatchError, received %v", err)
+	} else if result := fmt.Sprintf("%d %v", batchErr.Line, batchErr.Args); result != "2 [add testset 2.2.2.2]" {
+		t.Errorf("unexpected batch error: %s", result)
+	}
+
+	// Executors that cannot read input run commands one by one.
+	sequential := &utilitiestest.Executor{Outputs: []string{"", "ipset v7.1: Element cannot be added to the set: it's already added\n"}, Errors: []error{nil, errors.New("exit status 1")}}
+	if err := b.RunWith(context.Background(), sequential); !errors.As(err, &batchErr) {
+		t.Errorf("expectation failed: batch should return a BatchError, received %v", err)
+	} else if batchErr.Line != 2 || len(sequential.Calls) != 2 {
+		t.Errorf("unexpected batch error: %v (%d calls)", err, len(sequential.Calls))
+	}
+}
+
+func TestBatchRunWithExist(t *testing.T) {
+	const setName = "testset"
+	entry := NewAddEntry(setName, set.SetTypeHashIP, "2.2.2.2")
+	entry.Exist = true
+
+	b := NewBatch().
+		Entry(NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1")).
+		Entry(entry)
+
+	// Client default.
+	executor := &utilitiestest.InputExecutor{}
+	client := &Client{Executor: executor, Exist: true}
+	if err := client.RunBatch(context.Background(), b); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	} else if result := fmt.Sprintf("%v", executor.Calls); result != "[[restore -exist]]" {
+		t.Errorf("unexpected restore invocation: %s", result)
+	} else if b.Exist {
+		t.Error("expectation failed: client should not change the batch")
+	}
+
+	// Batch without -exist.
+	executor = &utilitiestest.InputExecutor{}
+	if err := NewClient(executor).RunBatch(context.Background(), b); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	} else if result := fmt.Sprintf("%v %q", executor.Calls, executor.Inputs); result != `[[restore]] ["add testset 1.1.1.1\nadd testset 2.2.2.2 -exist\n"]` {
+		t.Errorf("unexpected restore invocation: %s", result)
+	}
+
+	// Executors that cannot read input run each command with -exist.
+	b.Exist = true
+	sequential := &utilitiestest.Executor{}
+	if err := b.RunWith(context.Background(), sequential); err != nil {
+		t.Errorf("expectation failed: batch returned an error: %v", err)
+	} else if result := fmt.Sprintf("%v", sequential.Calls); result != "[[add testset 1.1.1.1 -exist] [add testset 2.2.2.2 -exist]]" {
+		t.Errorf("unexpected executor calls: %s", result)
+	}
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// DefaultCapacityThreshold is the default fraction of maxelem above which CheckCapacity reports a set.
+const DefaultCapacityThreshold = 0.8
+
+// minHashSize is the minimum hashsize of hash sets, as defined by the kernel.
+const minHashSize = 64
+
+// Parameters of PlanCapacity.
+const (
+	capacityLoad    = 2    // Average number of entries of each bucket.
+	maxElementsStep = 1024 // maxelem is rounded up to a multiple of maxElementsStep.
+)
+
+// CapacityRequest describes the expected contents of a hash set, planned by PlanCapacity.
+type CapacityRequest struct {
+	Type    set.SetType
+	Family  ProtocolFamily
+	Entries int     // Expected number of entries.
+	Growth  float64 // Expected growth of entries (ex.: 1.5 for 50% more entries); values lower than 1 are treated as 1.
+
+	// Extensions of entries, which increase memory.
+	Timeout  bool
+	Counters bool
+	Comments bool
+	SKBInfo  bool
+}
+
+// CapacityPlan describes the recommended options of a hash set and its estimated kernel memory.
+type CapacityPlan struct {
+	HashSize    int
+	MaxElements int
+	BucketSize  int // Requires ipset 7.11 or later; set it to 0 for older versions, which use the kernel default.
+
+	MemSize     int // Estimated memory with the expected number of entries, in bytes.
+	FullMemSize int // Estimated memory with MaxElements entries, in bytes.
+}
+
+// CapacityWarning describes a set whose number of entries is approaching its maximum.
+type CapacityWarning struct {
+	Name        string
+	NumEntries  int
+	MaxElements int // maxelem of hash sets, size of list sets.
+	Usage       float64
+}
+
+// PlanCapacity returns the options of a hash set that fits the expected number of entries after their growth:
+// maxelem leaves room for all of them, while hashsize keeps buckets short, and bucketsize is large enough
+// for the longest bucket expected with that load, so that the hash is rarely resized.
+// Memory estimates are approximate, since the layout of sets depends on the kernel.
+func PlanCapacity(request CapacityRequest) (*CapacityPlan, error) {
+	if !isHashSetType(request.Type) {
+		return nil, fmt.Errorf("%w: capacity can be planned only for hash sets, not for sets of type %v", liberrors.ErrIPSetOptionNotSupported, request.Type)
+	} else if request.Entries < 0 {
+		return nil, fmt.Errorf("%w: expected entries %d is invalid", liberrors.ErrIPSetCommandIsInvalid, request.Entries)
+	}
+
+	growth := request.Growth
+	if growth < 1 || math.IsNaN(growth) {
+		growth = 1
+	}
+
+	target := int(math.Ceil(float64(request.Entries) * growth))
+	out := &CapacityPlan{}
+	out.MaxElements = (target + maxElementsStep - 1) / maxElementsStep * maxElementsStep
+	if out.MaxElements == 0 {
+		out.MaxElements = maxElementsStep
+	}
+
+	out.HashSize = minHashSize
+	for out.HashSize*capacityLoad < target {
+		out.HashSize *= 2
+	}
+	out.BucketSize = planBucketSize(out.HashSize, target)
+
+	size := entrySize(request)
+	out.MemSize = estimateMemSize(out.HashSize, request.Entries, size)
+	out.FullMemSize = estimateMemSize(out.HashSize, out.MaxElements, size)
+	return out, nil
+}
+
+// Options returns the create options that apply p, to be used with NewCreate.
+func (p *CapacityPlan) Options() []CreateOption {
+	out := []CreateOption{WithHashSize(p.HashSize), WithMaxElem(p.MaxElements)}
+	if p.BucketSize != 0 {
+		out = append(out, WithBucketSize(p.BucketSize))
+	}
+	return out
+}
+
+// Apply sets hashsize, maxelem and bucketsize of a create command of a hash set to the values of p.
+func (p *CapacityPlan) Apply(c *CreateSet) {
+	c.HashSize = p.HashSize
+	c.MaxElements = p.MaxElements
+	c.BucketSize = p.BucketSize
+}
+
+// String returns a description of w.
+func (w CapacityWarning) String() string {
+	return fmt.Sprintf(`set "%s" uses %.0f%% of its capacity (%d of %d entries)`, w.Name, w.Usage*100, w.NumEntries, w.MaxElements)
+}
+
+// CapacityWarnings returns a warning for each set whose number of entries is at least threshold times its maximum;
+// sets without a maximum (bitmap sets) are ignored. Headers of sets listed with Terse are enough.
+func CapacityWarnings(sets []SetInfo, threshold float64) []CapacityWarning {
+	out := []CapacityWarning{}
+	for _, info := range sets {
+		capacity := info.Header.MaxElements
+		if info.Type == set.SetTypeListSet {
+			capacity = info.Header.Size
+		}
+		if capacity <= 0 {
+			continue
+		}
+
+		if usage := float64(info.Header.NumEntries) / float64(capacity); usage >= threshold {
+			out = append(out, CapacityWarning{Name: info.Name, NumEntries: info.Header.NumEntries, MaxElements: capacity, Usage: usage})
+		}
+	}
+	return out
+}
+
+// CheckCapacity lists the headers of all sets, and returns a warning for each set whose number of entries
+// is at least threshold times its maximum (ex.: DefaultCapacityThreshold).
+func (c *Client) CheckCapacity(ctx context.Context, threshold float64) ([]CapacityWarning, error) {
+	list := NewListSet("")
+	list.Terse = true
+
+	sets, err := list.SetsWith(ctx, utilities.ExecutorOrDefault(c.Executor))
+	if err != nil {
+		return nil, err
+	}
+	return CapacityWarnings(sets, threshold), nil
+}
+
+// Support.
+
+// Approximate sizes of kernel structures of hash sets, in bytes.
+const (
+	setOverhead    = 256 // Set and hash table headers.
+	bucketPointer  = 8
+	bucketOverhead = 32
+	bucketStep     = 2 // Buckets grow by this number of entries.
+	timeoutSize    = 8
+	countersSize   = 16
+	commentSize    = 8 // Pointer to the comment, allocated separately.
+	skbInfoSize    = 16
+)
+
+// elementSizes defines the size of entries of each hash set type, for inet and inet6 sets.
+var elementSizes = map[set.SetType][2]int{
+	set.SetTypeHashIP:         {4, 16},
+	set.SetTypeHashMAC:        {8, 8},
+	set.SetTypeHashIPMAC:      {12, 24},
+	set.SetTypeHashNet:        {8, 20},
+	set.SetTypeHashNetNet:     {16, 36},
+	set.SetTypeHashIPPort:     {8, 20},
+	set.SetTypeHashNetPort:    {8, 20},
+	set.SetTypeHashIPPortIP:   {12, 36},
+	set.SetTypeHashIPPortNet:  {12, 36},
+	set.SetTypeHashIPMark:     {8, 20},
+	set.SetTypeHashNetPortNet: {16, 40},
+	set.SetTypeHashNetIFace:   {24, 36},
+}
+
+// planBucketSize returns the smallest bucketsize such that, with entries evenly hashed into hashSize buckets,
+// less than one bucket is expected to be longer, up to the maximum bucketsize.
+func planBucketSize(hashSize, entries int) int {
+	load := float64(entries) / float64(hashSize)
+	p := math.Exp(-load) // Probability that a bucket has n entries (Poisson distribution).
+	tail := 1 - p        // Probability that a bucket has more than n entries.
+	for n := 1; n < maxBucketSize; n++ {
+		p *= load / float64(n)
+		tail -= p
+		if n >= minBucketSize && n%bucketStep == 0 && tail*float64(hashSize) < 1 {
+			return n
+		}
+	}
+	return maxBucketSize
+}
+
+// entrySize returns the approximate size of an entry of a set described by request, including its extensions.
+func entrySize(request CapacityRequest) int {
+	sizes := elementSizes[request.Type]
+	out := sizes[0]
+	if request.Family == ProtocolFamilyINet6 {
+		out = sizes[1]
+	}
+
+	if request.Timeout {
+		out += timeoutSize
+	}
+	if request.Counters {
+		out += countersSize
+	}
+	if request.Comments {
+		out += commentSize
+	}
+	if request.SKBInfo {
+		out += skbInfoSize
+	}
+	return (out + 7) / 8 * 8 // Aligned to 8 bytes.
+}
+
+// estimateMemSize returns the approximate memory of a hash set with hashSize buckets and a given number of entries,
+// assuming that entries are evenly distributed.
+func estimateMemSize(hashSize, entries, entrySize int) int {
+	out := setOverhead + hashSize*bucketPointer
+	if entries == 0 {
+		return out
+	}
+
+	used := float64(hashSize) * (1 - math.Exp(-float64(entries)/float64(hashSize))) // Buckets with at least an entry.
+	slots := math.Ceil(float64(entries)/used/bucketStep) * bucketStep
+	return out + int(used*(bucketOverhead+slots*float64(entrySize)))
+}
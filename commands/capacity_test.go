@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestPlanCapacity(t *testing.T) {
+	type test struct {
+		request    CapacityRequest
+		hashSize   int
+		maxElem    int
+		bucketSize int
+		err        error
+	}
+
+	tests := []test{
+		{CapacityRequest{Type: set.SetTypeHashIP, Entries: 0}, 64, 1024, 2, nil},
+		{CapacityRequest{Type: set.SetTypeHashIP, Entries: 100}, 64, 1024, 6, nil},
+		{CapacityRequest{Type: set.SetTypeHashNet, Entries: 100000, Growth: 1.5}, 131072, 150528, 8, nil},
+		{CapacityRequest{Type: set.SetTypeHashIPPort, Family: ProtocolFamilyINet6, Entries: 5000, Growth: 0.5}, 4096, 5120, 8, nil},
+		{CapacityRequest{Type: set.SetTypeBitmapIP, Entries: 100}, 0, 0, 0, liberrors.ErrIPSetOptionNotSupported},
+		{CapacityRequest{Type: set.SetTypeListSet, Entries: 100}, 0, 0, 0, liberrors.ErrIPSetOptionNotSupported},
+		{CapacityRequest{Type: set.SetTypeHashIP, Entries: -1}, 0, 0, 0, liberrors.ErrIPSetCommandIsInvalid},
+	}
+
+	for i, test := range tests {
+		plan, err := PlanCapacity(test.request)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("expectation %d failed: %v != %v (expected)", i+1, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+		} else if plan.HashSize != test.hashSize || plan.MaxElements != test.maxElem || plan.BucketSize != test.bucketSize {
+			t.Errorf("expectation %d failed: hashsize %d, maxelem %d, bucketsize %d != %d, %d, %d (expected)",
+				i+1, plan.HashSize, plan.MaxElements, plan.BucketSize, test.hashSize, test.maxElem, test.bucketSize)
+		} else if plan.MemSize <= 0 || plan.FullMemSize < plan.MemSize {
+			t.Errorf("expectation %d failed: unexpected plan %+v", i+1, plan)
+		}
+	}
+}
+
+func TestPlanCapacityMemSize(t *testing.T) {
+	small, _ := PlanCapacity(CapacityRequest{Type: set.SetTypeHashIP, Entries: 10000})
+	large, _ := PlanCapacity(CapacityRequest{Type: set.SetTypeHashIP, Family: ProtocolFamilyINet6, Entries: 10000})
+	extended, _ := PlanCapacity(CapacityRequest{Type: set.SetTypeHashIP, Family: ProtocolFamilyINet6, Entries: 10000, Timeout: true, Counters: true, Comments: true})
+
+	if !(small.MemSize < large.MemSize && large.MemSize < extended.MemSize) {
+		t.Errorf("expectation failed: memory estimates %d, %d, %d are not increasing", small.MemSize, large.MemSize, extended.MemSize)
+	}
+
+	const expects = "[create planned hash:ip hashsize 8192 maxelem 10240 bucketsize 8]"
+	create, err := NewCreate("planned", set.SetTypeHashIP, small.Options()...)
+	if err != nil {
+		t.Errorf("expectation failed: %v", err)
+	} else if result := fmt.Sprint(create.TranslateToIPSetArgs()); result != expects {
+		t.Errorf("expectation failed: %s != %s (expected)", result, expects)
+	}
+
+	create = NewCreateHashIP("planned", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false)
+	small.Apply(create)
+	if result := fmt.Sprint(create.TranslateToIPSetArgs()); result != expects {
+		t.Errorf("expectation failed: %s != %s (expected)", result, expects)
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	output := `<ipsets>` +
+		`<ipset name="full"><type>hash:ip</type><header><maxelem>1000</maxelem><numentries>950</numentries></header><members></members></ipset>` +
+		`<ipset name="empty"><type>hash:ip</type><header><maxelem>1000</maxelem><numentries>10</numentries></header><members></members></ipset>` +
+		`<ipset name="lists"><type>list:set</type><header><size>8</size><numentries>8</numentries></header><members></members></ipset>` +
+		`<ipset name="ports"><type>bitmap:port</type><header><range>0-1024</range><numentries>1000</numentries></header><members></members></ipset>` +
+		`</ipsets>`
+	executor := &utilitiestest.Executor{Outputs: []string{output}}
+
+	warnings, err := NewClient(executor).CheckCapacity(context.Background(), DefaultCapacityThreshold)
+	expects := `[set "full" uses 95% of its capacity (950 of 1000 entries) set "lists" uses 100% of its capacity (8 of 8 entries)]`
+	if err != nil {
+		t.Errorf("expectation failed: %v", err)
+	} else if result := fmt.Sprint(warnings); result != expects {
+		t.Errorf("expectation failed: %s != %s (expected)", result, expects)
+	} else if result := fmt.Sprint(executor.Calls); result != "[[list -terse -output xml]]" {
+		t.Errorf("expectation failed: %s != [[list -terse -output xml]] (expected)", result)
+	}
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// Client runs operations that combine multiple ipset commands, using the same executor for all of them.
+type Client struct {
+	Executor utilities.Executor // Defaults to utilities.DefaultExecutor if nil.
+	Exist    bool               // Run batches with -exist, so that operations can be repeated.
+}
+
+// NewClient returns a client that runs commands using a given executor.
+func NewClient(executor utilities.Executor) *Client {
+	return &Client{Executor: executor}
+}
+
+// RunBatch executes all commands of b using the executor of c; b is run with -exist if c.Exist is true.
+func (c *Client) RunBatch(ctx context.Context, b *Batch) error {
+	run := *b
+	run.Exist = b.Exist || c.Exist
+	return run.RunWith(ctx, utilities.ExecutorOrDefault(c.Executor))
+}
+
+// ReplaceSetContents atomically replaces all entries of set name with entries.
+// A temporary set with the same type and options of the live set is created and filled with entries,
+// then it is swapped with the live set and destroyed. If any step before the swap fails, the temporary
+// set is destroyed and the live set is left untouched.
+// The temporary set is always created without -exist: if a set with its name already exists,
+// it is not reused and the operation fails.
+func (c *Client) ReplaceSetContents(ctx context.Context, name string, entries []string) error {
+	list := NewListSet(name)
+	list.Terse = true
+	info, err := list.InfoWith(ctx, utilities.ExecutorOrDefault(c.Executor))
+	if err != nil {
+		return err
+	}
+
+	return c.replaceSet(ctx, name, *NewCreateFromInfo(name, info), entries)
+}
+
+// Support.
+
+// replaceSet replaces set name with a set created by create (whose name is ignored) and filled with entries,
+// through a temporary set that is swapped with set name and then destroyed, as described by ReplaceSetContents.
+func (c *Client) replaceSet(ctx context.Context, name string, create CreateSet, entries []string) error {
+	executor := utilities.ExecutorOrDefault(c.Executor)
+
+	tempName, err := temporarySetName(name)
+	if err != nil {
+		return err
+	}
+
+	create.Name = tempName
+	create.Exist = false
+	batch := NewBatch().Create(&create)
+	for _, entry := range entries {
+		add := NewAddEntry(tempName, create.Type, entry)
+		add.Exist = c.Exist // Repeated entries are accepted only with -exist.
+		batch.Entry(add)
+	}
+
+	// Validate all commands before changing anything.
+	if _, err := batch.TranslateToIPSetRestoreInput(); err != nil {
+		return err
+	}
+
+	if err := batch.RunWith(ctx, executor); err != nil {
+		var batchErr *BatchError
+		if errors.Is(err, liberrors.ErrIPSetSetExists) {
+			return fmt.Errorf(`temporary set "%s" already exists: %w`, tempName, err) // Owned by someone else.
+		} else if !errors.As(err, &batchErr) || batchErr.Line > 1 {
+			c.destroyTemporarySet(tempName) // The temporary set may have been created.
+		}
+		return err
+	}
+
+	if err := NewSwapSets(name, tempName).swap(ctx, executor); err != nil {
+		c.destroyTemporarySet(tempName)
+		return err
+	}
+
+	if err := NewDestroySet(tempName).RunWith(ctx, executor); err != nil {
+		return fmt.Errorf(`set "%s" was replaced, but temporary set "%s" cannot be destroyed: %w`, name, tempName, err)
+	}
+	return nil
+}
+
+// destroyTemporarySet destroys a temporary set after a failure, ignoring errors.
+// The set is destroyed even if the context of the failed operation is done.
+func (c *Client) destroyTemporarySet(name string) {
+	NewDestroySet(name).RunWith(context.Background(), utilities.ExecutorOrDefault(c.Executor))
+}
+
+// temporarySetName returns a random name for a temporary copy of set name, not longer than MaxSetNameLength.
+// The suffix is read from crypto/rand, so that names differ across processes.
+func temporarySetName(name string) (string, error) {
+	random := make([]byte, 3)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("cannot generate the name of a temporary set: %w", err)
+	}
+
+	suffix := "-" + hex.EncodeToString(random)
+	if len(name)+len(suffix) > MaxSetNameLength {
+		name = name[:MaxSetNameLength-len(suffix)]
+	}
+	return name + suffix, nil
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+const fakeLiveSetOutput = `<ipsets><ipset name="live"><type>hash:ip</type><revision>4</revision>
+<header><family>inet</family><hashsize>1024</hashsize><maxelem>65536</maxelem><bucketsize>12</bucketsize><timeout>300</timeout><counters/><forceadd/><memsize>100</memsize><references>1</references><numentries>1</numentries></header>
+<members></members></ipset></ipsets>`
+
+func TestClientReplaceSetContents(t *testing.T) {
+	executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: []string{fakeLiveSetOutput, ""}}}
+	client := NewClient(executor)
+
+	if err := client.ReplaceSetContents(context.Background(), "live", []string{"1.1.1.1", "2.2.2.2"}); err != nil {
+		t.Errorf("expectation failed: replace returned an error: %v", err)
+		return
+	}
+
+	if len(executor.Calls) != 4 || len(executor.Inputs) != 1 {
+		t.Errorf("unexpected executor calls: %v", executor.Calls)
+		return
+	}
+
+	tempName := executor.Calls[2][2]
+	result := fmt.Sprintf("%v", executor.Calls)
+	expects := fmt.Sprintf("%v", [][]string{
+		{"list", "live", "-terse", "-output", "xml"},
+		{"restore"},
+		{"swap", "live", tempName},
+		{"destroy", tempName},
+	})
+	if result != expects {
+		t.Errorf("unexpected executor calls: %s != %s (expected)", result, expects)
+	}
+
+	expectsInput := fmt.Sprintf("create %s hash:ip family inet hashsize 1024 maxelem 65536 bucketsize 12 timeout 300 counters forceadd\n", tempName) +
+		fmt.Sprintf("add %s 1.1.1.1\n", tempName) +
+		fmt.Sprintf("add %s 2.2.2.2\n", tempName)
+	if executor.Inputs[0] != expectsInput {
+		t.Errorf("unexpected restore input: %s != %s (expected)", executor.Inputs[0], expectsInput)
+	}
+}
+
+func TestClientReplaceSetContentsExist(t *testing.T) {
+	executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: []string{fakeLiveSetOutput, ""}}}
+	client := NewClient(executor)
+	client.Exist = true
+
+	if err := client.ReplaceSetContents(context.Background(), "live", []string{"1.1.1.1", "1.1.1.1"}); err != nil {
+		t.Errorf("expectation failed: replace returned an error: %v", err)
+		return
+	}
+
+	// The temporary set must never be created with -exist, which would reuse a set with the same name.
+	tempName := executor.Calls[2][2]
+	expectsInput := fmt.Sprintf("create %s hash:ip family inet hashsize 1024 maxelem 65536 bucketsize 12 timeout 300 counters forceadd\n", tempName) +
+		fmt.Sprintf("add %s 1.1.1.1 -exist\n", tempName) +
+		fmt.Sprintf("add %s 1.1.1.1 -exist\n", tempName)
+	if result := fmt.Sprint(executor.Calls[1]); result != "[restore]" {
+		t.Errorf("unexpected restore call: %s != [restore] (expected)", result)
+	} else if executor.Inputs[0] != expectsInput {
+		t.Errorf("unexpected restore input: %s != %s (expected)", executor.Inputs[0], expectsInput)
+	}
+}
+
+func TestClientReplaceSetContentsFailures(t *testing.T) {
+	type test struct {
+		entries  []string
+		outputs  []string
+		errors   []error
+		commands string // Commands run by the executor.
+	}
+
+	failure := errors.New("exit status 1")
+	tests := []test{
+		// Invalid entries: nothing is created.
+		{[]string{"1.1.1.1", "invalid"}, []string{fakeLiveSetOutput}, nil, "[list]"},
+		// Temporary set cannot be created.
+		{[]string{"1.1.1.1"}, []string{fakeLiveSetOutput, "ipset v7.1: Error in line 1: Set cannot be created\n"}, []error{nil, failure}, "[list restore]"},
+		// Entries cannot be added: temporary set is destroyed.
+		{[]string{"1.1.1.1"}, []string{fakeLiveSetOutput, "ipset v7.1: Error in line 2: Hash is full\n", ""}, []error{nil, failure, nil}, "[list restore destroy]"},
+		// Swap fails: temporary set is destroyed.
+		{[]string{"1.1.1.1"}, []string{fakeLiveSetOutput, "", "ipset v7.1: Kernel error\n", ""}, []error{nil, nil, failure, nil}, "[list restore swap destroy]"},
+		// Temporary set already exists: it is not destroyed.
+		{[]string{"1.1.1.1"}, []string{fakeLiveSetOutput, "ipset v7.1: Error in line 1: Set cannot be created: set with the same name already exists\n"}, []error{nil, failure}, "[list restore]"},
+		// Live set does not exist.
+		{[]string{"1.1.1.1"}, []string{"ipset v7.1: The set with the given name does not exist\n"}, []error{failure}, "[list]"},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: test.outputs, Errors: test.errors}}
+		if err := NewClient(executor).ReplaceSetContents(context.Background(), "live", test.entries); err == nil {
+			t.Errorf("expectation %d failed: replace should return an error", i+1)
+		}
+
+		commands := []string{}
+		for _, call := range executor.Calls {
+			commands = append(commands, call[0])
+		}
+		if result := fmt.Sprintf("%v", commands); result != test.commands {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.commands)
+		}
+
+		for _, call := range executor.Calls {
+			if call[0] == "destroy" && call[1] == "live" {
+				t.Errorf("expectation %d failed: live set was destroyed", i+1)
+			}
+		}
+	}
+}
+
+func TestTemporarySetName(t *testing.T) {
+	for _, name := range []string{"live", strings.Repeat("a", MaxSetNameLength)} {
+		tempName, err := temporarySetName(name)
+		if err != nil {
+			t.Errorf("expectation failed: %v", err)
+		} else if len(tempName) > MaxSetNameLength || tempName == name || tempName[:3] != name[:3] {
+			t.Errorf("unexpected temporary name for %s: %s", name, tempName)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 // Package commands contains definitions of all ipset commands supported by go-ipset.
 package commands
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+)
 
 // CommandName defines a command of ipset supported by go-ipset.
 type CommandName int
@@ -15,8 +21,14 @@ const (
 	CommandNameFlush
 	CommandNameDestroy
 	CommandNameExists
+	CommandNameSave
+	CommandNameSwap
+	CommandNameRename
 )
 
+// MaxSetNameLength is the maximum length of set names accepted by ipset.
+const MaxSetNameLength = 31
+
 // String returns the underlying command name of a given CommandName c.
 func (c CommandName) String() string {
 	switch c {
@@ -36,6 +48,12 @@ func (c CommandName) String() string {
 		return "destroy"
 	case CommandNameExists:
 		return "-L"
+	case CommandNameSave:
+		return "save"
+	case CommandNameSwap:
+		return "swap"
+	case CommandNameRename:
+		return "rename"
 
 	default:
 		return "" // Unsupported command
@@ -68,6 +86,37 @@ type Command interface {
 
 	// IncludesMandatoryOptions returns true if mandatory options of the command are defined.
 	IncludesMandatoryOptions() bool
+
+	// Validate returns an error describing the first missing or invalid option of the command,
+	// wrapping ErrIPSetCommandIsInvalid or ErrIPSetOptionNotSupported; commands refuse to run if it fails.
+	Validate() error
+}
+
+// validateCommandName returns an error if c is not one of the given names.
+func validateCommandName(c CommandName, names ...CommandName) error {
+	for _, name := range names {
+		if c == name {
+			return nil
+		}
+	}
+
+	expected := make([]string, len(names))
+	for i, name := range names {
+		expected[i] = name.String()
+	}
+	return fmt.Errorf(`%w: command "%s" is not %s`, liberrors.ErrIPSetCommandIsInvalid, c.String(), strings.Join(expected, " or "))
+}
+
+// validateSetName returns an error if a set name, used as a given field of a command,
+// is empty or longer than MaxSetNameLength characters.
+func validateSetName(field, name string) error {
+	if name = strings.Trim(name, " \n"); name == "" {
+		return fmt.Errorf("%w: %s is missing", liberrors.ErrIPSetCommandIsInvalid, field)
+	} else if len(name) > MaxSetNameLength {
+		return fmt.Errorf(`%w: %s "%s" is longer than %d characters`, liberrors.ErrIPSetCommandIsInvalid, field, name, MaxSetNameLength)
+	} else {
+		return nil
+	}
 }
 
 // XML output support.
@@ -76,12 +125,40 @@ type OxmlIPSets struct {
 	Sets    []OxmlIPSet `xml:"ipset"`
 }
 type OxmlIPSet struct {
-	XMLName xml.Name     `xml:"ipset"`
-	Name    string       `xml:"name,attr"`
-	Type    string       `xml:"type,attr"`
-	Members []OxmlMember `xml:"members>member"`
+	XMLName  xml.Name     `xml:"ipset"`
+	Name     string       `xml:"name,attr"`
+	Type     string       `xml:"type"`
+	Revision int          `xml:"revision"`
+	Header   OxmlHeader   `xml:"header"`
+	Members  []OxmlMember `xml:"members>member"`
+}
+type OxmlHeader struct {
+	Family     string    `xml:"family"`
+	Range      string    `xml:"range"`
+	HashSize   int       `xml:"hashsize"`
+	MaxElem    int       `xml:"maxelem"`
+	BucketSize int       `xml:"bucketsize"`
+	Size       int       `xml:"size"`
+	NetMask    int       `xml:"netmask"`
+	MarkMask   string    `xml:"markmask"` // Hexadecimal.
+	Timeout    int       `xml:"timeout"`
+	MemSize    int       `xml:"memsize"`
+	References int       `xml:"references"`
+	NumEntries int       `xml:"numentries"`
+	Counters   *struct{} `xml:"counters"`
+	Comment    *struct{} `xml:"comment"`
+	SKBInfo    *struct{} `xml:"skbinfo"`
+	ForceAdd   *struct{} `xml:"forceadd"`
 }
 type OxmlMember struct {
-	XMLName xml.Name `xml:"member"`
-	Element string   `xml:"elem"`
+	XMLName  xml.Name  `xml:"member"`
+	Element  string    `xml:"elem"`
+	Timeout  int       `xml:"timeout"`
+	Packets  uint64    `xml:"packets"`
+	Bytes    uint64    `xml:"bytes"`
+	Comment  string    `xml:"comment"`
+	SKBMark  string    `xml:"skbmark"` // Formatted as mark/mask.
+	SKBPrio  string    `xml:"skbprio"` // Formatted as major:minor.
+	SKBQueue int       `xml:"skbqueue"`
+	NoMatch  *struct{} `xml:"nomatch"`
 }
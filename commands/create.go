@@ -2,6 +2,10 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/set"
 	"github.com/francescocolleoni/go-ipset/utilities"
 )
@@ -15,10 +19,11 @@ type CreateSet struct {
 	// Options.
 	IPRange        string // Used only for bitmap:ip and bitmap:ip,mac sets.
 	PortRange      string // Used only for bitmap:port sets.
-	NetMask        int    // Used only for bitmap:ip, hash:ip sets.
+	NetMask        int    // Used only for bitmap:ip, hash:ip sets, up to 128 for inet6 sets.
 	MarkMask       int    // Used only for hash:ip,mark sets.
 	HashSize       int    // Only for hash sets.
 	MaxElements    int    // Only for hash sets.
+	BucketSize     int    // Only for hash sets, from 2 to 12 (ipset 7.11 or later).
 	Size           int    // Only for list sets.
 	Timeout        int
 	UseCounters    bool
@@ -26,6 +31,9 @@ type CreateSet struct {
 	ForceAdd       bool
 	AllowsComments bool
 	ProtocolFamily ProtocolFamily // Only for hash sets, excluding hash:mac.
+
+	// Global options.
+	Exist bool // Do not fail if a set with the same name and options already exists.
 }
 
 // TranslateToCommandLine support functions for Create*type* sets.
@@ -34,7 +42,7 @@ func (c *CreateSet) translateCreateBitmapIPToCommandLine() []string {
 	// bitmap:ip
 	// range fromip-toip|ip/cidr [ netmask cidr ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
 	out := rangeIPOption(c.IPRange)
-	out = append(out, netmaskOption(c.NetMask)...)
+	out = append(out, netmaskOption(c.NetMask, ProtocolFamilyINet)...)
 	return out
 }
 func (c *CreateSet) translateCreateBitmapIPMACToCommandLine() []string {
@@ -49,27 +57,30 @@ func (c *CreateSet) translateCreateBitmapPortToCommandLine() []string {
 }
 func (c *CreateSet) translateCreateHashIPToCommandLine() []string {
 	// hash:ip
-	// [ family { inet | inet6 } ] | [ hashsize value ] [ maxelem value ] [ netmask cidr ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
+	// [ family { inet | inet6 } ] | [ hashsize value ] [ maxelem value ] [ bucketsize value ] [ netmask cidr ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
 	out := protocolFamilyOption(c.ProtocolFamily, c.Type)
 	out = append(out, hashSizeOption(c.HashSize)...)
 	out = append(out, maxElementsOption(c.MaxElements)...)
-	out = append(out, netmaskOption(c.NetMask)...)
+	out = append(out, bucketSizeOption(c.BucketSize)...)
+	out = append(out, netmaskOption(c.NetMask, c.ProtocolFamily)...)
 	return out
 }
 func (c *CreateSet) translateCreateHashMACToCommandLine() []string {
 	// hash:ip,mac
-	// [ hashsize value ] [ maxelem value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
+	// [ hashsize value ] [ maxelem value ] [ bucketsize value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
 	out := hashSizeOption(c.HashSize)
 	out = append(out, maxElementsOption(c.MaxElements)...)
+	out = append(out, bucketSizeOption(c.BucketSize)...)
 	return out
 }
 func (c *CreateSet) translateCreateHashIPMarkToCommandLine() []string {
 	// hash:ip,mark
-	// [ family { inet | inet6 } ] | [ markmask value ] [ hashsize value ] [ maxelem value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
+	// [ family { inet | inet6 } ] | [ markmask value ] [ hashsize value ] [ maxelem value ] [ bucketsize value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
 	out := protocolFamilyOption(c.ProtocolFamily, c.Type)
 	out = append(out, markmaskOption(c.MarkMask)...)
 	out = append(out, hashSizeOption(c.HashSize)...)
 	out = append(out, maxElementsOption(c.MaxElements)...)
+	out = append(out, bucketSizeOption(c.BucketSize)...)
 	return out
 }
 func (c *CreateSet) translateCreateListToCommandLine() []string {
@@ -78,10 +89,11 @@ func (c *CreateSet) translateCreateListToCommandLine() []string {
 	return sizeOption(c.Size)
 }
 func (c *CreateSet) translateCreateHashOtherToCommandLine() []string {
-	// [ family { inet | inet6 } ] | [ hashsize value ] [ maxelem value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
+	// [ family { inet | inet6 } ] | [ hashsize value ] [ maxelem value ] [ bucketsize value ] [ timeout value ] [ counters ] [ comment ] [ skbinfo ]
 	out := protocolFamilyOption(c.ProtocolFamily, c.Type)
 	out = append(out, hashSizeOption(c.HashSize)...)
 	out = append(out, maxElementsOption(c.MaxElements)...)
+	out = append(out, bucketSizeOption(c.BucketSize)...)
 	return out
 }
 
@@ -133,6 +145,8 @@ func (c *CreateSet) TranslateToIPSetArgs() []string {
 	out = append(out, countersOption(c.UseCounters)...)
 	out = append(out, commentFlagOption(c.AllowsComments)...)
 	out = append(out, skbInfoOption(c.UseSKBInfo)...)
+	out = append(out, forceAddOption(c.ForceAdd)...)
+	out = append(out, existOption(c.Exist)...)
 
 	return out
 }
@@ -154,9 +168,50 @@ func (c *CreateSet) IncludesMandatoryOptions() bool {
 	}
 }
 
+// CreateSet implementation of Validate.
+// Options that do not apply to the set type are reported, instead of being dropped by TranslateToIPSetArgs;
+// family inet is accepted by all types, since it is the default one.
+func (c *CreateSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameCreate); err != nil {
+		return err
+	} else if err := validateSetName("name", c.Name); err != nil {
+		return err
+	} else if c.Type.Features() == 0 {
+		return fmt.Errorf("%w: set type %v is not supported", liberrors.ErrIPSetCommandIsInvalid, c.Type)
+	}
+
+	probe := newCreateCommand(c.Name, c.Type)
+	for _, option := range c.options() {
+		if err := option(probe); err != nil {
+			return err
+		}
+	}
+
+	if !c.IncludesMandatoryOptions() {
+		return fmt.Errorf("%w: range is required by sets of type %v", liberrors.ErrIPSetCommandIsInvalid, c.Type)
+	} else if c.NetMask != 0 && len(netmaskOption(c.NetMask, c.ProtocolFamily)) == 0 {
+		return fmt.Errorf("%w: netmask %d is invalid for family %v", liberrors.ErrIPSetCommandIsInvalid, c.NetMask, c.ProtocolFamily)
+	}
+	return nil
+}
+
 // Run executes a CreateSet command.
 func (c *CreateSet) Run() error {
-	if out, err := utilities.RunIPSet(c.TranslateToIPSetArgs()...); err != nil {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a CreateSet command; ipset is killed if ctx is done before it exits.
+func (c *CreateSet) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a CreateSet command using a given executor.
+func (c *CreateSet) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err != nil {
 		return out.Error
 	}
 
@@ -164,6 +219,7 @@ func (c *CreateSet) Run() error {
 }
 
 // New Create*type* set.
+// Hash constructors ignore all other options if a protocol family is given; NewCreate combines them.
 // NewCreateBitmapIP returns a create command for a SetTypeBitmapIP set.
 func NewCreateBitmapIP(name, ipRange string, netMask, timeout int, useCounters, allowsComments, useSKBInfo bool) *CreateSet {
 	// bitmap:ip
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// CreateOption sets an option of a CreateSet command built by NewCreate.
+// Options return an error if their value is invalid or if they do not apply to the type of the set.
+type CreateOption func(c *CreateSet) error
+
+// Limits of option bucketsize, as defined by the kernel.
+const (
+	minBucketSize = 2
+	maxBucketSize = 12
+)
+
+// NewCreate returns a create command for a set of a given type, applying options in order.
+// In contrast with NewCreate*type* constructors, family can be combined with all other options.
+// NewCreate returns ErrIPSetOptionNotSupported if an option does not apply to setType, and
+// ErrIPSetCommandIsInvalid if name or setType are not valid, an option value is invalid or a mandatory option is missing
+// (ex.: range of bitmap sets); the result is checked by Validate.
+func NewCreate(name string, setType set.SetType, options ...CreateOption) (*CreateSet, error) {
+	if setType.Features() == 0 {
+		return nil, fmt.Errorf("%w: set type %v is not supported", liberrors.ErrIPSetCommandIsInvalid, setType)
+	}
+
+	out := newCreateCommand(name, setType)
+	for _, option := range options {
+		if err := option(out); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WithFamily sets option family; supported only by hash sets, excluding hash:mac.
+func WithFamily(protocolFamily ProtocolFamily) CreateOption {
+	return func(c *CreateSet) error {
+		if len(protocolFamilyOption(ProtocolFamilyINet, c.Type)) == 0 {
+			return createOptionNotSupported(c, "family")
+		}
+
+		c.ProtocolFamily = protocolFamily
+		return nil
+	}
+}
+
+// WithIPRange sets option range of bitmap:ip and bitmap:ip,mac sets, formatted as fromip-toip or ip/cidr.
+func WithIPRange(ipRange string) CreateOption {
+	return func(c *CreateSet) error {
+		if c.Type != set.SetTypeBitmapIP && c.Type != set.SetTypeBitmapIPMAC {
+			return createOptionNotSupported(c, "range")
+		} else if len(rangeIPOption(ipRange)) == 0 {
+			return createOptionIsInvalid("range", ipRange)
+		}
+
+		c.IPRange = ipRange
+		return nil
+	}
+}
+
+// WithPortRange sets option range of bitmap:port sets, formatted as fromport-toport.
+func WithPortRange(portRange string) CreateOption {
+	return func(c *CreateSet) error {
+		if c.Type != set.SetTypeBitmapPort {
+			return createOptionNotSupported(c, "range")
+		} else if len(rangePortOption(portRange)) == 0 {
+			return createOptionIsInvalid("range", portRange)
+		}
+
+		c.PortRange = portRange
+		return nil
+	}
+}
+
+// WithNetMask sets option netmask of bitmap:ip and hash:ip sets.
+// Values must be in [1, 32], or in [1, 128] for inet6 sets.
+func WithNetMask(netMask int) CreateOption {
+	return func(c *CreateSet) error {
+		if c.Type != set.SetTypeBitmapIP && c.Type != set.SetTypeHashIP {
+			return createOptionNotSupported(c, "netmask")
+		} else if len(netmaskOption(netMask, ProtocolFamilyINet6)) == 0 {
+			return createOptionIsInvalid("netmask", netMask)
+		}
+
+		c.NetMask = netMask
+		return nil
+	}
+}
+
+// WithMarkMask sets option markmask of hash:ip,mark sets.
+func WithMarkMask(markMask int) CreateOption {
+	return func(c *CreateSet) error {
+		if c.Type != set.SetTypeHashIPMark {
+			return createOptionNotSupported(c, "markmask")
+		} else if len(markmaskOption(markMask)) == 0 {
+			return createOptionIsInvalid("markmask", markMask)
+		}
+
+		c.MarkMask = markMask
+		return nil
+	}
+}
+
+// WithHashSize sets option hashsize of hash sets.
+func WithHashSize(hashSize int) CreateOption {
+	return func(c *CreateSet) error {
+		if !isHashSetType(c.Type) {
+			return createOptionNotSupported(c, "hashsize")
+		} else if hashSize <= 0 {
+			return createOptionIsInvalid("hashsize", hashSize)
+		}
+
+		c.HashSize = hashSize
+		return nil
+	}
+}
+
+// WithMaxElem sets option maxelem of hash sets.
+func WithMaxElem(maxElements int) CreateOption {
+	return func(c *CreateSet) error {
+		if !isHashSetType(c.Type) {
+			return createOptionNotSupported(c, "maxelem")
+		} else if maxElements <= 0 {
+			return createOptionIsInvalid("maxelem", maxElements)
+		}
+
+		c.MaxElements = maxElements
+		return nil
+	}
+}
+
+// WithBucketSize sets option bucketsize of hash sets, the maximum number of entries of a bucket
+// before the hash is resized; odd values are rounded up by the kernel.
+func WithBucketSize(bucketSize int) CreateOption {
+	return func(c *CreateSet) error {
+		if !isHashSetType(c.Type) {
+			return createOptionNotSupported(c, "bucketsize")
+		} else if bucketSize < minBucketSize || bucketSize > maxBucketSize {
+			return createOptionIsInvalid("bucketsize", bucketSize)
+		}
+
+		c.BucketSize = bucketSize
+		return nil
+	}
+}
+
+// WithSize sets option size of list:set sets.
+func WithSize(size int) CreateOption {
+	return func(c *CreateSet) error {
+		if c.Type != set.SetTypeListSet {
+			return createOptionNotSupported(c, "size")
+		} else if size <= 0 {
+			return createOptionIsInvalid("size", size)
+		}
+
+		c.Size = size
+		return nil
+	}
+}
+
+// WithTimeout sets option timeout, in seconds, supported by all sets.
+func WithTimeout(timeout int) CreateOption {
+	return func(c *CreateSet) error {
+		if timeout <= 0 {
+			return createOptionIsInvalid("timeout", timeout)
+		}
+
+		c.Timeout = timeout
+		return nil
+	}
+}
+
+// WithCounters sets option counters, supported by all sets.
+func WithCounters() CreateOption {
+	return func(c *CreateSet) error {
+		c.UseCounters = true
+		return nil
+	}
+}
+
+// WithComments sets option comment, supported by all sets.
+func WithComments() CreateOption {
+	return func(c *CreateSet) error {
+		c.AllowsComments = true
+		return nil
+	}
+}
+
+// WithSKBInfo sets option skbinfo, supported by all sets.
+func WithSKBInfo() CreateOption {
+	return func(c *CreateSet) error {
+		c.UseSKBInfo = true
+		return nil
+	}
+}
+
+// WithForceAdd sets option forceadd of hash sets.
+func WithForceAdd() CreateOption {
+	return func(c *CreateSet) error {
+		if !isHashSetType(c.Type) {
+			return createOptionNotSupported(c, "forceadd")
+		}
+
+		c.ForceAdd = true
+		return nil
+	}
+}
+
+// WithExist sets global option -exist.
+func WithExist() CreateOption {
+	return func(c *CreateSet) error {
+		c.Exist = true
+		return nil
+	}
+}
+
+// Support.
+
+// options returns the options that build the non-zero fields of c, used to validate them.
+func (c *CreateSet) options() []CreateOption {
+	out := []CreateOption{}
+	if c.ProtocolFamily == ProtocolFamilyINet6 {
+		out = append(out, WithFamily(c.ProtocolFamily))
+	}
+	if c.IPRange != "" {
+		out = append(out, WithIPRange(c.IPRange))
+	}
+	if c.PortRange != "" {
+		out = append(out, WithPortRange(c.PortRange))
+	}
+	if c.NetMask != 0 {
+		out = append(out, WithNetMask(c.NetMask))
+	}
+	if c.MarkMask != 0 {
+		out = append(out, WithMarkMask(c.MarkMask))
+	}
+	if c.HashSize != 0 {
+		out = append(out, WithHashSize(c.HashSize))
+	}
+	if c.MaxElements != 0 {
+		out = append(out, WithMaxElem(c.MaxElements))
+	}
+	if c.BucketSize != 0 {
+		out = append(out, WithBucketSize(c.BucketSize))
+	}
+	if c.Size != 0 {
+		out = append(out, WithSize(c.Size))
+	}
+	if c.Timeout != 0 {
+		out = append(out, WithTimeout(c.Timeout))
+	}
+	if c.ForceAdd {
+		out = append(out, WithForceAdd())
+	}
+	return out
+}
+
+// isHashSetType returns true if setType is a hash set type.
+func isHashSetType(setType set.SetType) bool {
+	return strings.HasPrefix(setType.String(), "hash:")
+}
+
+// createOptionNotSupported returns an error describing an option that does not apply to the type of c.
+func createOptionNotSupported(c *CreateSet, option string) error {
+	return fmt.Errorf("%w: option %s is not supported by sets of type %v", liberrors.ErrIPSetOptionNotSupported, option, c.Type)
+}
+
+// createOptionIsInvalid returns an error describing an invalid option value.
+func createOptionIsInvalid(option string, value interface{}) error {
+	return fmt.Errorf("%w: %s %v is invalid", liberrors.ErrIPSetCommandIsInvalid, option, value)
+}
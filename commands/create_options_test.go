@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+func TestNewCreate(t *testing.T) {
+	type test struct {
+		setType set.SetType
+		options []CreateOption
+		args    []string
+		err     error
+	}
+
+	const setName = "testset"
+	tests := []test{
+		{set.SetTypeHashIP, nil, []string{}, nil},
+		{
+			set.SetTypeHashIP, []CreateOption{WithFamily(ProtocolFamilyINet6), WithHashSize(1024), WithMaxElem(65536), WithNetMask(64), WithTimeout(60)},
+			[]string{"family", "inet6", "hashsize", "1024", "maxelem", "65536", "netmask", "64", "timeout", "60"}, nil,
+		},
+		{
+			set.SetTypeHashNetPort, []CreateOption{WithFamily(ProtocolFamilyINet), WithMaxElem(10), WithCounters(), WithComments(), WithSKBInfo(), WithForceAdd(), WithExist()},
+			[]string{"family", "inet", "maxelem", "10", "counters", "comment", "skbinfo", "forceadd", "-exist"}, nil,
+		},
+		{set.SetTypeHashIPMark, []CreateOption{WithFamily(ProtocolFamilyINet6), WithMarkMask(255)}, []string{"family", "inet6", "markmask", "255"}, nil},
+		{set.SetTypeHashMAC, []CreateOption{WithHashSize(64), WithTimeout(10)}, []string{"hashsize", "64", "timeout", "10"}, nil},
+		{set.SetTypeHashNet, []CreateOption{WithHashSize(64), WithBucketSize(4)}, []string{"hashsize", "64", "bucketsize", "4"}, nil},
+		{set.SetTypeBitmapIP, []CreateOption{WithIPRange("10.0.0.0/16"), WithNetMask(24)}, []string{"range", "10.0.0.0/16", "netmask", "24"}, nil},
+		{set.SetTypeBitmapIPMAC, []CreateOption{WithIPRange("1.1.1.1-2.2.2.2"), WithCounters()}, []string{"range", "1.1.1.1-2.2.2.2", "counters"}, nil},
+		{set.SetTypeBitmapPort, []CreateOption{WithPortRange("0-1024")}, []string{"range", "0-1024"}, nil},
+		{set.SetTypeListSet, []CreateOption{WithSize(8), WithTimeout(5)}, []string{"size", "8", "timeout", "5"}, nil},
+
+		// Options that do not apply to the set type.
+		{set.SetTypeHashMAC, []CreateOption{WithFamily(ProtocolFamilyINet6)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeBitmapIP, []CreateOption{WithIPRange("1.1.1.1-2.2.2.2"), WithMaxElem(10)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeBitmapPort, []CreateOption{WithIPRange("1.1.1.1-2.2.2.2")}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeHashNet, []CreateOption{WithNetMask(24)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeHashIP, []CreateOption{WithMarkMask(255)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeHashIP, []CreateOption{WithSize(8)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeListSet, []CreateOption{WithBucketSize(4)}, nil, liberrors.ErrIPSetOptionNotSupported},
+		{set.SetTypeListSet, []CreateOption{WithForceAdd()}, nil, liberrors.ErrIPSetOptionNotSupported},
+
+		// Invalid values and missing mandatory options.
+		{set.SetTypeHashIP, []CreateOption{WithNetMask(64)}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeHashIP, []CreateOption{WithNetMask(64), WithFamily(ProtocolFamilyINet6)}, []string{"family", "inet6", "netmask", "64"}, nil},
+		{set.SetTypeHashIP, []CreateOption{WithHashSize(0)}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeHashIP, []CreateOption{WithBucketSize(16)}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeHashIP, []CreateOption{WithTimeout(-1)}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeBitmapIP, []CreateOption{WithIPRange("invalid")}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeBitmapIP, []CreateOption{WithTimeout(10)}, nil, liberrors.ErrIPSetCommandIsInvalid},
+		{set.SetTypeUnsupported, nil, nil, liberrors.ErrIPSetCommandIsInvalid},
+	}
+
+	for i, test := range tests {
+		command, err := NewCreate(setName, test.setType, test.options...)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("expectation %d failed: %v does not match %v (expected)", i+1, err, test.err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+			continue
+		}
+
+		result := fmt.Sprintf("%v", command.TranslateToIPSetArgs())
+		expects := fmt.Sprintf("%v", append([]string{"create", setName, test.setType.String()}, test.args...))
+		if result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"testing"
 
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/set"
 	"github.com/francescocolleoni/go-ipset/utilities"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
 )
 
 func TestCreateSetTranslateToCommandLine(t *testing.T) {
@@ -52,6 +56,13 @@ func TestCreateSetTranslateToCommandLine(t *testing.T) {
 			NewCreateHashIP(setName, ProtocolFamilyDefault, 10, 10, 10, 10, true, true, true), set.SetTypeHashIP,
 			[]string{"hashsize", "10", "maxelem", "10", "netmask", "10", "timeout", "10", "counters", "comment", "skbinfo"},
 		},
+		{
+			&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, ProtocolFamily: ProtocolFamilyINet6, NetMask: 64}, set.SetTypeHashIP,
+			[]string{"family", "inet6", "netmask", "64"},
+		},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, ProtocolFamily: ProtocolFamilyINet, NetMask: 64}, set.SetTypeHashIP, []string{"family", "inet"}},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, Timeout: 60, Exist: true}, set.SetTypeHashIP, []string{"timeout", "60", "-exist"}},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, UseCounters: true, ForceAdd: true}, set.SetTypeHashIP, []string{"counters", "forceadd"}},
 
 		{NewCreateHashIPMark(setName, ProtocolFamilyINet, 0, 0, 0, 0, false, false, false), set.SetTypeHashIPMark, []string{"family", "inet"}},
 		{NewCreateHashIPMark(setName, ProtocolFamilyINet, 10, 10, 10, 10, true, true, true), set.SetTypeHashIPMark, []string{"family", "inet"}},
@@ -506,3 +517,40 @@ func TestCreateSet(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateSetValidate(t *testing.T) {
+	type test struct {
+		command *CreateSet
+		expects error
+		message string
+	}
+
+	const setName = "testset"
+	tests := []test{
+		{NewCreateHashIP(setName, ProtocolFamilyDefault, 1024, 65536, 24, 60, true, true, true), nil, ""},
+		{NewCreateFromInfo(setName, &SetInfo{Type: set.SetTypeBitmapIP, Header: SetHeader{ProtocolFamily: ProtocolFamilyINet, Range: "10.0.0.0-10.0.255.255"}}), nil, ""},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, NetMask: 40}, liberrors.ErrIPSetCommandIsInvalid, "netmask 40 is invalid for family inet"},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIPMark, MarkMask: -1}, liberrors.ErrIPSetCommandIsInvalid, "markmask -1 is invalid"},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashIP, HashSize: -1}, liberrors.ErrIPSetCommandIsInvalid, "hashsize -1 is invalid"},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeHashMAC, ProtocolFamily: ProtocolFamilyINet6}, liberrors.ErrIPSetOptionNotSupported, "option family is not supported"},
+		{&CreateSet{Command: CommandNameCreate, Name: setName, Type: set.SetTypeListSet, MaxElements: 10}, liberrors.ErrIPSetOptionNotSupported, "option maxelem is not supported"},
+		{NewCreateBitmapIP(setName, "", 0, 0, false, false, false), liberrors.ErrIPSetCommandIsInvalid, "range is required"},
+		{NewCreateBitmapPort(setName, "1-70000", 0, false, false, false), liberrors.ErrIPSetCommandIsInvalid, "range 1-70000 is invalid"},
+		{NewCreateHashIP("", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), liberrors.ErrIPSetCommandIsInvalid, "name is missing"},
+		{NewCreateHashIP(strings.Repeat("a", MaxSetNameLength+1), ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), liberrors.ErrIPSetCommandIsInvalid, "is longer than"},
+		{&CreateSet{Command: CommandNameAdd, Name: setName, Type: set.SetTypeHashIP}, liberrors.ErrIPSetCommandIsInvalid, `command "add" is not create`},
+	}
+
+	for i, test := range tests {
+		err := test.command.Validate()
+		if test.expects == nil && err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+		} else if test.expects != nil && (!errors.Is(err, test.expects) || !strings.Contains(fmt.Sprintf("%v", err), test.message)) {
+			t.Errorf("expectation %d failed: %v does not match %v: %s (expected)", i+1, err, test.expects, test.message)
+		} else if executor := (&utilitiestest.Executor{}); test.expects != nil {
+			if err := test.command.RunWith(context.Background(), executor); !errors.Is(err, test.expects) || len(executor.Calls) > 0 {
+				t.Errorf("expectation %d failed: invalid command should not run", i+1)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"encoding/xml"
 	"strings"
 
@@ -61,9 +62,39 @@ func (c *ExistsSet) IncludesMandatoryOptions() bool {
 	return strings.Trim(c.Name, " \n") != ""
 }
 
+// DestroySet implementation of Validate.
+func (c *DestroySet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameDestroy); err != nil {
+		return err
+	}
+	return validateSetName("name", c.Name)
+}
+
+// ExistsSet implementation of Validate.
+func (c *ExistsSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameExists); err != nil {
+		return err
+	}
+	return validateSetName("name", c.Name)
+}
+
 // Run executes a DestroySet command.
 func (c *DestroySet) Run() error {
-	if out, err := utilities.RunIPSet(c.TranslateToIPSetArgs()...); err != nil {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a DestroySet command; ipset is killed if ctx is done before it exits.
+func (c *DestroySet) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a DestroySet command using a given executor.
+func (c *DestroySet) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err != nil {
 		return out.Error
 	}
 
@@ -72,10 +103,24 @@ func (c *DestroySet) Run() error {
 
 // Run executes a ExistsSet command.
 func (c *ExistsSet) Run() bool {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a ExistsSet command; ipset is killed if ctx is done before it exits.
+func (c *ExistsSet) RunContext(ctx context.Context) bool {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a ExistsSet command using a given executor.
+func (c *ExistsSet) RunWith(ctx context.Context, executor utilities.Executor) bool {
+	if c.Validate() != nil {
+		return false
+	}
+
 	args := c.TranslateToIPSetArgs()
 	args = append(args, "-output", "xml")
 
-	out, err := utilities.RunIPSet(args...)
+	out, err := utilities.RunIPSetWith(ctx, executor, args...)
 	if err != nil {
 		return false
 	}
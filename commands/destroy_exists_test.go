@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/utilities"
 )
 
@@ -75,6 +78,38 @@ func TestDestroyExistsSetValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestSetNameValidate(t *testing.T) {
+	type test struct {
+		command Command
+		message string
+	}
+
+	tooLong := strings.Repeat("a", MaxSetNameLength+1)
+	tests := []test{
+		{NewDestroySet("testset"), ""},
+		{NewDestroySet(" "), "name is missing"},
+		{NewExistsSet(tooLong), fmt.Sprintf(`name "%s" is longer than %d characters`, tooLong, MaxSetNameLength)},
+		{NewFlushSet(""), "name is missing"},
+		{NewListSet(""), ""},
+		{NewListSet(tooLong), "is longer than"},
+		{&ListSet{Command: CommandNameSave}, `command "save" is not list`},
+		{NewSaveSet(""), ""},
+		{NewSwapSets("a", ""), "other set name is missing"},
+		{NewRenameSet("", "b"), "name is missing"},
+		{NewRenameSet("a", tooLong), "new set name"},
+	}
+
+	for i, test := range tests {
+		err := test.command.Validate()
+		if test.message == "" && err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+		} else if test.message != "" && (!errors.Is(err, liberrors.ErrIPSetCommandIsInvalid) || !strings.Contains(fmt.Sprintf("%v", err), test.message)) {
+			t.Errorf("expectation %d failed: %v != %s (expected)", i+1, err, test.message)
+		}
+	}
+}
+
 func TestDestroyExistsSet(t *testing.T) {
 	const setName = "testset"
 	utilities.RunIPSet("destroy", setName)
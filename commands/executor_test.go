@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestRunWith(t *testing.T) {
+	type test struct {
+		run     func(executor *utilitiestest.Executor) error
+		expects []string
+	}
+
+	const setName = "testset"
+	tests := []test{
+		{
+			func(e *utilitiestest.Executor) error {
+				return NewCreateHashMAC(setName, 0, 0, 10, false, false, false).RunWith(context.Background(), e)
+			},
+			[]string{"create", setName, "hash:mac", "timeout", "10"},
+		},
+		{
+			func(e *utilitiestest.Executor) error {
+				return NewAddEntry(setName, set.SetTypeHashIP, "1.1.1.1").RunWith(context.Background(), e)
+			},
+			[]string{"add", setName, "1.1.1.1"},
+		},
+		{
+			func(e *utilitiestest.Executor) error {
+				return NewTestEntry(setName, set.SetTypeHashIP, "1.1.1.1").RunWith(context.Background(), e)
+			},
+			[]string{"test", setName, "1.1.1.1"},
+		},
+		{
+			func(e *utilitiestest.Executor) error {
+				return NewDeleteEntry(setName, set.SetTypeHashIP, "1.1.1.1").RunWith(context.Background(), e)
+			},
+			[]string{"del", setName, "1.1.1.1"},
+		},
+		{
+			func(e *utilitiestest.Executor) error { return NewFlushSet(setName).RunWith(context.Background(), e) },
+			[]string{"flush", setName},
+		},
+		{
+			func(e *utilitiestest.Executor) error { return NewDestroySet(setName).RunWith(context.Background(), e) },
+			[]string{"destroy", setName},
+		},
+		{
+			func(e *utilitiestest.Executor) error {
+				_, err := NewListSet(setName).RunWith(context.Background(), e)
+				return err
+			},
+			[]string{"list", setName, "-output", "xml"},
+		},
+		{
+			func(e *utilitiestest.Executor) error {
+				NewExistsSet(setName).RunWith(context.Background(), e)
+				return nil
+			},
+			[]string{"-L", setName, "-output", "xml"},
+		},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: []string{fakeListOutput(setName, "hash:ip", "1.1.1.1")}}
+		if err := test.run(executor); err != nil {
+			t.Errorf("expectation %d failed: command returned an error: %v", i+1, err)
+		}
+
+		result := fmt.Sprintf("%v", executor.Calls)
+		expects := fmt.Sprintf("%v", [][]string{test.expects})
+		if result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		}
+	}
+}
+
+func TestRunWithError(t *testing.T) {
+	const setName = "testset"
+	executor := &utilitiestest.Executor{
+		Outputs: []string{"ipset v7.1: The set with the given name does not exist\n"},
+		Errors:  []error{errors.New("exit status 1")},
+	}
+
+	expects := `ipset returned error "The set with the given name does not exist"`
+	if err := NewFlushSet(setName).RunWith(context.Background(), executor); err == nil {
+		t.Error("expectation failed: command should return an error")
+	} else if err.Error() != expects {
+		t.Errorf("unexpected error: %s != %s (expected)", err.Error(), expects)
+	}
+}
+
+func TestListSetRunWith(t *testing.T) {
+	const setName = "testset"
+	executor := &utilitiestest.Executor{Outputs: []string{fakeListOutput(setName, "hash:ip", "1.1.1.1", "2.2.2.2")}}
+
+	if out, err := NewListSet(setName).RunWith(context.Background(), executor); err != nil {
+		t.Errorf("list set failed: %v", err)
+	} else if result := fmt.Sprintf("%v", out); result != "[1.1.1.1 2.2.2.2]" {
+		t.Errorf("unexpected list content: %s", result)
+	}
+
+	if exists := NewExistsSet(setName).RunWith(context.Background(), executor); !exists {
+		t.Errorf("test set %s should exist, but command returned false", setName)
+	}
+
+	if exists := NewExistsSet("otherset").RunWith(context.Background(), executor); exists {
+		t.Error("test set otherset should not exist, but command returned true")
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	executor := &utilitiestest.Executor{}
+	if err := NewFlushSet("testset").RunWith(ctx, executor); err != liberrors.ErrIPSetCanceled {
+		t.Errorf("unexpected error: %v != %v (expected)", err, liberrors.ErrIPSetCanceled)
+	}
+	if _, err := NewListSet("testset").RunWith(ctx, executor); err != liberrors.ErrIPSetCanceled {
+		t.Errorf("unexpected error: %v != %v (expected)", err, liberrors.ErrIPSetCanceled)
+	}
+
+	if len(executor.Calls) > 0 {
+		t.Errorf("executor should not run when context is done, received %v", executor.Calls)
+	}
+}
+
+// Support.
+
+// fakeListOutput returns the XML output of ipset list for a set with a given name, type and list of members.
+func fakeListOutput(name, setType string, members ...string) string {
+	out := fmt.Sprintf(`<ipsets><ipset name="%s"><type>%s</type><members>`, name, setType)
+	for _, member := range members {
+		out += fmt.Sprintf("<member><elem>%s</elem></member>", member)
+	}
+	return out + "</members></ipset></ipsets>"
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"strings"
 
 	"github.com/francescocolleoni/go-ipset/utilities"
@@ -33,9 +34,31 @@ func (c *FlushSet) IncludesMandatoryOptions() bool {
 	return strings.Trim(c.Name, " \n") != ""
 }
 
+// FlushSet implementation of Validate.
+func (c *FlushSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameFlush); err != nil {
+		return err
+	}
+	return validateSetName("name", c.Name)
+}
+
 // Run executes a FlushSet command.
 func (c *FlushSet) Run() error {
-	if out, err := utilities.RunIPSet(c.TranslateToIPSetArgs()...); err != nil {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a FlushSet command; ipset is killed if ctx is done before it exits.
+func (c *FlushSet) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a FlushSet command using a given executor.
+func (c *FlushSet) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...); err != nil {
 		return out.Error
 	}
 
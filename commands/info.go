@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// SetInfo describes a set as listed by ipset, including its header and members.
+type SetInfo struct {
+	Name     string
+	Type     set.SetType
+	Revision int
+	Header   SetHeader
+	Members  []SetMember
+}
+
+// SetHeader describes the header of a set as listed by ipset.
+type SetHeader struct {
+	ProtocolFamily ProtocolFamily
+	Range          string // Used only for bitmap sets.
+	HashSize       int    // Only for hash sets.
+	MaxElements    int    // Only for hash sets.
+	BucketSize     int    // Only for hash sets.
+	Size           int    // Only for list sets.
+	NetMask        int
+	MarkMask       int // Used only for hash:ip,mark sets.
+	Timeout        int
+	UseCounters    bool
+	AllowsComments bool
+	UseSKBInfo     bool
+	ForceAdd       bool
+
+	// Runtime information.
+	MemSize    int
+	References int
+	NumEntries int
+}
+
+// SetMember describes an entry of a set as listed by ipset, including its options.
+type SetMember struct {
+	Entry       string
+	Timeout     int    // Remaining seconds, if the set was created with timeout.
+	Packets     uint64 // Only if the set was created with counters.
+	Bytes       uint64 // Only if the set was created with counters.
+	Comment     string // Only if the set was created with comment.
+	SKBMark     uint32 // Only if the set was created with skbinfo.
+	SKBMarkMask uint32 // Only if the set was created with skbinfo.
+	SKBPrio     string // Formatted as major:minor, only if the set was created with skbinfo.
+	SKBQueue    int    // Only if the set was created with skbinfo.
+	NoMatch     bool
+}
+
+// NewCreateFromInfo returns a create command for a set named name, with the same type and options of the set described by info.
+func NewCreateFromInfo(name string, info *SetInfo) *CreateSet {
+	out := newCreateCommand(name, info.Type)
+	out.ProtocolFamily = info.Header.ProtocolFamily
+	if info.Type == set.SetTypeBitmapPort {
+		out.PortRange = info.Header.Range
+	} else {
+		out.IPRange = info.Header.Range
+	}
+	out.NetMask = info.Header.NetMask
+	out.MarkMask = info.Header.MarkMask
+	out.HashSize = info.Header.HashSize
+	out.MaxElements = info.Header.MaxElements
+	out.BucketSize = info.Header.BucketSize
+	out.Size = info.Header.Size
+	out.Timeout = info.Header.Timeout
+	out.UseCounters = info.Header.UseCounters
+	out.AllowsComments = info.Header.AllowsComments
+	out.UseSKBInfo = info.Header.UseSKBInfo
+	out.ForceAdd = info.Header.ForceAdd
+	return out
+}
+
+// Entries returns members of info as typed entries.
+func (info *SetInfo) Entries() ([]set.Entry, error) {
+	out := make([]set.Entry, len(info.Members))
+	for i, member := range info.Members {
+		entry, err := set.ParseEntry(info.Type, member.Entry)
+		if err != nil {
+			return nil, fmt.Errorf(`set "%s": %w`, info.Name, err)
+		}
+		out[i] = entry
+	}
+	return out, nil
+}
+
+// Support.
+
+// newSetInfo returns the SetInfo described by the XML representation of a set.
+func newSetInfo(in OxmlIPSet) (SetInfo, error) {
+	out := SetInfo{
+		Name:     in.Name,
+		Type:     set.SetTypeWithString(in.Type),
+		Revision: in.Revision,
+		Header: SetHeader{
+			Range:          in.Header.Range,
+			HashSize:       in.Header.HashSize,
+			MaxElements:    in.Header.MaxElem,
+			BucketSize:     in.Header.BucketSize,
+			Size:           in.Header.Size,
+			NetMask:        in.Header.NetMask,
+			Timeout:        in.Header.Timeout,
+			UseCounters:    in.Header.Counters != nil,
+			AllowsComments: in.Header.Comment != nil,
+			UseSKBInfo:     in.Header.SKBInfo != nil,
+			ForceAdd:       in.Header.ForceAdd != nil,
+			MemSize:        in.Header.MemSize,
+			References:     in.Header.References,
+			NumEntries:     in.Header.NumEntries,
+		},
+		Members: make([]SetMember, len(in.Members)),
+	}
+
+	switch in.Header.Family {
+	case "inet":
+		out.Header.ProtocolFamily = ProtocolFamilyINet
+	case "inet6":
+		out.Header.ProtocolFamily = ProtocolFamilyINet6
+	}
+
+	if in.Header.MarkMask != "" {
+		markMask, err := strconv.ParseUint(in.Header.MarkMask, 0, 32)
+		if err != nil {
+			return out, fmt.Errorf(`set "%s" has invalid markmask %s`, in.Name, in.Header.MarkMask)
+		}
+		out.Header.MarkMask = int(markMask)
+	}
+
+	for i, member := range in.Members {
+		out.Members[i] = SetMember{
+			Entry:    member.Element,
+			Timeout:  member.Timeout,
+			Packets:  member.Packets,
+			Bytes:    member.Bytes,
+			Comment:  strings.Trim(member.Comment, `"`),
+			SKBPrio:  member.SKBPrio,
+			SKBQueue: member.SKBQueue,
+			NoMatch:  member.NoMatch != nil,
+		}
+
+		if member.SKBMark != "" {
+			mark, mask, err := parseSKBMark(member.SKBMark)
+			if err != nil {
+				return out, fmt.Errorf(`member %s of set "%s" has invalid skbmark %s`, member.Element, in.Name, member.SKBMark)
+			}
+			out.Members[i].SKBMark = mark
+			out.Members[i].SKBMarkMask = mask
+		}
+	}
+
+	return out, nil
+}
+
+// parseSKBMark parses an skbmark value formatted as mark[/mask]; mask defaults to 0xffffffff.
+func parseSKBMark(in string) (uint32, uint32, error) {
+	parts := strings.SplitN(in, "/", 2)
+
+	mark, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	mask := uint64(0xffffffff)
+	if len(parts) == 2 {
+		if mask, err = strconv.ParseUint(parts[1], 0, 32); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uint32(mark), uint32(mask), nil
+}
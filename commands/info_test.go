@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+const fakeInfoOutput = `<ipsets>
+<ipset name="blocklist">
+<type>hash:ip</type>
+<revision>4</revision>
+<header>
+<family>inet6</family>
+<hashsize>1024</hashsize>
+<maxelem>65536</maxelem>
+<bucketsize>12</bucketsize>
+<netmask>64</netmask>
+<timeout>300</timeout>
+<counters/>
+<comment/>
+<skbinfo/>
+<memsize>1200</memsize>
+<references>1</references>
+<numentries>2</numentries>
+</header>
+<members>
+<member><elem>2001:db8::1</elem><timeout>120</timeout><packets>10</packets><bytes>840</bytes><comment>"a comment"</comment><skbmark>0x10/0xff</skbmark><skbprio>1:2</skbprio><skbqueue>3</skbqueue></member>
+<member><elem>2001:db8::2</elem><timeout>0</timeout><packets>0</packets><bytes>0</bytes><nomatch/></member>
+</members>
+</ipset>
+<ipset name="marks">
+<type>hash:ip,mark</type>
+<revision>2</revision>
+<header>
+<family>inet</family>
+<markmask>0x0000ffff</markmask>
+<hashsize>64</hashsize>
+<maxelem>128</maxelem>
+<memsize>200</memsize>
+<references>0</references>
+<numentries>0</numentries>
+</header>
+<members>
+</members>
+</ipset>
+</ipsets>
+`
+
+func TestListSetInfoWith(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{fakeInfoOutput}}
+
+	info, err := NewListSet("blocklist").InfoWith(context.Background(), executor)
+	if err != nil {
+		t.Errorf("expectation failed: info returned an error: %v", err)
+		return
+	}
+
+	result := fmt.Sprintf("%s %v %d %+v", info.Name, info.Type, info.Revision, info.Header)
+	expects := fmt.Sprintf("blocklist %v 4 %+v", set.SetTypeHashIP, SetHeader{
+		ProtocolFamily: ProtocolFamilyINet6,
+		HashSize:       1024,
+		MaxElements:    65536,
+		BucketSize:     12,
+		NetMask:        64,
+		Timeout:        300,
+		UseCounters:    true,
+		AllowsComments: true,
+		UseSKBInfo:     true,
+		MemSize:        1200,
+		References:     1,
+		NumEntries:     2,
+	})
+	if result != expects {
+		t.Errorf("unexpected set info: %s != %s (expected)", result, expects)
+	}
+
+	result = fmt.Sprintf("%+v", info.Members)
+	expects = fmt.Sprintf("%+v", []SetMember{
+		{Entry: "2001:db8::1", Timeout: 120, Packets: 10, Bytes: 840, Comment: "a comment", SKBMark: 0x10, SKBMarkMask: 0xff, SKBPrio: "1:2", SKBQueue: 3},
+		{Entry: "2001:db8::2", NoMatch: true},
+	})
+	if result != expects {
+		t.Errorf("unexpected members: %s != %s (expected)", result, expects)
+	}
+
+	if _, err := NewListSet("otherset").InfoWith(context.Background(), executor); !errors.Is(err, liberrors.ErrIPSetNoSuchSet) {
+		t.Errorf("expectation failed: %v != %v (expected)", err, liberrors.ErrIPSetNoSuchSet)
+	}
+}
+
+func TestListSetSetsWith(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{fakeInfoOutput}}
+
+	sets, err := NewListSet("").SetsWith(context.Background(), executor)
+	if err != nil {
+		t.Errorf("expectation failed: sets returned an error: %v", err)
+		return
+	} else if len(sets) != 2 {
+		t.Errorf("unexpected number of sets: %d", len(sets))
+		return
+	}
+
+	if sets[1].Type != set.SetTypeHashIPMark || sets[1].Header.MarkMask != 0xffff || len(sets[1].Members) != 0 {
+		t.Errorf("unexpected set info: %+v", sets[1])
+	}
+
+	if result := fmt.Sprintf("%v", executor.Calls); result != "[[list -output xml]]" {
+		t.Errorf("unexpected executor calls: %s", result)
+	}
+}
+
+func TestSetInfoEntries(t *testing.T) {
+	info, err := NewListSet("blocklist").InfoWith(context.Background(), &utilitiestest.Executor{Outputs: []string{fakeInfoOutput}})
+	if err != nil {
+		t.Errorf("expectation failed: info returned an error: %v", err)
+		return
+	}
+
+	entries, err := info.Entries()
+	if err != nil {
+		t.Errorf("expectation failed: entries returned an error: %v", err)
+	} else if result := fmt.Sprintf("%v", entries); result != "[2001:db8::1 2001:db8::2]" {
+		t.Errorf("unexpected entries: %s", result)
+	} else if _, ok := entries[0].(set.HashIPEntry); !ok {
+		t.Errorf("unexpected entry type: %T", entries[0])
+	}
+
+	info.Members = append(info.Members, SetMember{Entry: "1.1.1.1,80"})
+	if _, err := info.Entries(); err == nil {
+		t.Error("expectation failed: invalid members should return an error")
+	}
+}
+
+func TestSetInfoEntriesICMP(t *testing.T) {
+	info := SetInfo{Name: "ports", Type: set.SetTypeHashIPPort, Members: []SetMember{{Entry: "1.1.1.1,icmp:8/0"}, {Entry: "2001:db8::1,icmpv6:128/0"}, {Entry: "1.1.1.1,tcp:80"}}}
+
+	if entries, err := info.Entries(); err != nil {
+		t.Errorf("expectation failed: entries returned an error: %v", err)
+	} else if result := fmt.Sprintf("%v", entries); result != "[1.1.1.1,icmp:8/0 2001:db8::1,icmpv6:128/0 1.1.1.1,tcp:80]" {
+		t.Errorf("unexpected entries: %s", result)
+	}
+}
+
+func TestParseSKBMark(t *testing.T) {
+	type test struct {
+		in      string
+		expects string
+	}
+
+	tests := []test{
+		{"0x10/0xff", "16 255 <nil>"},
+		{"0x10", "16 4294967295 <nil>"},
+		{"16/255", "16 255 <nil>"},
+	}
+
+	for i, test := range tests {
+		mark, mask, err := parseSKBMark(test.in)
+		if result := fmt.Sprintf("%d %d %v", mark, mask, err); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		}
+	}
+
+	if _, _, err := parseSKBMark("invalid"); err == nil {
+		t.Error("expectation failed: invalid skbmark should return an error")
+	}
+}
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
 	"github.com/francescocolleoni/go-ipset/utilities"
 )
 
@@ -12,6 +16,11 @@ import (
 type ListSet struct {
 	Command CommandName
 	Name    string
+
+	// Options.
+	Terse   bool                      // List only headers, without members.
+	Filter  *regexp.Regexp            // Sets returns only sets whose name matches Filter; all sets if nil.
+	Members func(header SetInfo) bool // Sets lists members only of sets whose header is accepted, ignoring Terse.
 }
 
 // NewListSet returns a list set command.
@@ -21,12 +30,15 @@ func NewListSet(name string) *ListSet {
 
 // ListSet implementation of TranslateToIPSetArgs.
 func (c *ListSet) TranslateToIPSetArgs() []string {
-	name := strings.Trim(c.Name, " \n")
-	if name == "" {
-		return []string{c.Command.String()}
-	} else {
-		return []string{c.Command.String(), name}
+	out := []string{c.Command.String()}
+	if name := strings.Trim(c.Name, " \n"); name != "" {
+		out = append(out, name)
+	}
+
+	if c.Terse {
+		out = append(out, "-terse")
 	}
+	return out
 }
 
 // ListSet implementation of ValidateOptions.
@@ -35,32 +47,177 @@ func (c *ListSet) IncludesMandatoryOptions() bool {
 	return strings.Trim(c.Name, " \n") != ""
 }
 
+// ListSet implementation of Validate.
+// Name is optional, because all sets are listed if it is empty; Run and Info require it.
+func (c *ListSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameList); err != nil {
+		return err
+	} else if strings.Trim(c.Name, " \n") == "" {
+		return nil
+	}
+	return validateSetName("name", c.Name)
+}
+
 // Run executes the list set command and returns ip addresses contained in the target set.
 func (c *ListSet) Run() ([]string, error) {
-	args := c.TranslateToIPSetArgs()
-	args = append(args, "-output", "xml")
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes the list set command and returns ip addresses contained in the target set.
+// ipset is killed if ctx is done before it exits.
+func (c *ListSet) RunContext(ctx context.Context) ([]string, error) {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes the list set command using a given executor and returns ip addresses contained in the target set.
+func (c *ListSet) RunWith(ctx context.Context, executor utilities.Executor) ([]string, error) {
+	xmlSet, err := c.listTargetSet(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, len(xmlSet.Members))
+	for i, member := range xmlSet.Members {
+		members[i] = member.Element
+	}
+	return members, nil
+}
 
-	out, err := utilities.RunIPSet(args...)
+// Info executes the list set command and returns header and members of the target set.
+func (c *ListSet) Info() (*SetInfo, error) {
+	return c.InfoContext(context.Background())
+}
+
+// InfoContext executes the list set command and returns header and members of the target set.
+// ipset is killed if ctx is done before it exits.
+func (c *ListSet) InfoContext(ctx context.Context) (*SetInfo, error) {
+	return c.InfoWith(ctx, utilities.DefaultExecutor)
+}
+
+// InfoWith executes the list set command using a given executor and returns header and members of the target set.
+func (c *ListSet) InfoWith(ctx context.Context, executor utilities.Executor) (*SetInfo, error) {
+	xmlSet, err := c.listTargetSet(ctx, executor)
 	if err != nil {
 		return nil, err
 	}
 
+	info, err := newSetInfo(xmlSet)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Sets executes the list set command and returns header and members of all listed sets;
+// all sets are listed if name is empty.
+func (c *ListSet) Sets() ([]SetInfo, error) {
+	return c.SetsContext(context.Background())
+}
+
+// SetsContext executes the list set command and returns header and members of all listed sets.
+// ipset is killed if ctx is done before it exits.
+func (c *ListSet) SetsContext(ctx context.Context) ([]SetInfo, error) {
+	return c.SetsWith(ctx, utilities.DefaultExecutor)
+}
+
+// SetsWith executes the list set command using a given executor and returns header and members of all listed sets.
+// With Filter or Members, headers are listed first and members are then listed set by set, so that members
+// of sets that are not returned are never listed; sets destroyed in the meantime are skipped.
+func (c *ListSet) SetsWith(ctx context.Context, executor utilities.Executor) ([]SetInfo, error) {
+	if c.Filter != nil || c.Members != nil {
+		return c.filteredSets(ctx, executor)
+	}
+
+	xmlOut, err := c.list(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SetInfo, len(xmlOut.Sets))
+	for i, xmlSet := range xmlOut.Sets {
+		if out[i], err = newSetInfo(xmlSet); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Support.
+
+// list runs ipset list with XML output and decodes its result.
+func (c *ListSet) list(ctx context.Context, executor utilities.Executor) (OxmlIPSets, error) {
+	if err := c.Validate(); err != nil {
+		return OxmlIPSets{}, err
+	}
+
+	args := c.TranslateToIPSetArgs()
+	args = append(args, "-output", "xml")
+
 	var xmlOut OxmlIPSets
+	out, err := utilities.RunIPSetWith(ctx, executor, args...)
+	if err != nil {
+		return xmlOut, out.Error
+	}
+
 	if err := xml.Unmarshal([]byte(out.Out), &xmlOut); err != nil {
-		return nil, err // Cannot decode output.
+		return xmlOut, err // Cannot decode output.
 	}
+	return xmlOut, nil
+}
 
-	for _, set := range xmlOut.Sets {
-		if set.Name != c.Name {
+// filteredSets lists headers of all sets, then members of sets matching Filter accepted by Members.
+func (c *ListSet) filteredSets(ctx context.Context, executor utilities.Executor) ([]SetInfo, error) {
+	headers := *c
+	headers.Terse = true
+	headers.Filter, headers.Members = nil, nil
+
+	sets, err := headers.SetsWith(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []SetInfo{}
+	for _, info := range sets {
+		if c.Filter != nil && !c.Filter.MatchString(info.Name) {
 			continue
 		}
 
-		members := make([]string, len(set.Members))
-		for i, member := range set.Members {
-			members[i] = member.Element
+		listMembers := !c.Terse
+		if c.Members != nil {
+			listMembers = c.Members(info)
+		}
+		if !listMembers {
+			out = append(out, info)
+			continue
+		}
+
+		full, err := NewListSet(info.Name).InfoWith(ctx, executor)
+		if errors.Is(err, liberrors.ErrIPSetNoSuchSet) {
+			continue // The set was destroyed after being listed.
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, *full)
+	}
+	return out, nil
+}
+
+// listTargetSet runs ipset list and returns the XML representation of the set named c.Name.
+func (c *ListSet) listTargetSet(ctx context.Context, executor utilities.Executor) (OxmlIPSet, error) {
+	if err := validateSetName("name", c.Name); err != nil {
+		return OxmlIPSet{}, err
+	}
+
+	xmlOut, err := c.list(ctx, executor)
+	if err != nil {
+		return OxmlIPSet{}, err
+	}
+
+	for _, set := range xmlOut.Sets {
+		if set.Name == c.Name {
+			return set, nil
 		}
-		return members, nil
 	}
 
-	return nil, fmt.Errorf(`set named "%s" cannot be found`, c.Name)
+	return OxmlIPSet{}, fmt.Errorf(`%w: set named "%s" cannot be found`, liberrors.ErrIPSetNoSuchSet, c.Name)
 }
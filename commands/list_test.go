@@ -1,13 +1,17 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/francescocolleoni/go-ipset/set"
 	"github.com/francescocolleoni/go-ipset/utilities"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
 )
 
 func TestListSetTranslateToCommandLine(t *testing.T) {
@@ -74,6 +78,59 @@ func TestListSetValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestListSetSetsFilter(t *testing.T) {
+	type test struct {
+		terse   bool
+		members func(header SetInfo) bool
+		sets    string
+		calls   string
+	}
+
+	headers := `<ipsets>` +
+		`<ipset name="web"><type>hash:ip</type><header><counters/></header><members></members></ipset>` +
+		`<ipset name="web-old"><type>hash:ip</type><header><counters/></header><members></members></ipset>` +
+		`<ipset name="webhooks"><type>hash:ip</type><header></header><members></members></ipset>` +
+		`<ipset name="db"><type>hash:ip</type><header><counters/></header><members></members></ipset>` +
+		`</ipsets>`
+	outputs := []string{headers, fakeListOutput("web", "hash:ip", "1.1.1.1"), "ipset v7.1: The set with the given name does not exist\n", fakeListOutput("webhooks", "hash:ip", "2.2.2.2")}
+	failure := errors.New("exit status 1")
+	counters := func(header SetInfo) bool { return header.Header.UseCounters }
+
+	tests := []test{
+		// Members of matching sets are listed one by one, skipping sets destroyed in the meantime.
+		{false, nil, "[web:1 webhooks:1]", "[[list -terse -output xml] [list web -output xml] [list web-old -output xml] [list webhooks -output xml]]"},
+		// Only headers of matching sets are listed.
+		{true, nil, "[web:0 web-old:0 webhooks:0]", "[[list -terse -output xml]]"},
+		// Members are listed only for sets accepted by Members.
+		{true, counters, "[web:1 webhooks:0]", "[[list -terse -output xml] [list web -output xml] [list web-old -output xml]]"},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: outputs, Errors: []error{nil, nil, failure, nil}}
+		list := NewListSet("")
+		list.Terse = test.terse
+		list.Filter = regexp.MustCompile("^web")
+		list.Members = test.members
+
+		sets, err := list.SetsWith(context.Background(), executor)
+		if err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+			continue
+		}
+
+		names := []string{}
+		for _, info := range sets {
+			names = append(names, fmt.Sprintf("%s:%d", info.Name, len(info.Members)))
+		}
+		if result := fmt.Sprint(names); result != test.sets {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.sets)
+		} else if result := fmt.Sprint(executor.Calls); result != test.calls {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.calls)
+		}
+	}
+}
+
 func TestListSet(t *testing.T) {
 	setName := "testset"
 	utilities.RunIPSet("destroy", setName)
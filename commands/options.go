@@ -30,8 +30,14 @@ func flagOption(argName string, flag bool) []string {
 }
 
 // netmaskOption returns formatted ipset option netmask.
-func netmaskOption(value int) []string {
-	if value >= 1 && value <= 32 {
+// Netmask values must be in [1, 32] for inet sets and in [1, 128] for inet6 sets.
+func netmaskOption(value int, protocol ProtocolFamily) []string {
+	maxValue := 32
+	if protocol == ProtocolFamilyINet6 {
+		maxValue = 128
+	}
+
+	if value >= 1 && value <= maxValue {
 		return intOption("netmask", value)
 	} else {
 		return []string{}
@@ -67,6 +73,11 @@ func maxElementsOption(value int) []string {
 	return intOption("maxelem", value)
 }
 
+// bucketSizeOption returns formatted ipset option bucketsize.
+func bucketSizeOption(value int) []string {
+	return intOption("bucketsize", value)
+}
+
 // countersOption returns formatted ipset option counters.
 func countersOption(flag bool) []string {
 	return flagOption("counters", flag)
@@ -146,7 +157,9 @@ func commentFlagOption(flag bool) []string {
 	return flagOption("comment", flag)
 }
 
-// commentOption returns formatted ipset option comment.
+// commentOption returns formatted ipset option comment, which should be used with add command.
+// Quotes and backslashes are removed, because ipset does not accept them in comments;
+// the comment is not quoted, since arguments are not parsed by a shell.
 func commentOption(comment string) []string {
 	comment = strings.Trim(comment, " \n")
 	for strings.ContainsAny(comment, `\"`) {
@@ -157,10 +170,62 @@ func commentOption(comment string) []string {
 	if comment == "" {
 		return []string{}
 	} else {
-		return []string{"comment", fmt.Sprintf(`"%s"`, comment)}
+		return []string{"comment", comment}
 	}
 }
 
+// counterOption returns formatted ipset option packets or bytes, which should be used with add command.
+func counterOption(argName string, value uint64) []string {
+	if value == 0 {
+		return []string{}
+	} else {
+		return []string{argName, strconv.FormatUint(value, 10)}
+	}
+}
+
+// skbMarkOption returns formatted ipset option skbmark, formatted as mark[/mask].
+// Mask is omitted if it is 0 or 0xffffffff.
+func skbMarkOption(mark, mask uint32) []string {
+	if mark == 0 && mask == 0 {
+		return []string{}
+	} else if mask == 0 || mask == 0xffffffff {
+		return []string{"skbmark", fmt.Sprintf("0x%x", mark)}
+	} else {
+		return []string{"skbmark", fmt.Sprintf("0x%x/0x%x", mark, mask)}
+	}
+}
+
+// skbPrioOption returns formatted ipset option skbprio.
+// Parameter prio must be formatted as major:minor, where major and minor are hexadecimal values.
+// Any other format will return an empty array of arguments.
+func skbPrioOption(prio string) []string {
+	if matchesTarget(prio, ":", skbPrioMatch, skbPrioMatch) {
+		return []string{"skbprio", prio}
+	} else {
+		return []string{}
+	}
+}
+
+// skbQueueOption returns formatted ipset option skbqueue.
+// Values higher than 65535 will return an empty array of arguments.
+func skbQueueOption(value int) []string {
+	if value > 65535 {
+		return []string{}
+	} else {
+		return intOption("skbqueue", value)
+	}
+}
+
+// existOption returns formatted ipset global option -exist.
+func existOption(flag bool) []string {
+	return flagOption("-exist", flag)
+}
+
+// noMatchOption returns formatted ipset option nomatch.
+func noMatchOption(flag bool) []string {
+	return flagOption("nomatch", flag)
+}
+
 // matchesTarget returns true if the regex built by joining matchComponents with separator matches target.
 func matchesTarget(target, separator string, matchComponents ...string) bool {
 	if len(matchComponents) <= 0 {
@@ -178,11 +243,30 @@ const ipCidrRangeMatch = `^(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:2
 const portRangeMatch = `^\d+-\d+$`
 
 // Add / Delete / Test matches.
-const ipMatch = `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`
-const ipCidrMatch = `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)/([1-9]|1[0-9]|2[0-9]|3[0-2])`
+// Matches ipMatch and ipCidrMatch accept both IPv4 and IPv6 addresses, while bitmap sets only support IPv4.
+const ipv4Match = `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`
+const ipv4CidrMatch = ipv4Match + `/(?:[1-9]|1[0-9]|2[0-9]|3[0-2])`
+const ipv6Match = `(?:` +
+	`(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,7}:|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,5}(?::[0-9a-fA-F]{1,4}){1,2}|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,4}(?::[0-9a-fA-F]{1,4}){1,3}|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,3}(?::[0-9a-fA-F]{1,4}){1,4}|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,2}(?::[0-9a-fA-F]{1,4}){1,5}|` +
+	`[0-9a-fA-F]{1,4}:(?::[0-9a-fA-F]{1,4}){1,6}|` +
+	`:(?:(?::[0-9a-fA-F]{1,4}){1,7}|:)|` +
+	`(?:[0-9a-fA-F]{1,4}:){6}` + ipv4Match + `|` +
+	`(?:[0-9a-fA-F]{1,4}:){1,5}:` + ipv4Match + `|` +
+	`::(?:[fF]{4}(?::0{1,4})?:)?` + ipv4Match +
+	`)`
+const ipv6CidrMatch = ipv6Match + `/(?:[1-9]|[1-9][0-9]|1[01][0-9]|12[0-8])`
+const ipMatch = `(?:` + ipv4Match + `|` + ipv6Match + `)`
+const ipCidrMatch = `(?:` + ipv4CidrMatch + `|` + ipv6CidrMatch + `)`
 const macMatch = `[a-zA-Z0-9]{2}:[a-zA-Z0-9]{2}:[a-zA-Z0-9]{2}:[a-zA-Z0-9]{2}:[a-zA-Z0-9]{2}:[a-zA-Z0-9]{2}`
 const portMatch = `\d+`
 const protoMatch = `.+`
-const markMatch = `\d+`
+const markMatch = `(?:0[xX][0-9a-fA-F]+|\d+)`
 const ifaceMatch = `.+`
 const physdevMatch = `physdev`
+const skbPrioMatch = `[0-9a-fA-F]{1,4}`
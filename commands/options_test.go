@@ -18,8 +18,11 @@ func TestOptions(t *testing.T) {
 		{handler: func() []string { return timeoutOption(0) }},
 		{func() []string { return timeoutOption(10) }, []string{"timeout", "10"}},
 
-		{handler: func() []string { return netmaskOption(0) }},
-		{func() []string { return netmaskOption(10) }, []string{"netmask", "10"}},
+		{handler: func() []string { return netmaskOption(0, ProtocolFamilyINet) }},
+		{func() []string { return netmaskOption(10, ProtocolFamilyINet) }, []string{"netmask", "10"}},
+		{handler: func() []string { return netmaskOption(64, ProtocolFamilyINet) }},
+		{func() []string { return netmaskOption(64, ProtocolFamilyINet6) }, []string{"netmask", "64"}},
+		{handler: func() []string { return netmaskOption(129, ProtocolFamilyINet6) }},
 
 		{handler: func() []string { return markmaskOption(-1) }},
 		{func() []string { return markmaskOption(10) }, []string{"markmask", "10"}},
@@ -46,7 +49,26 @@ func TestOptions(t *testing.T) {
 		{func() []string { return commentFlagOption(true) }, []string{"comment"}},
 
 		{handler: func() []string { return commentOption("") }},
-		{func() []string { return commentOption(`this is a \"comment"`) }, []string{"comment", `"this is a comment"`}},
+		{func() []string { return commentOption(`this is a \"comment"`) }, []string{"comment", "this is a comment"}},
+
+		{handler: func() []string { return counterOption("packets", 0) }},
+		{func() []string { return counterOption("bytes", 1<<40) }, []string{"bytes", "1099511627776"}},
+
+		{handler: func() []string { return skbMarkOption(0, 0) }},
+		{func() []string { return skbMarkOption(16, 0) }, []string{"skbmark", "0x10"}},
+		{func() []string { return skbMarkOption(16, 0xffffffff) }, []string{"skbmark", "0x10"}},
+		{func() []string { return skbMarkOption(16, 0xff) }, []string{"skbmark", "0x10/0xff"}},
+
+		{handler: func() []string { return skbPrioOption("") }},
+		{handler: func() []string { return skbPrioOption("1:invalid") }},
+		{func() []string { return skbPrioOption("1:a") }, []string{"skbprio", "1:a"}},
+
+		{handler: func() []string { return skbQueueOption(0) }},
+		{handler: func() []string { return skbQueueOption(65536) }},
+		{func() []string { return skbQueueOption(3) }, []string{"skbqueue", "3"}},
+
+		{handler: func() []string { return noMatchOption(false) }},
+		{func() []string { return noMatchOption(true) }, []string{"nomatch"}},
 
 		{handler: func() []string { return rangeIPOption("invalid range") }},
 		{handler: func() []string { return rangePortOption("invalid range") }},
@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// DesiredSet describes a set that should exist, together with all of its entries.
+type DesiredSet struct {
+	Create  *CreateSet
+	Entries []string // Compared with members in the canonical form of set.ParseEntry (ex.: 1.1.1.1,80 matches 1.1.1.1,tcp:80).
+}
+
+// ReconcileOptions defines how a Client reconciles sets.
+type ReconcileOptions struct {
+	DryRun           bool // Return the plan without executing it.
+	DestroyUnmanaged bool // Destroy sets that are not desired.
+}
+
+// ReconcileAction defines a change of a ReconcilePlan.
+type ReconcileAction int
+
+const (
+	ReconcileActionCreate   = iota // Create a missing set.
+	ReconcileActionRecreate        // Replace a set whose options changed.
+	ReconcileActionAdd             // Add a missing entry.
+	ReconcileActionDelete          // Delete an entry that is not desired.
+	ReconcileActionDestroy         // Destroy a set that is not desired.
+)
+
+// String returns the name of a given ReconcileAction a.
+func (a ReconcileAction) String() string {
+	switch a {
+	case ReconcileActionCreate:
+		return "create"
+	case ReconcileActionRecreate:
+		return "recreate"
+	case ReconcileActionAdd:
+		return "add"
+	case ReconcileActionDelete:
+		return "delete"
+	case ReconcileActionDestroy:
+		return "destroy"
+
+	default:
+		return "" // Unsupported action
+	}
+}
+
+// ReconcileStep describes a single change of a ReconcilePlan.
+type ReconcileStep struct {
+	Action ReconcileAction
+	Name   string
+	Entry  string // Only for add and delete.
+	Reason string // Only for recreate, describes the option that changed.
+}
+
+// String returns a description of s.
+func (s ReconcileStep) String() string {
+	switch s.Action {
+	case ReconcileActionAdd, ReconcileActionDelete:
+		return fmt.Sprintf("%v %s %s", s.Action, s.Name, s.Entry)
+	case ReconcileActionRecreate:
+		return fmt.Sprintf("%v %s (%s)", s.Action, s.Name, s.Reason)
+	default:
+		return fmt.Sprintf("%v %s", s.Action, s.Name)
+	}
+}
+
+// ReconcilePlan describes the changes that converge current sets to desired ones.
+type ReconcilePlan struct {
+	Steps  []ReconcileStep
+	stages []reconcileStage
+}
+
+// IsEmpty returns true if current sets already match desired ones.
+func (p *ReconcilePlan) IsEmpty() bool {
+	return len(p.Steps) == 0
+}
+
+// Reconcile converges sets listed by ipset to desired ones, and returns the plan of changes:
+// missing sets are created, sets whose options changed are recreated, entries that are not desired
+// are deleted and missing entries are added; sets that are not desired are destroyed only if
+// options.DestroyUnmanaged is true.
+// Sets are created in the order they are desired, so that sets included in list:set sets should be listed first.
+// Sets are recreated as in ReplaceSetContents, through a temporary set swapped with the existing one, so that
+// references from iptables rules and list:set sets are kept; sets whose type or family changed cannot be
+// swapped, and they are destroyed and created again instead, failing if they are referenced.
+// The plan is run in order, unless options.DryRun is true: changes between recreations are run with batches,
+// with -exist if c.Exist is true. ipset restore is not transactional: if a command fails, previous ones
+// are not reverted.
+func (c *Client) Reconcile(ctx context.Context, desired []DesiredSet, options ReconcileOptions) (*ReconcilePlan, error) {
+	current, err := NewListSet("").SetsWith(ctx, utilities.ExecutorOrDefault(c.Executor))
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := newReconcilePlan(desired, current, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun {
+		return plan, nil
+	}
+
+	for _, stage := range plan.stages {
+		if stage.replace != nil {
+			err = c.replaceSet(ctx, stage.replace.Create.Name, *stage.replace.Create, stage.replace.Entries)
+		} else {
+			err = c.RunBatch(ctx, stage.batch)
+		}
+
+		if err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// Support.
+
+// reconcileStage defines commands of a ReconcilePlan that are run together.
+type reconcileStage struct {
+	batch   *Batch
+	replace *DesiredSet // Set replaced through a temporary set, instead of running batch.
+}
+
+// newReconcilePlan returns the plan that converges sets current to sets desired.
+func newReconcilePlan(desired []DesiredSet, current []SetInfo, options ReconcileOptions) (*ReconcilePlan, error) {
+	out := &ReconcilePlan{Steps: []ReconcileStep{}, stages: []reconcileStage{}}
+
+	currentIndexes := map[string]int{}
+	for i, info := range current {
+		currentIndexes[info.Name] = i
+	}
+
+	desiredNames := map[string]bool{}
+	for _, d := range desired {
+		if d.Create == nil {
+			return nil, fmt.Errorf("desired set without create command")
+		}
+
+		name := d.Create.Name
+		if desiredNames[name] {
+			return nil, fmt.Errorf(`set "%s" is desired more than once`, name)
+		}
+		desiredNames[name] = true
+
+		entries, err := canonicalEntries(d.Create.Type, d.Entries)
+		if err != nil {
+			return nil, fmt.Errorf(`desired set "%s": %w`, name, err)
+		}
+
+		i, found := currentIndexes[name]
+		if !found {
+			out.add(ReconcileStep{Action: ReconcileActionCreate, Name: name}, d.Create)
+			out.addEntries(d.Create, entries)
+			continue
+		}
+
+		if reason := createDifference(d.Create, &current[i]); reason != "" {
+			step := ReconcileStep{Action: ReconcileActionRecreate, Name: name, Reason: reason}
+			if d.Create.Type.IsSwappableWith(current[i].Type) && d.Create.ProtocolFamily.String() == current[i].Header.ProtocolFamily.String() {
+				out.replace(step, DesiredSet{Create: d.Create, Entries: entries})
+			} else {
+				out.add(step, NewDestroySet(name), d.Create)
+			}
+			out.addEntries(d.Create, entries)
+			continue
+		}
+
+		// Entries are deleted before adding new ones, so that sets limited by maxelem do not become full.
+		desiredEntries := map[string]bool{}
+		for _, entry := range entries {
+			desiredEntries[entry] = true
+		}
+		members := map[string]bool{}
+		for _, member := range current[i].Members {
+			entry := canonicalEntry(d.Create.Type, member.Entry)
+			members[entry] = true
+			if !desiredEntries[entry] {
+				step := ReconcileStep{Action: ReconcileActionDelete, Name: name, Entry: member.Entry}
+				out.add(step, NewDeleteEntry(name, d.Create.Type, member.Entry))
+			}
+		}
+
+		missing := []string{}
+		for _, entry := range entries {
+			if !members[entry] {
+				missing = append(missing, entry)
+			}
+		}
+		out.addEntries(d.Create, missing)
+	}
+
+	if options.DestroyUnmanaged {
+		// list:set sets are destroyed first, because they may reference other unmanaged sets.
+		for _, lists := range []bool{true, false} {
+			for _, info := range current {
+				if !desiredNames[info.Name] && (info.Type == set.SetTypeListSet) == lists {
+					out.add(ReconcileStep{Action: ReconcileActionDestroy, Name: info.Name}, NewDestroySet(info.Name))
+				}
+			}
+		}
+	}
+
+	// Validate all commands before changing anything.
+	for _, stage := range out.stages {
+		batch := stage.batch
+		if stage.replace != nil {
+			batch = NewBatch().Create(stage.replace.Create)
+			for _, entry := range stage.replace.Entries {
+				batch.Entry(NewAddEntry(stage.replace.Create.Name, stage.replace.Create.Type, entry))
+			}
+		}
+
+		if _, err := batch.TranslateToIPSetRestoreInput(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// add appends a step to p, together with the commands that apply it.
+func (p *ReconcilePlan) add(step ReconcileStep, commands ...Command) {
+	p.Steps = append(p.Steps, step)
+	if n := len(p.stages); n == 0 || p.stages[n-1].replace != nil {
+		p.stages = append(p.stages, reconcileStage{batch: NewBatch()})
+	}
+
+	batch := p.stages[len(p.stages)-1].batch
+	batch.commands = append(batch.commands, commands...)
+}
+
+// replace appends to p a step that replaces a set with desired set d, through a temporary set.
+func (p *ReconcilePlan) replace(step ReconcileStep, d DesiredSet) {
+	p.Steps = append(p.Steps, step)
+	p.stages = append(p.stages, reconcileStage{replace: &d})
+}
+
+// addEntries appends to p the steps that add entries to a set created by c.
+// Entries of sets replaced through a temporary set are already added by the replacement.
+func (p *ReconcilePlan) addEntries(c *CreateSet, entries []string) {
+	replaced := len(p.stages) > 0 && p.stages[len(p.stages)-1].replace != nil
+	for _, entry := range entries {
+		step := ReconcileStep{Action: ReconcileActionAdd, Name: c.Name, Entry: entry}
+		if replaced {
+			p.Steps = append(p.Steps, step)
+		} else {
+			p.add(step, NewAddEntry(c.Name, c.Type, entry))
+		}
+	}
+}
+
+// canonicalEntries returns entries of a set of type setType in canonical form, without duplicates,
+// or an error if any of them is invalid.
+func canonicalEntries(setType set.SetType, entries []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		parsed, err := set.ParseEntry(setType, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if canonical := parsed.String(); !seen[canonical] {
+			seen[canonical] = true
+			out = append(out, canonical)
+		}
+	}
+	return out, nil
+}
+
+// canonicalEntry returns an entry of a set of type setType in canonical form,
+// or the trimmed entry if it cannot be parsed.
+func canonicalEntry(setType set.SetType, entry string) string {
+	if parsed, err := set.ParseEntry(setType, entry); err == nil {
+		return parsed.String()
+	}
+	return strings.TrimSpace(entry)
+}
+
+// createDifference returns the name of the first option of a create command c that differs from
+// the header of an existing set, or an empty string if the set can be kept.
+// Options that are zero in c (ex.: maxelem, netmask) are ignored, since the kernel picks their values;
+// hashsize and bucketsize are always ignored, since they only tune a hash that the kernel may resize.
+func createDifference(c *CreateSet, info *SetInfo) string {
+	header := info.Header
+	switch {
+	case c.Type != info.Type:
+		return "type"
+	case header.ProtocolFamily != ProtocolFamilyDefault && c.ProtocolFamily.String() != header.ProtocolFamily.String():
+		return "family"
+	case c.IPRange != "" && normalizeIPRange(c.IPRange) != normalizeIPRange(header.Range):
+		return "range"
+	case c.PortRange != "" && c.PortRange != header.Range:
+		return "range"
+	case c.NetMask != 0 && c.NetMask != header.NetMask:
+		return "netmask"
+	case c.MarkMask != 0 && c.MarkMask != header.MarkMask:
+		return "markmask"
+	case c.MaxElements != 0 && c.MaxElements != header.MaxElements:
+		return "maxelem"
+	case c.Size != 0 && c.Size != header.Size:
+		return "size"
+	case c.Timeout != header.Timeout:
+		return "timeout"
+	case c.UseCounters != header.UseCounters:
+		return "counters"
+	case c.AllowsComments != header.AllowsComments:
+		return "comment"
+	case c.UseSKBInfo != header.UseSKBInfo:
+		return "skbinfo"
+	case c.ForceAdd != header.ForceAdd:
+		return "forceadd"
+	default:
+		return ""
+	}
+}
+
+// normalizeIPRange returns an IPv4 range formatted as fromip-toip, as listed by ipset;
+// ranges expressed as ip/cidr are converted, while any other value is returned unchanged.
+func normalizeIPRange(in string) string {
+	_, network, err := net.ParseCIDR(in)
+	if err != nil || network.IP.To4() == nil {
+		return in
+	}
+
+	from := binary.BigEndian.Uint32(network.IP.To4())
+	to := from | ^binary.BigEndian.Uint32(net.IP(network.Mask).To4())
+
+	last := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(last, to)
+	return fmt.Sprintf("%v-%v", network.IP.To4(), last)
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestNewReconcilePlan(t *testing.T) {
+	type test struct {
+		desired []DesiredSet
+		current []SetInfo
+		options ReconcileOptions
+		expects string
+	}
+
+	hashIP := func(name string, timeout int, members ...string) SetInfo {
+		out := SetInfo{Name: name, Type: set.SetTypeHashIP, Header: SetHeader{ProtocolFamily: ProtocolFamilyINet, HashSize: 1024, MaxElements: 65536, Timeout: timeout}}
+		for _, member := range members {
+			out.Members = append(out.Members, SetMember{Entry: member})
+		}
+		return out
+	}
+	bitmapIP := SetInfo{Name: "b", Type: set.SetTypeBitmapIP, Header: SetHeader{Range: "192.168.0.0-192.168.255.255"}}
+	list := SetInfo{Name: "l", Type: set.SetTypeListSet, Header: SetHeader{Size: 8}}
+	ipPort := SetInfo{Name: "p", Type: set.SetTypeHashIPPort, Members: []SetMember{{Entry: "1.1.1.1,tcp:80"}, {Entry: "1.1.1.1,icmp:8/0"}}}
+	hashNet := SetInfo{Name: "n", Type: set.SetTypeHashNet, Members: []SetMember{{Entry: "2.2.2.2"}, {Entry: "10.0.0.0/8"}}}
+
+	tests := []test{
+		// Missing set.
+		{[]DesiredSet{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), []string{"1.1.1.1", "2.2.2.2"}}},
+			nil, ReconcileOptions{}, "[create a add a 1.1.1.1 add a 2.2.2.2]"},
+		// Up to date set.
+		{[]DesiredSet{{NewCreateHashIP("a", ProtocolFamilyINet, 4096, 0, 0, 0, false, false, false), []string{"1.1.1.1"}}},
+			[]SetInfo{hashIP("a", 0, "1.1.1.1")}, ReconcileOptions{}, "[]"},
+		// Members to add and delete.
+		{[]DesiredSet{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), []string{"1.1.1.1", "3.3.3.3", "3.3.3.3"}}},
+			[]SetInfo{hashIP("a", 0, "1.1.1.1", "2.2.2.2")}, ReconcileOptions{}, "[delete a 2.2.2.2 add a 3.3.3.3]"},
+		// Entries are compared in canonical form.
+		{[]DesiredSet{{NewCreateHashIPPort("p", ProtocolFamilyDefault, 0, 0, 0, false, false, false), []string{"1.1.1.1,80", "1.1.1.1,icmp:8/0"}}},
+			[]SetInfo{ipPort}, ReconcileOptions{}, "[]"},
+		{[]DesiredSet{{NewCreateHashNet("n", ProtocolFamilyDefault, 0, 0, 0, false, false, false), []string{"2.2.2.2/32", "10.1.2.3/8"}}},
+			[]SetInfo{hashNet}, ReconcileOptions{}, "[]"},
+		// Incompatible options.
+		{[]DesiredSet{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 300, false, false, false), []string{"1.1.1.1"}}},
+			[]SetInfo{hashIP("a", 0, "1.1.1.1")}, ReconcileOptions{}, "[recreate a (timeout) add a 1.1.1.1]"},
+		{[]DesiredSet{{NewCreateHashIP("a", ProtocolFamilyINet6, 0, 0, 0, 0, false, false, false), nil}},
+			[]SetInfo{hashIP("a", 0)}, ReconcileOptions{}, "[recreate a (family)]"},
+		{[]DesiredSet{{NewCreateHashNet("a", ProtocolFamilyDefault, 0, 0, 0, false, false, false), nil}},
+			[]SetInfo{hashIP("a", 0)}, ReconcileOptions{}, "[recreate a (type)]"},
+		// Ranges expressed as ip/cidr match ranges listed by ipset.
+		{[]DesiredSet{{NewCreateBitmapIP("b", "192.168.0.0/16", 0, 0, false, false, false), nil}},
+			[]SetInfo{bitmapIP}, ReconcileOptions{}, "[]"},
+		{[]DesiredSet{{NewCreateBitmapIP("b", "192.168.0.0/24", 0, 0, false, false, false), nil}},
+			[]SetInfo{bitmapIP}, ReconcileOptions{}, "[recreate b (range)]"},
+		// Unmanaged sets.
+		{nil, []SetInfo{hashIP("a", 0), list}, ReconcileOptions{}, "[]"},
+		{nil, []SetInfo{hashIP("a", 0), list}, ReconcileOptions{DestroyUnmanaged: true}, "[destroy l destroy a]"},
+	}
+
+	for i, test := range tests {
+		plan, err := newReconcilePlan(test.desired, test.current, test.options)
+		if err != nil {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, err)
+			continue
+		}
+
+		if result := fmt.Sprintf("%v", plan.Steps); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		} else if plan.IsEmpty() != (test.expects == "[]") {
+			t.Errorf("expectation %d failed: unexpected IsEmpty %v", i+1, plan.IsEmpty())
+		}
+	}
+
+	// Invalid desired sets.
+	invalid := [][]DesiredSet{
+		{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), []string{"invalid"}}},
+		{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), nil}, {NewCreateHashNet("a", ProtocolFamilyDefault, 0, 0, 0, false, false, false), nil}},
+		{{nil, []string{"1.1.1.1"}}},
+	}
+	for i, desired := range invalid {
+		if _, err := newReconcilePlan(desired, nil, ReconcileOptions{}); err == nil {
+			t.Errorf("expectation %d failed: invalid desired sets should return an error", i+1)
+		}
+	}
+}
+
+func TestClientReconcile(t *testing.T) {
+	desired := []DesiredSet{{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), []string{"1.1.1.1", "2.2.2.2"}}}
+	listOutput := fakeListOutput("a", "hash:ip", "1.1.1.1", "3.3.3.3")
+
+	// Dry run.
+	executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: []string{listOutput}}}
+	plan, err := NewClient(executor).Reconcile(context.Background(), desired, ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Errorf("dry run failed: %v", err)
+	} else if result := fmt.Sprintf("%v", plan.Steps); result != "[delete a 3.3.3.3 add a 2.2.2.2]" {
+		t.Errorf("unexpected plan: %s", result)
+	} else if len(executor.Calls) != 1 {
+		t.Errorf("dry run should only list sets: %v", executor.Calls)
+	}
+
+	// Apply.
+	executor = &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: []string{listOutput, ""}}}
+	if _, err := NewClient(executor).Reconcile(context.Background(), desired, ReconcileOptions{}); err != nil {
+		t.Errorf("reconcile failed: %v", err)
+	} else if result := fmt.Sprintf("%v", executor.Calls); result != "[[list -output xml] [restore]]" {
+		t.Errorf("unexpected executor calls: %s", result)
+	} else if expects := "del a 3.3.3.3\nadd a 2.2.2.2\n"; len(executor.Inputs) != 1 || executor.Inputs[0] != expects {
+		t.Errorf("unexpected restore input: %v != %s (expected)", executor.Inputs, expects)
+	}
+}
+
+func TestClientReconcileRecreate(t *testing.T) {
+	desired := []DesiredSet{
+		{NewCreateHashIP("a", ProtocolFamilyDefault, 0, 0, 0, 300, false, false, false), []string{"1.1.1.1"}},
+		{NewCreateHashIP("b", ProtocolFamilyDefault, 0, 0, 0, 0, false, false, false), []string{"2.2.2.2"}},
+	}
+	listOutput := fakeListOutput("a", "hash:ip", "1.1.1.1")
+
+	// Sets are recreated through a temporary set swapped with the existing one, instead of being destroyed.
+	executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: []string{listOutput, "", "", "", ""}}}
+	plan, err := NewClient(executor).Reconcile(context.Background(), desired, ReconcileOptions{})
+	if err != nil {
+		t.Errorf("reconcile failed: %v", err)
+		return
+	} else if result := fmt.Sprintf("%v", plan.Steps); result != "[recreate a (timeout) add a 1.1.1.1 create b add b 2.2.2.2]" {
+		t.Errorf("unexpected plan: %s", result)
+	}
+
+	if len(executor.Calls) != 5 || len(executor.Inputs) != 2 {
+		t.Errorf("unexpected executor calls: %v", executor.Calls)
+		return
+	}
+
+	tempName := executor.Calls[2][2]
+	expects := fmt.Sprintf("%v", [][]string{{"list", "-output", "xml"}, {"restore"}, {"swap", "a", tempName}, {"destroy", tempName}, {"restore"}})
+	if result := fmt.Sprintf("%v", executor.Calls); result != expects {
+		t.Errorf("unexpected executor calls: %s != %s (expected)", result, expects)
+	}
+
+	expectsInputs := []string{
+		fmt.Sprintf("create %s hash:ip timeout 300\nadd %s 1.1.1.1\n", tempName, tempName),
+		"create b hash:ip\nadd b 2.2.2.2\n",
+	}
+	if fmt.Sprintf("%q", executor.Inputs) != fmt.Sprintf("%q", expectsInputs) {
+		t.Errorf("unexpected restore inputs: %q != %q (expected)", executor.Inputs, expectsInputs)
+	}
+}
+
+func TestNormalizeIPRange(t *testing.T) {
+	tests := [][]string{
+		{"10.0.0.0/8", "10.0.0.0-10.255.255.255"},
+		{"10.1.2.3/24", "10.1.2.0-10.1.2.255"},
+		{"10.0.0.1-10.0.0.9", "10.0.0.1-10.0.0.9"},
+		{"invalid", "invalid"},
+	}
+
+	for i, test := range tests {
+		if result := normalizeIPRange(test[0]); result != test[1] {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test[1])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// RenameSet defines the ipset rename command.
+type RenameSet struct {
+	Command CommandName
+	Name    string
+	NewName string
+}
+
+// NewRenameSet returns a rename command, renaming set name as newName.
+func NewRenameSet(name, newName string) *RenameSet {
+	return &RenameSet{Command: CommandNameRename, Name: name, NewName: newName}
+}
+
+// RenameSet implementation of TranslateToIPSetArgs.
+func (c *RenameSet) TranslateToIPSetArgs() []string {
+	out := []string{c.Command.String()}
+	for _, name := range []string{c.Name, c.NewName} {
+		if name = strings.Trim(name, " \n"); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// RenameSet implementation of ValidateOptions.
+// This function will return true iif both names are not empty and the new name
+// is not longer than MaxSetNameLength characters.
+func (c *RenameSet) IncludesMandatoryOptions() bool {
+	newName := strings.Trim(c.NewName, " \n")
+	return strings.Trim(c.Name, " \n") != "" && newName != "" && len(newName) <= MaxSetNameLength
+}
+
+// RenameSet implementation of Validate.
+func (c *RenameSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameRename); err != nil {
+		return err
+	} else if err := validateSetName("name", c.Name); err != nil {
+		return err
+	}
+	return validateSetName("new set name", c.NewName)
+}
+
+// Run executes a RenameSet command.
+func (c *RenameSet) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a RenameSet command; ipset is killed if ctx is done before it exits.
+func (c *RenameSet) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a RenameSet command using a given executor.
+// Errors returned when the new name is already used or the set is referenced
+// match ErrIPSetSetExists and ErrIPSetSetInUse respectively.
+func (c *RenameSet) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	out, _ := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...)
+	return out.Error // Already matches errors derived from ipset messages.
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestRenameSetTranslateToCommandLine(t *testing.T) {
+	type test struct {
+		command *RenameSet
+		args    []string
+		valid   bool
+	}
+
+	tooLong := strings.Repeat("a", MaxSetNameLength+1)
+	tests := []test{
+		{NewRenameSet("a", "b"), []string{"rename", "a", "b"}, true},
+		{NewRenameSet("a\n", " b"), []string{"rename", "a", "b"}, true},
+		{NewRenameSet("a", strings.Repeat("a", MaxSetNameLength)), []string{"rename", "a", strings.Repeat("a", MaxSetNameLength)}, true},
+		{NewRenameSet("a", tooLong), []string{"rename", "a", tooLong}, false},
+		{NewRenameSet("a", ""), []string{"rename", "a"}, false},
+		{NewRenameSet("", ""), []string{"rename"}, false},
+	}
+
+	for i, test := range tests {
+		result := fmt.Sprintf("%v", test.command.TranslateToIPSetArgs())
+		expects := fmt.Sprintf("%v", test.args)
+		if result != expects {
+			t.Errorf("expectation failed (%d): %s != %s (expected)", i+1, result, expects)
+		}
+
+		if valid := test.command.IncludesMandatoryOptions(); valid != test.valid {
+			t.Errorf("expectation failed (%d): %v != %v (expected)", i+1, valid, test.valid)
+		}
+	}
+}
+
+func TestRenameSetRunWith(t *testing.T) {
+	type test struct {
+		command *RenameSet
+		output  string
+		expects error
+	}
+
+	tests := []test{
+		{NewRenameSet("a", "b"), "", nil},
+		{NewRenameSet("a", "b"), "ipset v7.1: Set cannot be renamed: a set with the new name already exists\n", liberrors.ErrIPSetSetExists},
+		{NewRenameSet("a", "b"), "ipset v7.1: Set cannot be renamed: it is in use by another system\n", liberrors.ErrIPSetSetInUse},
+		{NewRenameSet("a", "b"), "ipset v7.1: The set with the given name does not exist\n", liberrors.ErrIPSetNoSuchSet},
+		{NewRenameSet("a", strings.Repeat("a", MaxSetNameLength+1)), "", liberrors.ErrIPSetCommandIsInvalid},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: []string{test.output}}
+		if test.output != "" {
+			executor.Errors = []error{errors.New("exit status 1")}
+		}
+
+		if err := test.command.RunWith(context.Background(), executor); !errors.Is(err, test.expects) {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, err, test.expects)
+		}
+	}
+}
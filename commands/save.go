@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// SaveSet defines the ipset save command.
+type SaveSet struct {
+	Command CommandName
+	Name    string // Optional, all sets are saved if empty.
+}
+
+// SavedSet defines a set saved by ipset save, described by the commands that restore it.
+type SavedSet struct {
+	Create  *CreateSet
+	Entries []*AddTestDeleteEntry
+}
+
+// NewSaveSet returns a save command; all sets are saved if name is empty.
+func NewSaveSet(name string) *SaveSet {
+	return &SaveSet{Command: CommandNameSave, Name: name}
+}
+
+// SaveSet implementation of TranslateToIPSetArgs.
+func (c *SaveSet) TranslateToIPSetArgs() []string {
+	name := strings.Trim(c.Name, " \n")
+	if name == "" {
+		return []string{c.Command.String()}
+	} else {
+		return []string{c.Command.String(), name}
+	}
+}
+
+// SaveSet implementation of ValidateOptions.
+// This function always returns true, because name is optional.
+func (c *SaveSet) IncludesMandatoryOptions() bool {
+	return true
+}
+
+// SaveSet implementation of Validate.
+func (c *SaveSet) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameSave); err != nil {
+		return err
+	} else if strings.Trim(c.Name, " \n") == "" {
+		return nil // All sets are saved.
+	}
+	return validateSetName("name", c.Name)
+}
+
+// Run executes a SaveSet command.
+func (c *SaveSet) Run() ([]SavedSet, error) {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a SaveSet command; ipset is killed if ctx is done before it exits.
+func (c *SaveSet) RunContext(ctx context.Context) ([]SavedSet, error) {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a SaveSet command using a given executor.
+func (c *SaveSet) RunWith(ctx context.Context, executor utilities.Executor) ([]SavedSet, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	out, err := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...)
+	if err != nil {
+		return nil, out.Error
+	}
+
+	return ParseSave(strings.NewReader(out.Out))
+}
+
+// ParseSave parses the output of ipset save, returning the commands that create each set and add its entries.
+func ParseSave(r io.Reader) ([]SavedSet, error) {
+	out := []SavedSet{}
+	setIndexes := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := utilities.SplitIPSetLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("save line %d: %w", line, err)
+		}
+
+		switch args[0] {
+		case "create":
+			create, err := parseSaveCreate(args)
+			if err != nil {
+				return nil, fmt.Errorf("save line %d: %w", line, err)
+			}
+
+			setIndexes[create.Name] = len(out)
+			out = append(out, SavedSet{Create: create, Entries: []*AddTestDeleteEntry{}})
+
+		case "add":
+			if len(args) < 3 {
+				return nil, fmt.Errorf("save line %d: missing set name or entry", line)
+			}
+
+			i, found := setIndexes[args[1]]
+			if !found {
+				return nil, fmt.Errorf("save line %d: set %s is not created before its entries", line, args[1])
+			}
+
+			entry, err := parseSaveAdd(out[i].Create, args)
+			if err != nil {
+				return nil, fmt.Errorf("save line %d: %w", line, err)
+			}
+			out[i].Entries = append(out[i].Entries, entry)
+
+		default:
+			return nil, fmt.Errorf("save line %d: unsupported command %s", line, args[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Support.
+
+// parseSaveCreate returns the create command described by the arguments of a create line of ipset save.
+func parseSaveCreate(args []string) (*CreateSet, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("missing set name or type")
+	}
+
+	setType := set.SetTypeWithString(args[2])
+	if setType == set.SetTypeUnsupported {
+		return nil, fmt.Errorf("unsupported set type %s", args[2])
+	}
+
+	out := newCreateCommand(args[1], setType)
+	for i := 3; i < len(args); i++ {
+		option := args[i]
+		switch option {
+		case "counters":
+			out.UseCounters = true
+			continue
+		case "comment":
+			out.AllowsComments = true
+			continue
+		case "skbinfo":
+			out.UseSKBInfo = true
+			continue
+		case "forceadd":
+			out.ForceAdd = true
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("option %s requires a value", option)
+		}
+		value := args[i+1]
+		i++
+
+		switch option {
+		case "family":
+			switch value {
+			case "inet":
+				out.ProtocolFamily = ProtocolFamilyINet
+			case "inet6":
+				out.ProtocolFamily = ProtocolFamilyINet6
+			default:
+				return nil, fmt.Errorf("unsupported family %s", value)
+			}
+
+		case "range":
+			if setType == set.SetTypeBitmapPort {
+				out.PortRange = value
+			} else {
+				out.IPRange = value
+			}
+
+		case "netmask", "markmask", "hashsize", "maxelem", "bucketsize", "size", "timeout":
+			n, err := strconv.ParseInt(value, 0, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid value %s of option %s", value, option)
+			}
+
+			switch option {
+			case "netmask":
+				out.NetMask = int(n)
+			case "markmask":
+				out.MarkMask = int(n)
+			case "hashsize":
+				out.HashSize = int(n)
+			case "maxelem":
+				out.MaxElements = int(n)
+			case "bucketsize":
+				out.BucketSize = int(n)
+			case "size":
+				out.Size = int(n)
+			case "timeout":
+				out.Timeout = int(n)
+			}
+
+		case "initval":
+			// Seed of the hash, chosen at random by the kernel when the set is created: restoring it would make
+			// the hash of the new set predictable, so the new set gets its own seed.
+
+		default:
+			return nil, fmt.Errorf("unsupported create option %s", option)
+		}
+	}
+
+	return out, nil
+}
+
+// parseSaveAdd returns the add command described by the arguments of an add line of ipset save,
+// checking its options against the create command of its set.
+func parseSaveAdd(create *CreateSet, args []string) (*AddTestDeleteEntry, error) {
+	out := NewAddEntry(args[1], create.Type, args[2])
+
+	for i := 3; i < len(args); i++ {
+		option := args[i]
+		if option == "nomatch" {
+			out.NoMatch = true
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("option %s requires a value", option)
+		}
+		value := args[i+1]
+		i++
+
+		var err error
+		switch option {
+		case "timeout", "skbqueue":
+			var n int64
+			if n, err = strconv.ParseInt(value, 10, 32); err == nil && option == "timeout" {
+				out.Timeout = int(n)
+				out.Permanent = n == 0
+			} else if err == nil {
+				out.SKBQueue = int(n)
+			}
+		case "packets":
+			out.Packets, err = strconv.ParseUint(value, 10, 64)
+		case "bytes":
+			out.Bytes, err = strconv.ParseUint(value, 10, 64)
+		case "comment":
+			out.Comment = value
+		case "skbmark":
+			out.SKBMark, out.SKBMarkMask, err = parseSKBMark(value)
+		case "skbprio":
+			out.SKBPrio = value
+		default:
+			return nil, fmt.Errorf("unsupported entry option %s", option)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s of option %s", value, option)
+		}
+	}
+
+	if err := out.Validate(); err != nil {
+		return nil, err
+	} else if err := out.ValidateOptionsFor(create); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
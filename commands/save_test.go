@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+const fakeSaveOutput = `create blocklist hash:net family inet hashsize 1024 maxelem 65536 timeout 300 counters comment bucketsize 12 initval 0x5c2b9e47
+add blocklist 10.0.0.0/8 timeout 120 packets 0 bytes 0 comment "private network"
+add blocklist 1.1.1.1 timeout 0 packets 10 bytes 840
+create ports bitmap:port range 0-1024
+add ports 22
+create marks hash:ip,mark family inet markmask 0x0000ffff hashsize 64 maxelem 128
+add marks 1.1.1.1,0x10
+create all list:set size 8
+add all blocklist
+add all ports
+`
+
+func TestParseSave(t *testing.T) {
+	sets, err := ParseSave(strings.NewReader(fakeSaveOutput))
+	if err != nil {
+		t.Errorf("expectation failed: parse returned an error: %v", err)
+		return
+	} else if len(sets) != 4 {
+		t.Errorf("unexpected number of sets: %d", len(sets))
+		return
+	}
+
+	expects := []string{
+		"create blocklist hash:net family inet hashsize 1024 maxelem 65536 bucketsize 12 timeout 300 counters comment\n" +
+			"add blocklist 10.0.0.0/8 timeout 120 comment \"private network\"\n" +
+			"add blocklist 1.1.1.1 timeout 0 packets 10 bytes 840\n",
+		"create ports bitmap:port range 0-1024\n" +
+			"add ports 22\n",
+		"create marks hash:ip,mark family inet markmask 65535 hashsize 64 maxelem 128\n" +
+			"add marks 1.1.1.1,0x10\n",
+		"create all list:set size 8\n" +
+			"add all blocklist\n" +
+			"add all ports\n",
+	}
+
+	for i, s := range sets {
+		input, err := NewBatch().Restore(s).TranslateToIPSetRestoreInput()
+		if err != nil {
+			t.Errorf("expectation %d failed: restore returned an error: %v", i+1, err)
+		} else if input != expects[i] {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, input, expects[i])
+		}
+	}
+}
+
+func TestParseSaveErrors(t *testing.T) {
+	tests := []string{
+		"add blocklist 1.1.1.1",
+		"create blocklist hash:unknown",
+		"create blocklist hash:ip hashsize",
+		"create blocklist hash:ip family inet7",
+		"create blocklist hash:ip unknownoption 1",
+		"create blocklist hash:ip\nadd blocklist 1.1.1.1 comment \"unterminated",
+		"create blocklist hash:ip\nadd blocklist 1.1.1.1 unknownoption",
+		"create blocklist hash:ip\nadd blocklist 1.1.1.1 timeout 10",
+		"create blocklist hash:ip\nadd blocklist 1.1.1.1 comment text",
+		"create blocklist hash:ip\nadd blocklist 1.1.1.1 nomatch",
+		"create blocklist hash:ip skbinfo\nadd blocklist 1.1.1.1 skbmark invalid",
+		"create blocklist hash:ip\nadd blocklist invalid",
+		"swap a b",
+	}
+
+	for i, test := range tests {
+		if _, err := ParseSave(strings.NewReader(test)); err == nil {
+			t.Errorf("expectation %d failed: parse should return an error", i+1)
+		}
+	}
+}
+
+func TestSaveSetRunWith(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{fakeSaveOutput}}
+
+	sets, err := NewSaveSet("").RunWith(context.Background(), executor)
+	if err != nil {
+		t.Errorf("expectation failed: save returned an error: %v", err)
+	} else if len(sets) != 4 || len(sets[0].Entries) != 2 {
+		t.Errorf("unexpected saved sets: %v", sets)
+	}
+
+	NewSaveSet("blocklist").RunWith(context.Background(), executor)
+	if result := fmt.Sprintf("%v", executor.Calls); result != "[[save] [save blocklist]]" {
+		t.Errorf("unexpected executor calls: %s", result)
+	}
+}
+
+func TestParseSaveEntryOptions(t *testing.T) {
+	save := "create blocklist hash:net family inet hashsize 1024 maxelem 65536 timeout 300 counters comment skbinfo\n" +
+		`add blocklist 10.0.0.0/8 timeout 120 packets 5 bytes 420 comment "private network" skbmark 0x10/0xff skbprio 1:10 skbqueue 2` + "\n" +
+		"add blocklist 10.1.0.0/16 timeout 0 nomatch\n"
+
+	sets, err := ParseSave(strings.NewReader(save))
+	if err != nil {
+		t.Errorf("expectation failed: parse returned an error: %v", err)
+		return
+	}
+
+	expects := "create blocklist hash:net family inet hashsize 1024 maxelem 65536 timeout 300 counters comment skbinfo\n" +
+		`add blocklist 10.0.0.0/8 timeout 120 packets 5 bytes 420 comment "private network" skbmark 0x10/0xff skbprio 1:10 skbqueue 2` + "\n" +
+		"add blocklist 10.1.0.0/16 timeout 0 nomatch\n"
+	if input, err := NewBatch().Restore(sets[0]).TranslateToIPSetRestoreInput(); err != nil {
+		t.Errorf("expectation failed: restore returned an error: %v", err)
+	} else if input != expects {
+		t.Errorf("expectation failed: %s != %s (expected)", input, expects)
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// SwapSets defines the ipset swap command.
+type SwapSets struct {
+	Command   CommandName
+	Name      string
+	OtherName string
+}
+
+// NewSwapSets returns a swap command, exchanging the contents of sets name and otherName.
+func NewSwapSets(name, otherName string) *SwapSets {
+	return &SwapSets{Command: CommandNameSwap, Name: name, OtherName: otherName}
+}
+
+// SwapSets implementation of TranslateToIPSetArgs.
+func (c *SwapSets) TranslateToIPSetArgs() []string {
+	out := []string{c.Command.String()}
+	for _, name := range []string{c.Name, c.OtherName} {
+		if name = strings.Trim(name, " \n"); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// SwapSets implementation of ValidateOptions.
+// This function will return true iif both names are not empty.
+func (c *SwapSets) IncludesMandatoryOptions() bool {
+	return strings.Trim(c.Name, " \n") != "" && strings.Trim(c.OtherName, " \n") != ""
+}
+
+// SwapSets implementation of Validate.
+func (c *SwapSets) Validate() error {
+	if err := validateCommandName(c.Command, CommandNameSwap); err != nil {
+		return err
+	} else if err := validateSetName("name", c.Name); err != nil {
+		return err
+	}
+	return validateSetName("other set name", c.OtherName)
+}
+
+// Run executes a SwapSets command.
+func (c *SwapSets) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes a SwapSets command; ipset is killed if ctx is done before it exits.
+func (c *SwapSets) RunContext(ctx context.Context) error {
+	return c.RunWith(ctx, utilities.DefaultExecutor)
+}
+
+// RunWith executes a SwapSets command using a given executor.
+// Before swapping, both sets must exist (ErrIPSetNoSuchSet) and have compatible types
+// and families (ErrIPSetIncompatibleSets); the same errors are returned if ipset refuses the swap.
+func (c *SwapSets) RunWith(ctx context.Context, executor utilities.Executor) error {
+	if err := c.Validate(); err != nil {
+		return err
+	} else if err := c.checkSets(ctx, executor); err != nil {
+		return err
+	}
+
+	return c.swap(ctx, executor)
+}
+
+// Support.
+
+// swap runs ipset swap without pre-checks.
+func (c *SwapSets) swap(ctx context.Context, executor utilities.Executor) error {
+	out, _ := utilities.RunIPSetWith(ctx, executor, c.TranslateToIPSetArgs()...)
+	return out.Error // Already matches errors derived from ipset messages.
+}
+
+// checkSets returns an error if sets of c do not exist or cannot be swapped.
+func (c *SwapSets) checkSets(ctx context.Context, executor utilities.Executor) error {
+	list := NewListSet("")
+	list.Terse = true
+
+	sets, err := list.SetsWith(ctx, executor)
+	if err != nil {
+		return err
+	}
+
+	infos := make([]*SetInfo, 2)
+	for i, name := range []string{c.Name, c.OtherName} {
+		for j := range sets {
+			if sets[j].Name == name {
+				infos[i] = &sets[j]
+			}
+		}
+
+		if infos[i] == nil {
+			return fmt.Errorf(`%w: set named "%s" cannot be found`, liberrors.ErrIPSetNoSuchSet, name)
+		}
+	}
+
+	if !infos[0].Type.IsSwappableWith(infos[1].Type) {
+		return fmt.Errorf(`%w: set "%s" (%v) cannot be swapped with set "%s" (%v)`,
+			liberrors.ErrIPSetIncompatibleSets, c.Name, infos[0].Type, c.OtherName, infos[1].Type)
+	} else if infos[0].Header.ProtocolFamily.String() != infos[1].Header.ProtocolFamily.String() {
+		return fmt.Errorf(`%w: set "%s" (%v) cannot be swapped with set "%s" (%v)`,
+			liberrors.ErrIPSetIncompatibleSets, c.Name, infos[0].Header.ProtocolFamily, c.OtherName, infos[1].Header.ProtocolFamily)
+	}
+
+	return nil
+}
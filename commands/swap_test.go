@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestSwapSetsTranslateToCommandLine(t *testing.T) {
+	type test struct {
+		command *SwapSets
+		args    []string
+		valid   bool
+	}
+
+	tests := []test{
+		{NewSwapSets("a", "b"), []string{"swap", "a", "b"}, true},
+		{NewSwapSets(" a\n", "b"), []string{"swap", "a", "b"}, true},
+		{NewSwapSets("a", ""), []string{"swap", "a"}, false},
+		{NewSwapSets("", ""), []string{"swap"}, false},
+	}
+
+	for i, test := range tests {
+		result := fmt.Sprintf("%v", test.command.TranslateToIPSetArgs())
+		expects := fmt.Sprintf("%v", test.args)
+		if result != expects {
+			t.Errorf("expectation failed (%d): %s != %s (expected)", i+1, result, expects)
+		}
+
+		if valid := test.command.IncludesMandatoryOptions(); valid != test.valid {
+			t.Errorf("expectation failed (%d): %v != %v (expected)", i+1, valid, test.valid)
+		}
+	}
+}
+
+func TestSwapSetsRunWith(t *testing.T) {
+	sets := fakeTerseListOutput(
+		[]string{"live", "hash:ip", "inet"},
+		[]string{"staging", "hash:ip", "inet"},
+		[]string{"bitmap", "bitmap:ip", ""}, // Bitmap sets are listed without a family.
+		[]string{"nets", "hash:net", "inet"},
+		[]string{"live6", "hash:ip", "inet6"},
+	)
+
+	type test struct {
+		other   string
+		expects error
+	}
+
+	tests := []test{
+		{"staging", nil},
+		{"bitmap", nil},
+		{"nets", liberrors.ErrIPSetIncompatibleSets},
+		{"live6", liberrors.ErrIPSetIncompatibleSets},
+		{"missing", liberrors.ErrIPSetNoSuchSet},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: []string{sets, ""}}
+		err := NewSwapSets("live", test.other).RunWith(context.Background(), executor)
+		if !errors.Is(err, test.expects) {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, err, test.expects)
+		}
+
+		calls := fmt.Sprintf("%v", executor.Calls)
+		expects := "[[list -terse -output xml] [swap live " + test.other + "]]"
+		if test.expects != nil {
+			expects = "[[list -terse -output xml]]"
+		}
+		if calls != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, calls, expects)
+		}
+	}
+
+	// ipset refuses the swap.
+	executor := &utilitiestest.Executor{
+		Outputs: []string{sets, "ipset v7.1: The sets cannot be swapped: their type does not match\n"},
+		Errors:  []error{nil, errors.New("exit status 1")},
+	}
+	if err := NewSwapSets("live", "staging").RunWith(context.Background(), executor); !errors.Is(err, liberrors.ErrIPSetIncompatibleSets) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Support.
+
+// fakeTerseListOutput returns the XML output of ipset list -terse for sets defined by name, type and family;
+// the family is omitted if empty.
+func fakeTerseListOutput(sets ...[]string) string {
+	out := "<ipsets>"
+	for _, s := range sets {
+		family := ""
+		if s[2] != "" {
+			family = fmt.Sprintf("<family>%s</family>", s[2])
+		}
+		out += fmt.Sprintf(`<ipset name="%s"><type>%s</type><header>%s</header><members></members></ipset>`, s[0], s[1], family)
+	}
+	return out + "</ipsets>"
+}
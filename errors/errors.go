@@ -1,7 +1,48 @@
 // Package errors defines all errors exported by go-ipset.
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var ErrIPSetDidFail = errors.New("ipset command did fail")
 var ErrIPSetVersionIsNil = errors.New("ipset version is nil")
+var ErrIPSetTimeout = errors.New("ipset command timed out")
+var ErrIPSetCanceled = errors.New("ipset command was canceled")
+var ErrIPSetCommandIsInvalid = errors.New("ipset command has missing or invalid options")
+var ErrIPSetNoSuchSet = errors.New("ipset set does not exist")
+var ErrIPSetIncompatibleSets = errors.New("ipset sets have incompatible types or families")
+var ErrIPSetSetExists = errors.New("ipset set already exists")
+var ErrIPSetSetInUse = errors.New("ipset set is in use")
+var ErrIPSetOptionNotSupported = errors.New("ipset option is not supported by the set")
+var ErrIPSetElementExists = errors.New("ipset element is already added")
+var ErrIPSetElementMissing = errors.New("ipset element is not in set")
+var ErrIPSetSetIsFull = errors.New("ipset set is full")
+var ErrIPSetKernelModuleMissing = errors.New("ipset kernel support is missing")
+var ErrIPSetPermissionDenied = errors.New("ipset permission denied")
+
+// IPSetError describes a failed ipset command, as reported by ipset.
+// IPSetError matches ErrIPSetDidFail and its Kind with errors.Is, while Unwrap returns the error of the executor.
+type IPSetError struct {
+	Kind    error  // One of the errors of this package (ex.: ErrIPSetNoSuchSet), nil if unknown.
+	Set     string // Name of the target set, if any.
+	Command string // ipset command (ex.: add).
+	Message string // Message reported by ipset, without version prefix.
+	Err     error  // Error returned by the executor.
+}
+
+// Error returns a description of e.
+func (e *IPSetError) Error() string {
+	return fmt.Sprintf(`ipset returned error "%s"`, e.Message)
+}
+
+// Is returns true if target is ErrIPSetDidFail or the kind of e.
+func (e *IPSetError) Is(target error) bool {
+	return target == ErrIPSetDidFail || (e.Kind != nil && target == e.Kind)
+}
+
+// Unwrap returns the error returned by the executor.
+func (e *IPSetError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,134 @@
+// Package feed imports entries of blocklists published in common threat-feed formats.
+//
+// Each parser reads a feed from an io.Reader and returns add commands for a target set, validated for its type;
+// lines that cannot be imported are reported with their line numbers, instead of being dropped.
+package feed
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// Parser parses a feed into entries of a set named name, of type setType.
+// Errors are returned only if r cannot be read; invalid lines are listed by Result.Rejected.
+type Parser interface {
+	Parse(r io.Reader, name string, setType set.SetType) (*Result, error)
+}
+
+// Result describes the entries imported from a feed.
+type Result struct {
+	Entries    []*commands.AddTestDeleteEntry // Valid add commands, in the order of the feed.
+	Rejected   []Rejection                    // Lines that cannot be imported.
+	Duplicates int                            // Entries skipped because they were already imported.
+}
+
+// Rejection describes a line of a feed that cannot be imported.
+type Rejection struct {
+	Line int // Starting from 1.
+	Text string
+	Err  error
+}
+
+// Error returns a description of r.
+func (r Rejection) Error() string {
+	return fmt.Sprintf("line %d: %v", r.Line, r.Err)
+}
+
+// Unwrap returns the reason of r.
+func (r Rejection) Unwrap() error {
+	return r.Err
+}
+
+// Batch returns a batch that adds all entries of r.
+func (r *Result) Batch() *commands.Batch {
+	out := commands.NewBatch()
+	for _, entry := range r.Entries {
+		out.Entry(entry)
+	}
+	return out
+}
+
+// Support.
+
+// maxLineLength is the maximum length of lines of feeds.
+const maxLineLength = 1 << 20
+
+// lineParser returns the entry of a line of a feed (an empty entry for lines to skip) and its comment, if any.
+type lineParser func(text string) (entry string, comment string, err error)
+
+// result accumulates the entries of a feed for a set named name, of type setType.
+type result struct {
+	*Result
+	name     string
+	setType  set.SetType
+	comments bool // Add comments of lines to entries.
+	seen     map[string]bool
+}
+
+// newResult returns an empty result for a set named name, of type setType.
+func newResult(name string, setType set.SetType, comments bool) *result {
+	return &result{
+		Result:   &Result{Entries: []*commands.AddTestDeleteEntry{}, Rejected: []Rejection{}},
+		name:     name,
+		setType:  setType,
+		comments: comments,
+		seen:     map[string]bool{},
+	}
+}
+
+// add validates an entry read at a given line, and adds it to r or rejects it.
+func (r *result) add(line int, text, entry, comment string) {
+	if r.seen[entry] {
+		r.Duplicates++
+		return
+	}
+
+	e := commands.NewAddEntry(r.name, r.setType, entry)
+	if r.comments {
+		e.Comment = comment
+	}
+	if err := e.Validate(); err != nil {
+		r.reject(line, text, err)
+		return
+	}
+
+	r.seen[entry] = true
+	r.Entries = append(r.Entries, e)
+}
+
+// reject adds a rejected line to r.
+func (r *result) reject(line int, text string, err error) {
+	r.Rejected = append(r.Rejected, Rejection{Line: line, Text: text, Err: err})
+}
+
+// parseLines parses the lines of a feed read from reader with parse.
+func parseLines(reader io.Reader, r *result, parse lineParser) (*Result, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 4096), maxLineLength)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if entry, comment, err := parse(text); err != nil {
+			r.reject(line, text, err)
+		} else if entry != "" {
+			r.add(line, text, entry, comment)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r.Result, nil
+}
+
+// cutComment returns text before the first of a list of comment markers, and the text that follows it; both are trimmed.
+func cutComment(text string, markers string) (string, string) {
+	if i := strings.IndexAny(text, markers); i >= 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+	}
+	return strings.TrimSpace(text), ""
+}
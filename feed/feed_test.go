@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+func TestParsers(t *testing.T) {
+	type test struct {
+		parser     Parser
+		setType    set.SetType
+		input      string
+		entries    []string
+		rejected   []int
+		duplicates int
+	}
+
+	tests := []test{
+		{
+			parser:     Plain{Comments: true},
+			setType:    set.SetTypeHashIP,
+			input:      "# blocklist\n1.1.1.1 # scanner\n\n2.2.2.2 ; spam\n1.1.0\n3.3.3.3 4.4.4.4\n1.1.1.1\n",
+			entries:    []string{"1.1.1.1 comment scanner", "2.2.2.2 comment spam"},
+			rejected:   []int{5, 6},
+			duplicates: 1,
+		},
+		{
+			parser:   FireHOL{},
+			setType:  set.SetTypeHashNet,
+			input:    "#\n# firehol_level1\n#\n1.10.16.0/20\n2.56.192.0/22\n300.1.1.1\n",
+			entries:  []string{"1.10.16.0/20", "2.56.192.0/22"},
+			rejected: []int{6},
+		},
+		{
+			parser:  Spamhaus{Comments: true},
+			setType: set.SetTypeHashNet,
+			input:   "; Spamhaus DROP List 2024/01/01\n; Last-Modified: Mon, 01 Jan 2024\n1.10.16.0/20 ; SBL256894\n1.19.0.0/16 ; SBL434604\n",
+			entries: []string{"1.10.16.0/20 comment SBL256894", "1.19.0.0/16 comment SBL434604"},
+		},
+		{
+			parser:   Spamhaus{},
+			setType:  set.SetTypeHashNet,
+			input:    `{"cidr":"1.10.16.0/20","sblid":"SBL256894","rir":"apnic"}` + "\n" + `{"type":"metadata","timestamp":1704067200}` + "\n{invalid\n",
+			entries:  []string{"1.10.16.0/20"},
+			rejected: []int{3},
+		},
+		{
+			parser:  DShield{Comments: true},
+			setType: set.SetTypeHashNet,
+			input: "#\n# DShield.org Recommended Block List\n#\nStart\tEnd\tNetblock\tAttacks\tName\tCountry\temail\n" +
+				"45.148.10.0\t45.148.10.255\t24\t4012\tTECHOFF SRV LIMITED\tNL\tabuse@example.com\n" +
+				"80.82.77.0\t80.82.77.255\t24\t1992\n" +
+				"1.2.3.0\t1.2.3.255\n",
+			entries:  []string{"45.148.10.0/24 comment TECHOFF SRV LIMITED", "80.82.77.0/24"},
+			rejected: []int{7},
+		},
+		{
+			parser:   CSV{Columns: []int{1, 2}, Header: true},
+			setType:  set.SetTypeHashIPPort,
+			input:    "first_seen,ip,port\n# comment\n2024-01-01,1.1.1.1,tcp:22\n2024-01-01,2.2.2.2\n2024-01-01,3.3.3.3,tcp:70000\n2024-01-01, 4.4.4.4 ,udp:53\n",
+			entries:  []string{"1.1.1.1,tcp:22", "4.4.4.4,udp:53"},
+			rejected: []int{4, 5},
+		},
+		{
+			parser:   CSV{Columns: []int{1}, Header: true},
+			setType:  set.SetTypeHashIP,
+			input:    "blocklist \"v2\"\nfirst_seen,ip\n2024-01-01,1.1.1.1\n",
+			entries:  []string{"1.1.1.1"},
+			rejected: []int{1},
+		},
+		{
+			parser:   CSV{Comma: ';'},
+			setType:  set.SetTypeHashIP,
+			input:    "1.1.1.1;a\n\"2.2.2.2;b\n",
+			entries:  []string{"1.1.1.1"},
+			rejected: []int{2},
+		},
+		{
+			parser:  Save{Set: "blocklist"},
+			setType: set.SetTypeHashIP,
+			input: "create blocklist hash:ip family inet hashsize 1024 maxelem 65536 timeout 600\n" +
+				"add blocklist 1.1.1.1 timeout 300\nadd other 2.2.2.2\nadd blocklist 3.3.3.3\nflush blocklist\nadd blocklist\n",
+			entries:  []string{"1.1.1.1", "3.3.3.3"},
+			rejected: []int{5, 6},
+		},
+	}
+
+	for i, test := range tests {
+		result, err := test.parser.Parse(strings.NewReader(test.input), "blocklist", test.setType)
+		if err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+			continue
+		}
+
+		entries := make([]string, len(result.Entries))
+		for j, entry := range result.Entries {
+			entries[j] = strings.Join(entry.TranslateToIPSetArgs()[2:], " ")
+		}
+		rejected := make([]int, len(result.Rejected))
+		for j, rejection := range result.Rejected {
+			rejected[j] = rejection.Line
+		}
+
+		if fmt.Sprintf("%q", entries) != fmt.Sprintf("%q", test.entries) {
+			t.Errorf("expectation %d failed: entries %q != %q (expected)", i+1, entries, test.entries)
+		} else if fmt.Sprint(rejected) != fmt.Sprint(test.rejected) {
+			t.Errorf("expectation %d failed: rejected lines %v != %v (expected), %v", i+1, rejected, test.rejected, result.Rejected)
+		} else if result.Duplicates != test.duplicates {
+			t.Errorf("expectation %d failed: %d duplicates != %d (expected)", i+1, result.Duplicates, test.duplicates)
+		}
+	}
+}
+
+func TestRejection(t *testing.T) {
+	result, err := Plain{}.Parse(strings.NewReader("1.1.1.1\n1.1.0\n"), "blocklist", set.SetTypeHashIP)
+	if err != nil {
+		t.Fatalf("expectation failed: %v", err)
+	} else if len(result.Rejected) != 1 {
+		t.Fatalf("expectation failed: %d rejected lines != 1 (expected)", len(result.Rejected))
+	}
+
+	rejection := result.Rejected[0]
+	if !errors.Is(rejection, liberrors.ErrIPSetCommandIsInvalid) {
+		t.Errorf("expectation failed: %v does not match %v", rejection, liberrors.ErrIPSetCommandIsInvalid)
+	} else if !strings.HasPrefix(rejection.Error(), "line 2: ") || rejection.Text != "1.1.0" {
+		t.Errorf("expectation failed: unexpected rejection %v (%s)", rejection, rejection.Text)
+	} else if input, err := result.Batch().TranslateToIPSetRestoreInput(); err != nil || input != "add blocklist 1.1.1.1\n" {
+		t.Errorf("expectation failed: %q != %q (expected), %v", input, "add blocklist 1.1.1.1\n", err)
+	}
+}
@@ -0,0 +1,180 @@
+package feed
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+// Plain parses feeds listing one entry per line; text following # or ; is a comment.
+type Plain struct {
+	Comments bool // Add trailing comments to entries; the target set must be created with comment.
+}
+
+// FireHOL parses FireHOL .netset and .ipset files, listing one address or prefix per line; lines starting with # are comments.
+type FireHOL struct{}
+
+// Spamhaus parses Spamhaus DROP and EDROP lists, both as text (1.10.16.0/20 ; SBL256894) and as JSON lines
+// ({"cidr":"1.10.16.0/20","sblid":"SBL256894"}).
+type Spamhaus struct {
+	Comments bool // Add SBL identifiers to entries as comments; the target set must be created with comment.
+}
+
+// DShield parses DShield block lists, listing the start address, end address and netblock size of each
+// network separated by tabs; entries are formatted as start/netblock.
+type DShield struct {
+	Comments bool // Add names of networks to entries as comments; the target set must be created with comment.
+}
+
+// CSV parses feeds of comma separated values; lines starting with # are comments.
+type CSV struct {
+	Comma   rune  // Defaults to ','.
+	Columns []int // Columns joined with "," to build entries (ex.: address and port for hash:ip,port), starting from 0; defaults to the first column.
+	Header  bool  // The first record is a header.
+}
+
+// Save parses dumps of ipset save, importing the entries of add lines.
+type Save struct {
+	Set string // Only entries of the set named Set are imported; all entries if empty.
+}
+
+// Parse implementation of Parser.
+func (p Plain) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	return parseLines(r, newResult(name, setType, p.Comments), func(text string) (string, string, error) {
+		entry, comment := cutComment(text, "#;")
+		return singleField(entry, comment)
+	})
+}
+
+// Parse implementation of Parser.
+func (p FireHOL) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	return parseLines(r, newResult(name, setType, false), func(text string) (string, string, error) {
+		entry, _ := cutComment(text, "#")
+		return singleField(entry, "")
+	})
+}
+
+// Parse implementation of Parser.
+func (p Spamhaus) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	return parseLines(r, newResult(name, setType, p.Comments), func(text string) (string, string, error) {
+		if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") {
+			var record struct {
+				CIDR  string `json:"cidr"`
+				SBLID string `json:"sblid"`
+			}
+			if err := json.Unmarshal([]byte(trimmed), &record); err != nil {
+				return "", "", err
+			}
+			return record.CIDR, record.SBLID, nil // Metadata records have no cidr.
+		}
+
+		entry, comment := cutComment(text, ";")
+		return singleField(entry, comment)
+	})
+}
+
+// Parse implementation of Parser.
+func (p DShield) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	return parseLines(r, newResult(name, setType, p.Comments), func(text string) (string, string, error) {
+		text, _ = cutComment(text, "#")
+		fields := strings.Split(text, "\t")
+		if text == "" || fields[0] == "Start" {
+			return "", "", nil // Comment or header.
+		} else if len(fields) < 3 {
+			return "", "", fmt.Errorf("start, end and netblock are required")
+		}
+
+		comment := ""
+		if len(fields) > 4 {
+			comment = strings.TrimSpace(fields[4])
+		}
+		return strings.TrimSpace(fields[0]) + "/" + strings.TrimSpace(fields[2]), comment, nil
+	})
+}
+
+// Parse implementation of Parser.
+func (p CSV) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	out := newResult(name, setType, false)
+	columns := p.Columns
+	if len(columns) == 0 {
+		columns = []int{0}
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if p.Comma != 0 {
+		reader.Comma = p.Comma
+	}
+
+	header := p.Header // Cleared once a record is read, so that malformed lines are never taken as the header.
+	for {
+		record, err := reader.Read()
+		var parseErr *csv.ParseError
+		if err == io.EOF {
+			return out.Result, nil
+		} else if errors.As(err, &parseErr) {
+			out.reject(parseErr.StartLine, "", parseErr.Err)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		text := strings.Join(record, string(reader.Comma))
+		if header {
+			header = false
+			continue
+		}
+
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			if column < 0 || column >= len(record) {
+				err = fmt.Errorf("column %d is missing", column)
+				break
+			}
+			values[i] = strings.TrimSpace(record[column])
+		}
+
+		if err != nil {
+			out.reject(line, text, err)
+		} else {
+			out.add(line, text, strings.Join(values, ","), "")
+		}
+	}
+}
+
+// Parse implementation of Parser.
+func (p Save) Parse(r io.Reader, name string, setType set.SetType) (*Result, error) {
+	return parseLines(r, newResult(name, setType, false), func(text string) (string, string, error) {
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], "#") || fields[0] == "create":
+			return "", "", nil
+		case fields[0] != "add":
+			return "", "", fmt.Errorf("unsupported command %s", fields[0])
+		case len(fields) < 3:
+			return "", "", fmt.Errorf("missing set name or entry")
+		case p.Set != "" && fields[1] != p.Set:
+			return "", "", nil
+		default:
+			return fields[2], "", nil // Entry options are not imported.
+		}
+	})
+}
+
+// Support.
+
+// singleField returns an entry without white spaces and its comment, or an error if entry includes more fields.
+func singleField(entry, comment string) (string, string, error) {
+	if fields := strings.Fields(entry); len(fields) > 1 {
+		return "", "", fmt.Errorf("unexpected text %q after entry", strings.Join(fields[1:], " "))
+	}
+	return entry, comment, nil
+}
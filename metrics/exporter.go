@@ -0,0 +1,120 @@
+// Package metrics exports statistics of sets listed by ipset with the Prometheus text exposition format.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// DefaultMaxEntrySeries is the default maximum number of entries of each set exported with per-entry metrics.
+const DefaultMaxEntrySeries = 100
+
+// Exporter gathers metrics of sets listed by ipset on each scrape.
+//
+// Headers of all sets are exported as gauges; members are listed only for sets created with counters,
+// whose packets and bytes are exported both as totals of the set and for at most MaxEntrySeries entries,
+// so that large sets do not create a series for each entry.
+type Exporter struct {
+	Executor       utilities.Executor // Defaults to utilities.DefaultExecutor if nil.
+	Filter         *regexp.Regexp     // Only sets whose name matches Filter are exported; all sets if nil.
+	MaxEntrySeries int                // Maximum number of entries of each set exported with per-entry metrics.
+}
+
+// NewExporter returns an exporter of all sets, that runs commands using a given executor.
+func NewExporter(executor utilities.Executor) *Exporter {
+	return &Exporter{Executor: executor, MaxEntrySeries: DefaultMaxEntrySeries}
+}
+
+// Gather lists sets and returns their metrics.
+// ipset_up is always returned, and it is 0 (with no other metric) if sets cannot be listed.
+func (e *Exporter) Gather(ctx context.Context) ([]*Family, error) {
+	up := newFamily("ipset_up", "Whether sets were listed by ipset.", MetricTypeGauge)
+	sets, err := e.listSets(ctx)
+	if err != nil {
+		up.add(0)
+		return []*Family{up}, err
+	}
+	up.add(1)
+
+	entries := newFamily("ipset_set_entries", "Number of entries of the set.", MetricTypeGauge)
+	memSize := newFamily("ipset_set_memsize_bytes", "Memory used by the set, in bytes.", MetricTypeGauge)
+	references := newFamily("ipset_set_references", "Number of references to the set.", MetricTypeGauge)
+	maxElements := newFamily("ipset_set_max_entries", "Maximum number of entries of the set (maxelem of hash sets, size of list sets).", MetricTypeGauge)
+	utilization := newFamily("ipset_set_utilization_ratio", "Number of entries of the set over its maximum number of entries.", MetricTypeGauge)
+	packets := newFamily("ipset_set_packets", "Packets matched by current entries of the set.", MetricTypeGauge)
+	bytes := newFamily("ipset_set_bytes", "Bytes matched by current entries of the set.", MetricTypeGauge)
+	entryPackets := newFamily("ipset_entry_packets_total", "Packets matched by the entry.", MetricTypeCounter)
+	entryBytes := newFamily("ipset_entry_bytes_total", "Bytes matched by the entry.", MetricTypeCounter)
+	dropped := newFamily("ipset_set_entry_series_dropped", "Entries of the set not exported with per-entry metrics.", MetricTypeGauge)
+
+	limit := e.MaxEntrySeries
+	if limit < 0 {
+		limit = 0
+	}
+
+	for _, info := range sets {
+		header := info.Header
+		labels := []Label{{"set", info.Name}, {"type", info.Type.String()}}
+		entries.add(float64(header.NumEntries), labels...)
+		memSize.add(float64(header.MemSize), labels...)
+		references.add(float64(header.References), labels...)
+
+		if capacity := maxEntries(info); capacity > 0 {
+			maxElements.add(float64(capacity), labels...)
+			utilization.add(float64(header.NumEntries)/float64(capacity), labels...)
+		}
+
+		if !header.UseCounters {
+			continue
+		}
+
+		var setPackets, setBytes uint64
+		for i, member := range info.Members {
+			setPackets += member.Packets
+			setBytes += member.Bytes
+			if i < limit {
+				entryLabels := []Label{{"set", info.Name}, {"entry", member.Entry}}
+				entryPackets.add(float64(member.Packets), entryLabels...)
+				entryBytes.add(float64(member.Bytes), entryLabels...)
+			}
+		}
+		packets.add(float64(setPackets), labels...)
+		bytes.add(float64(setBytes), labels...)
+		if len(info.Members) > limit {
+			dropped.add(float64(len(info.Members)-limit), Label{"set", info.Name})
+		}
+	}
+
+	return []*Family{up, entries, memSize, references, maxElements, utilization, packets, bytes, entryPackets, entryBytes, dropped}, nil
+}
+
+// ServeHTTP implementation of http.Handler.
+// Metrics are written even if sets cannot be listed, so that scrapers can alert on ipset_up.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families, _ := e.Gather(r.Context())
+	w.Header().Set("Content-Type", ContentType)
+	Write(w, families)
+}
+
+// Support.
+
+// listSets returns the headers of sets matching the filter of e, and the members of those created with counters.
+func (e *Exporter) listSets(ctx context.Context) ([]commands.SetInfo, error) {
+	list := commands.NewListSet("")
+	list.Filter = e.Filter
+	list.Members = func(header commands.SetInfo) bool { return header.Header.UseCounters }
+	return list.SetsWith(ctx, utilities.ExecutorOrDefault(e.Executor))
+}
+
+// maxEntries returns the maximum number of entries of a set, or 0 if it has no explicit limit.
+func maxEntries(info commands.SetInfo) int {
+	if info.Header.MaxElements > 0 {
+		return info.Header.MaxElements
+	} else {
+		return info.Header.Size
+	}
+}
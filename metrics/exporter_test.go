@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+const (
+	blocklistOutput = `<ipset name="blocklist"><type>hash:ip</type><header><family>inet</family><maxelem>4</maxelem>` +
+		`<memsize>512</memsize><references>1</references><numentries>1</numentries></header><members></members></ipset>`
+	webHeaderOutput = `<ipset name="web"><type>hash:ip,port</type><header><family>inet</family><maxelem>100</maxelem>` +
+		`<memsize>1024</memsize><references>0</references><numentries>3</numentries><counters/></header><members></members></ipset>`
+	webOutput = `<ipset name="web"><type>hash:ip,port</type><header><family>inet</family><maxelem>100</maxelem>` +
+		`<memsize>1024</memsize><references>0</references><numentries>3</numentries><counters/></header><members>` +
+		`<member><elem>1.1.1.1,tcp:80</elem><packets>10</packets><bytes>1000</bytes></member>` +
+		`<member><elem>2.2.2.2,tcp:80</elem><packets>20</packets><bytes>2000</bytes></member>` +
+		`<member><elem>3.3.3.3,tcp:80</elem><packets>30</packets><bytes>3000</bytes></member></members></ipset>`
+)
+
+func TestGather(t *testing.T) {
+	type test struct {
+		filter         string
+		maxEntrySeries int
+		outputs        []string
+		errors         []error
+		calls          []string
+		expects        string
+	}
+
+	tests := []test{
+		{
+			maxEntrySeries: 2,
+			outputs:        []string{"<ipsets>" + blocklistOutput + webHeaderOutput + "</ipsets>", "<ipsets>" + webOutput + "</ipsets>"},
+			calls:          []string{"list -terse -output xml", "list web -output xml"},
+			expects: `# HELP ipset_up Whether sets were listed by ipset.
+# TYPE ipset_up gauge
+ipset_up 1
+# HELP ipset_set_entries Number of entries of the set.
+# TYPE ipset_set_entries gauge
+ipset_set_entries{set="blocklist",type="hash:ip"} 1
+ipset_set_entries{set="web",type="hash:ip,port"} 3
+# HELP ipset_set_memsize_bytes Memory used by the set, in bytes.
+# TYPE ipset_set_memsize_bytes gauge
+ipset_set_memsize_bytes{set="blocklist",type="hash:ip"} 512
+ipset_set_memsize_bytes{set="web",type="hash:ip,port"} 1024
+# HELP ipset_set_references Number of references to the set.
+# TYPE ipset_set_references gauge
+ipset_set_references{set="blocklist",type="hash:ip"} 1
+ipset_set_references{set="web",type="hash:ip,port"} 0
+# HELP ipset_set_max_entries Maximum number of entries of the set (maxelem of hash sets, size of list sets).
+# TYPE ipset_set_max_entries gauge
+ipset_set_max_entries{set="blocklist",type="hash:ip"} 4
+ipset_set_max_entries{set="web",type="hash:ip,port"} 100
+# HELP ipset_set_utilization_ratio Number of entries of the set over its maximum number of entries.
+# TYPE ipset_set_utilization_ratio gauge
+ipset_set_utilization_ratio{set="blocklist",type="hash:ip"} 0.25
+ipset_set_utilization_ratio{set="web",type="hash:ip,port"} 0.03
+# HELP ipset_set_packets Packets matched by current entries of the set.
+# TYPE ipset_set_packets gauge
+ipset_set_packets{set="web",type="hash:ip,port"} 60
+# HELP ipset_set_bytes Bytes matched by current entries of the set.
+# TYPE ipset_set_bytes gauge
+ipset_set_bytes{set="web",type="hash:ip,port"} 6000
+# HELP ipset_entry_packets_total Packets matched by the entry.
+# TYPE ipset_entry_packets_total counter
+ipset_entry_packets_total{set="web",entry="1.1.1.1,tcp:80"} 10
+ipset_entry_packets_total{set="web",entry="2.2.2.2,tcp:80"} 20
+# HELP ipset_entry_bytes_total Bytes matched by the entry.
+# TYPE ipset_entry_bytes_total counter
+ipset_entry_bytes_total{set="web",entry="1.1.1.1,tcp:80"} 1000
+ipset_entry_bytes_total{set="web",entry="2.2.2.2,tcp:80"} 2000
+# HELP ipset_set_entry_series_dropped Entries of the set not exported with per-entry metrics.
+# TYPE ipset_set_entry_series_dropped gauge
+ipset_set_entry_series_dropped{set="web"} 1
+`,
+		},
+		{
+			filter:  "^block",
+			outputs: []string{"<ipsets>" + blocklistOutput + webHeaderOutput + "</ipsets>"},
+			calls:   []string{"list -terse -output xml"},
+			expects: "ipset_set_entries{set=\"blocklist\",type=\"hash:ip\"} 1\n",
+		},
+		{
+			outputs: []string{"ipset v7.1: Kernel error received: Operation not permitted\n"},
+			errors:  []error{errors.New("exit status 1")},
+			calls:   []string{"list -terse -output xml"},
+			expects: "# HELP ipset_up Whether sets were listed by ipset.\n# TYPE ipset_up gauge\nipset_up 0\n",
+		},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: test.outputs, Errors: test.errors}
+		exporter := NewExporter(executor)
+		exporter.MaxEntrySeries = test.maxEntrySeries
+		if test.filter != "" {
+			exporter.Filter = regexp.MustCompile(test.filter)
+		}
+
+		families, _ := exporter.Gather(context.Background())
+		var out bytes.Buffer
+		Write(&out, families)
+
+		calls := make([]string, len(executor.Calls))
+		for j, call := range executor.Calls {
+			calls[j] = strings.Join(call, " ")
+		}
+
+		if result, expects := fmt.Sprintf("%q", calls), fmt.Sprintf("%q", test.calls); result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		} else if test.filter == "" && out.String() != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, out.String(), test.expects)
+		} else if test.filter != "" && (!strings.Contains(out.String(), test.expects) || strings.Contains(out.String(), `set="web"`)) {
+			t.Errorf("expectation %d failed: %s does not contain only %s", i+1, out.String(), test.expects)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{"<ipsets>" + blocklistOutput + "</ipsets>"}}
+	recorder := httptest.NewRecorder()
+	NewExporter(executor).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expectation failed: status %d != %d (expected)", recorder.Code, http.StatusOK)
+	} else if contentType := recorder.Header().Get("Content-Type"); contentType != ContentType {
+		t.Errorf("expectation failed: content type %s != %s (expected)", contentType, ContentType)
+	} else if !strings.Contains(recorder.Body.String(), "ipset_up 1\n") {
+		t.Errorf("expectation failed: %s does not contain ipset_up 1", recorder.Body.String())
+	}
+}
+
+func TestWriteEscapes(t *testing.T) {
+	family := newFamily("test", "Help with \\ and\nnewline.", MetricTypeGauge)
+	family.add(1.5, Label{"entry", "a\"b\\c\nd"})
+
+	var out bytes.Buffer
+	Write(&out, []*Family{family})
+
+	expects := "# HELP test Help with \\\\ and\\nnewline.\n# TYPE test gauge\ntest{entry=\"a\\\"b\\\\c\\nd\"} 1.5\n"
+	if out.String() != expects {
+		t.Errorf("expectation failed: %q != %q (expected)", out.String(), expects)
+	}
+}
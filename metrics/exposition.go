@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the Prometheus text exposition format written by Write.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricType is the type of the samples of a family.
+type MetricType string
+
+// Metric types.
+const (
+	MetricTypeGauge   MetricType = "gauge"
+	MetricTypeCounter MetricType = "counter"
+)
+
+// Family is a group of samples with the same name, help and type.
+// Families can be adapted to other metric libraries (ex.: as constant metrics of a Prometheus collector).
+type Family struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []Sample
+}
+
+// Sample is a value of a family, identified by its labels.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Label is a name-value pair that identifies a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Write writes families with the Prometheus text exposition format; families without samples are skipped.
+func Write(w io.Writer, families []*Family) error {
+	b := bufio.NewWriter(w)
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		b.WriteString("# HELP " + family.Name + " " + escapeHelp(family.Help) + "\n")
+		b.WriteString("# TYPE " + family.Name + " " + string(family.Type) + "\n")
+		for _, sample := range family.Samples {
+			b.WriteString(family.Name)
+			if len(sample.Labels) > 0 {
+				b.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					b.WriteString(label.Name + `="` + escapeLabelValue(label.Value) + `"`)
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return b.Flush()
+}
+
+// Support.
+
+// newFamily returns a family without samples.
+func newFamily(name, help string, metricType MetricType) *Family {
+	return &Family{Name: name, Help: help, Type: metricType}
+}
+
+// add appends a sample to f.
+func (f *Family) add(value float64, labels ...Label) {
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// helpReplacer escapes help strings.
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// labelValueReplacer escapes label values.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// escapeHelp escapes backslashes and line feeds of a help string.
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+// escapeLabelValue escapes backslashes, line feeds and double quotes of a label value.
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+// formatValue formats a sample value.
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
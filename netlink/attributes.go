@@ -0,0 +1,229 @@
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"unsafe"
+)
+
+// nativeEndian is the byte order of the host, used by netlink headers and attribute headers.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	probe := uint16(1)
+	if *(*byte)(unsafe.Pointer(&probe)) == 1 {
+		return binary.LittleEndian
+	} else {
+		return binary.BigEndian
+	}
+}()
+
+// attribute defines a netlink attribute.
+// Type includes flags NLA_F_NESTED and NLA_F_NET_BYTEORDER; nested attributes store their children in Children.
+type attribute struct {
+	Type     uint16
+	Data     []byte
+	Children []attribute
+}
+
+// kind returns the type of a without flags.
+func (a attribute) kind() uint16 {
+	return a.Type & attrTypeMask
+}
+
+// isNested returns true if a contains other attributes.
+func (a attribute) isNested() bool {
+	return a.Type&attrFlagNested != 0
+}
+
+// encode returns the wire representation of a, including trailing padding.
+func (a attribute) encode() []byte {
+	data := a.Data
+	if a.isNested() {
+		data = encodeAttributes(a.Children)
+	}
+
+	out := make([]byte, align(attrHeaderLen+len(data)))
+	nativeEndian.PutUint16(out[0:2], uint16(attrHeaderLen+len(data)))
+	nativeEndian.PutUint16(out[2:4], a.Type)
+	copy(out[attrHeaderLen:], data)
+	return out
+}
+
+// uint8 returns the value of a as uint8.
+func (a attribute) uint8() uint8 {
+	if len(a.Data) < 1 {
+		return 0
+	}
+	return a.Data[0]
+}
+
+// uint16 returns the value of a as uint16, honoring NLA_F_NET_BYTEORDER.
+func (a attribute) uint16() uint16 {
+	if len(a.Data) < 2 {
+		return 0
+	}
+	return a.byteOrder().Uint16(a.Data)
+}
+
+// uint32 returns the value of a as uint32, honoring NLA_F_NET_BYTEORDER.
+func (a attribute) uint32() uint32 {
+	if len(a.Data) < 4 {
+		return 0
+	}
+	return a.byteOrder().Uint32(a.Data)
+}
+
+// uint64 returns the value of a as uint64, honoring NLA_F_NET_BYTEORDER.
+func (a attribute) uint64() uint64 {
+	if len(a.Data) < 8 {
+		return 0
+	}
+	return a.byteOrder().Uint64(a.Data)
+}
+
+// string returns the value of a as a string, without the terminating NUL byte.
+func (a attribute) string() string {
+	for i, b := range a.Data {
+		if b == 0 {
+			return string(a.Data[:i])
+		}
+	}
+	return string(a.Data)
+}
+
+// ip returns the IP address stored in a nested IP attribute.
+func (a attribute) ip() net.IP {
+	for _, child := range a.Children {
+		switch child.kind() {
+		case ipsetAttrIPAddrIPv4:
+			if len(child.Data) == net.IPv4len {
+				return net.IP(child.Data).To4()
+			}
+		case ipsetAttrIPAddrIPv6:
+			if len(child.Data) == net.IPv6len {
+				return net.IP(child.Data)
+			}
+		}
+	}
+	return nil
+}
+
+// byteOrder returns the byte order of numeric values stored in a.
+func (a attribute) byteOrder() binary.ByteOrder {
+	if a.Type&attrFlagNetOrder != 0 {
+		return binary.BigEndian
+	} else {
+		return nativeEndian
+	}
+}
+
+// Attribute constructors.
+
+// uint8Attribute returns an attribute of type t storing v.
+func uint8Attribute(t uint16, v uint8) attribute {
+	return attribute{Type: t, Data: []byte{v}}
+}
+
+// uint16Attribute returns an attribute of type t storing v in network byte order.
+func uint16Attribute(t uint16, v uint16) attribute {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, v)
+	return attribute{Type: t | attrFlagNetOrder, Data: data}
+}
+
+// uint32Attribute returns an attribute of type t storing v in network byte order.
+func uint32Attribute(t uint16, v uint32) attribute {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, v)
+	return attribute{Type: t | attrFlagNetOrder, Data: data}
+}
+
+// uint64Attribute returns an attribute of type t storing v in network byte order.
+func uint64Attribute(t uint16, v uint64) attribute {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, v)
+	return attribute{Type: t | attrFlagNetOrder, Data: data}
+}
+
+// stringAttribute returns an attribute of type t storing NUL terminated string v.
+func stringAttribute(t uint16, v string) attribute {
+	return attribute{Type: t, Data: append([]byte(v), 0)}
+}
+
+// bytesAttribute returns an attribute of type t storing raw bytes v.
+func bytesAttribute(t uint16, v []byte) attribute {
+	return attribute{Type: t, Data: v}
+}
+
+// nestedAttribute returns an attribute of type t containing a list of children.
+func nestedAttribute(t uint16, children ...attribute) attribute {
+	return attribute{Type: t | attrFlagNested, Children: children}
+}
+
+// ipAttribute returns a nested attribute of type t storing IPv4 or IPv6 address ip.
+func ipAttribute(t uint16, ip net.IP) attribute {
+	if ip4 := ip.To4(); ip4 != nil {
+		return nestedAttribute(t, attribute{Type: ipsetAttrIPAddrIPv4 | attrFlagNetOrder, Data: []byte(ip4)})
+	} else {
+		return nestedAttribute(t, attribute{Type: ipsetAttrIPAddrIPv6 | attrFlagNetOrder, Data: []byte(ip.To16())})
+	}
+}
+
+// Encoding and decoding.
+
+// encodeAttributes returns the wire representation of a list of attributes.
+func encodeAttributes(attributes []attribute) []byte {
+	out := []byte{}
+	for _, a := range attributes {
+		out = append(out, a.encode()...)
+	}
+	return out
+}
+
+// decodeAttributes parses a list of attributes from their wire representation.
+// Nested attributes are parsed recursively.
+func decodeAttributes(in []byte) ([]attribute, error) {
+	out := []attribute{}
+	for len(in) > 0 {
+		if len(in) < attrHeaderLen {
+			return nil, fmt.Errorf("netlink attribute is truncated (%d bytes)", len(in))
+		}
+
+		length := int(nativeEndian.Uint16(in[0:2]))
+		if length < attrHeaderLen || length > len(in) {
+			return nil, fmt.Errorf("netlink attribute has invalid length %d", length)
+		}
+
+		a := attribute{Type: nativeEndian.Uint16(in[2:4]), Data: in[attrHeaderLen:length]}
+		if a.isNested() {
+			children, err := decodeAttributes(a.Data)
+			if err != nil {
+				return nil, err
+			}
+			a.Children = children
+		}
+		out = append(out, a)
+
+		if next := align(length); next < len(in) {
+			in = in[next:]
+		} else {
+			in = nil
+		}
+	}
+	return out, nil
+}
+
+// findAttribute returns the first attribute of a list whose type matches t.
+func findAttribute(attributes []attribute, t uint16) (attribute, bool) {
+	for _, a := range attributes {
+		if a.kind() == t {
+			return a, true
+		}
+	}
+	return attribute{}, false
+}
+
+// align returns length rounded up to the netlink alignment (4 bytes).
+func align(length int) int {
+	return (length + 3) &^ 3
+}
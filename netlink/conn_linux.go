@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"syscall"
+	"time"
+)
+
+// receivePollInterval is the maximum time spent waiting for a datagram before checking the context again.
+const receivePollInterval = 100 * time.Millisecond
+
+// receiveBufferSize is the size of the buffer used to read datagrams; dumps never exceed this size.
+const receiveBufferSize = 1 << 16
+
+// kernelConn is a netlink socket connected to the kernel nfnetlink subsystem.
+type kernelConn struct {
+	fd int
+}
+
+// dialKernel opens a netlink socket connected to the kernel nfnetlink subsystem.
+func dialKernel() (conn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkNetfilter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return &kernelConn{fd: fd}, nil
+}
+
+// kernelConn implementation of send.
+func (c *kernelConn) send(b []byte) error {
+	return syscall.Sendto(c.fd, b, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// kernelConn implementation of receive.
+// The socket is polled with a short timeout so that cancellation of ctx is honored.
+func (c *kernelConn) receive(ctx context.Context) ([]byte, error) {
+	buffer := make([]byte, receiveBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		timeout := receivePollInterval
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := time.Until(deadline); remaining < timeout {
+				timeout = remaining
+			}
+		}
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+		if err := syscall.SetsockoptTimeval(c.fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+			return nil, err
+		}
+
+		n, _, err := syscall.Recvfrom(c.fd, buffer, 0)
+		if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		return buffer[:n], nil
+	}
+}
+
+// kernelConn implementation of close.
+func (c *kernelConn) close() error {
+	return syscall.Close(c.fd)
+}
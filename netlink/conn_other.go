@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package netlink
+
+import "errors"
+
+// dialKernel always fails: the ipset netlink subsystem is available only on Linux.
+func dialKernel() (conn, error) {
+	return nil, errors.New("netlink executor is supported only on linux")
+}
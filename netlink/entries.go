@@ -0,0 +1,518 @@
+package netlink
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Protocol numbers supported in [proto:]port components.
+var protocolNumbers = map[string]uint8{
+	"icmp":    1,
+	"tcp":     6,
+	"udp":     17,
+	"icmpv6":  58,
+	"sctp":    132,
+	"udplite": 136,
+}
+
+// isHash returns true if setType is a hash set type.
+func isHash(setType string) bool {
+	return strings.HasPrefix(setType, "hash:")
+}
+
+// isBitmap returns true if setType is a bitmap set type.
+func isBitmap(setType string) bool {
+	return strings.HasPrefix(setType, "bitmap:")
+}
+
+// typeComponents returns the components of the elements stored in sets of type setType (ex.: "ip", "port").
+func typeComponents(setType string) []string {
+	if i := strings.Index(setType, ":"); i >= 0 {
+		return strings.Split(setType[i+1:], ",")
+	}
+	return []string{}
+}
+
+// typeFamily returns the protocol family stored by sets of type setType, given the family requested by the user.
+func typeFamily(setType string, family uint8) uint8 {
+	switch setType {
+	case "bitmap:port", "hash:mac", "list:set":
+		return familyUnspec
+	case "bitmap:ip", "bitmap:ip,mac":
+		return familyINet
+	default:
+		if family == familyINet6 {
+			return familyINet6
+		}
+		return familyINet
+	}
+}
+
+// familyName returns the ipset name of protocol family family.
+func familyName(family uint8) string {
+	switch family {
+	case familyINet6:
+		return "inet6"
+	case familyINet:
+		return "inet"
+	default:
+		return "unspec"
+	}
+}
+
+// Element parsing.
+
+// parseElement returns the data attributes representing an element of a set of type setType.
+// Argument element must use the same syntax accepted by ipset (ex.: "1.1.1.1,tcp:80").
+func parseElement(setType string, element string) ([]attribute, error) {
+	components := typeComponents(setType)
+	if setType == "list:set" {
+		return []attribute{stringAttribute(ipsetAttrName, element)}, nil
+	}
+
+	parts := strings.Split(element, ",")
+	if setType == "bitmap:ip,mac" && len(parts) == 1 {
+		parts = append(parts, "") // MAC address is optional.
+	}
+	if len(parts) != len(components) {
+		return nil, fmt.Errorf("Syntax error: element %s is not valid for set type %s", element, setType)
+	}
+
+	out := []attribute{}
+	flags := uint32(0)
+	ipIndex := 0
+	for i, component := range components {
+		part := parts[i]
+
+		switch component {
+		case "ip", "net":
+			attributes, err := parseIPComponent(part, ipIndex, component == "net" || ipIndex == 0)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, attributes...)
+			ipIndex++
+
+		case "port":
+			attributes, err := parsePortComponent(part, isHash(setType))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, attributes...)
+
+		case "mac":
+			if part == "" {
+				continue
+			}
+			mac, err := net.ParseMAC(part)
+			if err != nil || len(mac) != 6 {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as a MAC address", part)
+			}
+			out = append(out, bytesAttribute(ipsetAttrEther, mac))
+
+		case "mark":
+			mark, err := strconv.ParseUint(part, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as a mark value", part)
+			}
+			out = append(out, uint32Attribute(ipsetAttrMark, uint32(mark)))
+
+		case "iface":
+			if strings.HasPrefix(part, "physdev:") {
+				part = strings.TrimPrefix(part, "physdev:")
+				flags |= ipsetFlagPhysdev
+			}
+			if part == "" || len(part) >= 16 {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as an interface name", part)
+			}
+			out = append(out, stringAttribute(ipsetAttrIface, part))
+
+		default:
+			return nil, fmt.Errorf("Syntax error: set type %s is not supported", setType)
+		}
+	}
+
+	if flags != 0 {
+		out = append(out, uint32Attribute(ipsetAttrCADTFlags, flags))
+	}
+	return out, nil
+}
+
+// parseIPComponent returns the attributes representing an ip, ip/cidr or fromip-toip component.
+// Argument index is 0 for the first IP of an element and 1 for the second one.
+func parseIPComponent(in string, index int, allowRanges bool) ([]attribute, error) {
+	ipType, cidrType, ipToType := uint16(ipsetAttrIP), uint16(ipsetAttrCIDR), uint16(ipsetAttrIPTo)
+	if index > 0 {
+		ipType, cidrType, ipToType = ipsetAttrIP2, ipsetAttrCIDR2, ipsetAttrIP2To
+	}
+
+	if allowRanges {
+		if i := strings.Index(in, "/"); i >= 0 {
+			ip := net.ParseIP(in[:i])
+			cidr, err := strconv.Atoi(in[i+1:])
+			if ip == nil || err != nil || cidr < 0 || cidr > maxCIDR(ip) {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as an ip/cidr", in)
+			}
+			return []attribute{ipAttribute(ipType, ip), uint8Attribute(cidrType, uint8(cidr))}, nil
+		}
+
+		if i := strings.Index(in, "-"); i >= 0 {
+			from, to := net.ParseIP(in[:i]), net.ParseIP(in[i+1:])
+			if from == nil || to == nil || (from.To4() == nil) != (to.To4() == nil) {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as a fromip-toip range", in)
+			}
+			return []attribute{ipAttribute(ipType, from), ipAttribute(ipToType, to)}, nil
+		}
+	}
+
+	ip := net.ParseIP(in)
+	if ip == nil {
+		return nil, fmt.Errorf("Syntax error: cannot parse %s as an IP address", in)
+	}
+	return []attribute{ipAttribute(ipType, ip)}, nil
+}
+
+// parsePortComponent returns the attributes representing a [proto:]port or [proto:]fromport-toport component.
+// Protocol attributes are included only if withProtocol is true.
+func parsePortComponent(in string, withProtocol bool) ([]attribute, error) {
+	protocol := uint8(6) // Defaults to tcp.
+	if i := strings.Index(in, ":"); i >= 0 {
+		name := strings.ToLower(in[:i])
+		if value, ok := protocolNumbers[name]; ok {
+			protocol = value
+		} else if value, err := strconv.ParseUint(name, 10, 8); err == nil {
+			protocol = uint8(value)
+		} else {
+			return nil, fmt.Errorf("Syntax error: cannot parse %s as a protocol", name)
+		}
+		in = in[i+1:]
+	}
+
+	out := []attribute{}
+	if protocol == 1 || protocol == 58 {
+		// ICMP type/code.
+		values := strings.Split(in, "/")
+		if len(values) != 2 {
+			return nil, fmt.Errorf("Syntax error: cannot parse %s as an ICMP type/code", in)
+		}
+		icmpType, errType := strconv.ParseUint(values[0], 10, 8)
+		icmpCode, errCode := strconv.ParseUint(values[1], 10, 8)
+		if errType != nil || errCode != nil {
+			return nil, fmt.Errorf("Syntax error: cannot parse %s as an ICMP type/code", in)
+		}
+		out = append(out, uint16Attribute(ipsetAttrPort, uint16(icmpType<<8|icmpCode)))
+	} else {
+		ports := strings.SplitN(in, "-", 2)
+		for i, raw := range ports {
+			port, err := strconv.ParseUint(raw, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("Syntax error: cannot parse %s as a port number", raw)
+			}
+
+			if i == 0 {
+				out = append(out, uint16Attribute(ipsetAttrPort, uint16(port)))
+			} else {
+				out = append(out, uint16Attribute(ipsetAttrPortTo, uint16(port)))
+			}
+		}
+	}
+
+	if withProtocol {
+		out = append(out, uint8Attribute(ipsetAttrProto, protocol))
+	}
+	return out, nil
+}
+
+// maxCIDR returns the maximum CIDR value of addresses of the same family of ip.
+func maxCIDR(ip net.IP) int {
+	if ip.To4() != nil {
+		return 32
+	} else {
+		return 128
+	}
+}
+
+// Element formatting.
+
+// formatElement returns the ipset representation of an element of a set of type setType
+// stored in a list of data attributes.
+func formatElement(setType string, attributes []attribute) string {
+	if setType == "list:set" {
+		if name, ok := findAttribute(attributes, ipsetAttrName); ok {
+			return name.string()
+		}
+		return ""
+	}
+
+	flags := uint32(0)
+	if a, ok := findAttribute(attributes, ipsetAttrCADTFlags); ok {
+		flags = a.uint32()
+	}
+
+	parts := []string{}
+	ipIndex := 0
+	for _, component := range typeComponents(setType) {
+		switch component {
+		case "ip", "net":
+			ipType, cidrType := uint16(ipsetAttrIP), uint16(ipsetAttrCIDR)
+			if ipIndex > 0 {
+				ipType, cidrType = ipsetAttrIP2, ipsetAttrCIDR2
+			}
+			ipIndex++
+
+			a, ok := findAttribute(attributes, ipType)
+			if !ok {
+				continue
+			}
+			ip := a.ip()
+			out := ip.String()
+			if cidr, ok := findAttribute(attributes, cidrType); ok && component == "net" && int(cidr.uint8()) != maxCIDR(ip) {
+				out = fmt.Sprintf("%s/%d", out, cidr.uint8())
+			}
+			parts = append(parts, out)
+
+		case "port":
+			port, _ := findAttribute(attributes, ipsetAttrPort)
+			if !isHash(setType) {
+				parts = append(parts, strconv.Itoa(int(port.uint16())))
+				continue
+			}
+
+			protocol := uint8(6)
+			if a, ok := findAttribute(attributes, ipsetAttrProto); ok {
+				protocol = a.uint8()
+			}
+			parts = append(parts, formatPort(protocol, port.uint16()))
+
+		case "mac":
+			if a, ok := findAttribute(attributes, ipsetAttrEther); ok {
+				parts = append(parts, strings.ToUpper(net.HardwareAddr(a.Data).String()))
+			}
+
+		case "mark":
+			mark, _ := findAttribute(attributes, ipsetAttrMark)
+			parts = append(parts, fmt.Sprintf("0x%08x", mark.uint32()))
+
+		case "iface":
+			iface, _ := findAttribute(attributes, ipsetAttrIface)
+			if flags&ipsetFlagPhysdev != 0 {
+				parts = append(parts, "physdev:"+iface.string())
+			} else {
+				parts = append(parts, iface.string())
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatPort returns the ipset representation of a protocol and port pair.
+func formatPort(protocol uint8, port uint16) string {
+	name := strconv.Itoa(int(protocol))
+	for key, value := range protocolNumbers {
+		if value == protocol {
+			name = key
+			break
+		}
+	}
+
+	if protocol == 1 || protocol == 58 {
+		return fmt.Sprintf("%s:%d/%d", name, port>>8, port&0xff)
+	}
+	return fmt.Sprintf("%s:%d", name, port)
+}
+
+// Create and entry options.
+
+// parseCreateOptions returns the protocol family and data attributes representing
+// the options of a create command for a set of type setType.
+func parseCreateOptions(setType string, options []string) (uint8, []attribute, error) {
+	family := uint8(familyINet)
+	out := []attribute{}
+	flags := uint32(0)
+
+	for i := 0; i < len(options); i++ {
+		name := options[i]
+		value := ""
+		if optionHasValue(name, createFlagOptions) {
+			if i+1 >= len(options) {
+				return 0, nil, fmt.Errorf("Syntax error: argument %s requires a value", name)
+			}
+			value = options[i+1]
+			i++
+		}
+
+		switch name {
+		case "family":
+			switch value {
+			case "inet":
+				family = familyINet
+			case "inet6":
+				family = familyINet6
+			default:
+				return 0, nil, fmt.Errorf("Syntax error: unknown family %s", value)
+			}
+
+		case "hashsize", "maxelem", "timeout", "size", "markmask":
+			number, err := strconv.ParseUint(value, 0, 32)
+			if err != nil {
+				return 0, nil, fmt.Errorf("Syntax error: cannot parse %s as a %s value", value, name)
+			}
+			out = append(out, uint32Attribute(createOptionAttributes[name], uint32(number)))
+
+		case "netmask":
+			number, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return 0, nil, fmt.Errorf("Syntax error: cannot parse %s as a netmask value", value)
+			}
+			out = append(out, uint8Attribute(ipsetAttrNetMask, uint8(number)))
+
+		case "bucketsize":
+			number, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return 0, nil, fmt.Errorf("Syntax error: cannot parse %s as a bucketsize value", value)
+			}
+			out = append(out, uint8Attribute(ipsetAttrBucketSize, uint8(number)))
+
+		case "range":
+			var attributes []attribute
+			var err error
+			if setType == "bitmap:port" {
+				attributes, err = parsePortComponent(value, false)
+			} else {
+				attributes, err = parseIPComponent(value, 0, true)
+			}
+			if err != nil {
+				return 0, nil, err
+			}
+			out = append(out, attributes...)
+
+		case "counters":
+			flags |= ipsetFlagWithCounters
+		case "comment":
+			flags |= ipsetFlagWithComment
+		case "forceadd":
+			flags |= ipsetFlagWithForceAdd
+		case "skbinfo":
+			flags |= ipsetFlagWithSKBInfo
+
+		default:
+			return 0, nil, fmt.Errorf("Syntax error: unknown argument %s", name)
+		}
+	}
+
+	if flags != 0 {
+		out = append(out, uint32Attribute(ipsetAttrCADTFlags, flags))
+	}
+	return typeFamily(setType, family), out, nil
+}
+
+// parseEntryOptions returns the data attributes representing the options of an add, del or test command
+// (ex.: timeout, comment, counters, skbinfo and nomatch).
+func parseEntryOptions(options []string) ([]attribute, uint32, error) {
+	out := []attribute{}
+	flags := uint32(0)
+
+	for i := 0; i < len(options); i++ {
+		name := options[i]
+		value := ""
+		if optionHasValue(name, entryFlagOptions) {
+			if i+1 >= len(options) {
+				return nil, 0, fmt.Errorf("Syntax error: argument %s requires a value", name)
+			}
+			value = options[i+1]
+			i++
+		}
+
+		switch name {
+		case "timeout":
+			number, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a timeout value", value)
+			}
+			out = append(out, uint32Attribute(ipsetAttrTimeout, uint32(number)))
+
+		case "packets", "bytes":
+			number, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a %s value", value, name)
+			}
+			out = append(out, uint64Attribute(entryOptionAttributes[name], number))
+
+		case "comment":
+			comment := strings.Trim(value, `"`)
+			if len(comment) > ipsetMaxCommentSize {
+				return nil, 0, fmt.Errorf("Syntax error: comment is longer than %d characters", ipsetMaxCommentSize)
+			}
+			out = append(out, stringAttribute(ipsetAttrComment, comment))
+
+		case "skbmark":
+			values := strings.SplitN(value, "/", 2)
+			mark, err := strconv.ParseUint(values[0], 0, 32)
+			mask := uint64(0xffffffff)
+			if err == nil && len(values) == 2 {
+				mask, err = strconv.ParseUint(values[1], 0, 32)
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a skbmark value", value)
+			}
+			out = append(out, uint64Attribute(ipsetAttrSKBMark, mark<<32|mask))
+
+		case "skbprio":
+			values := strings.SplitN(value, ":", 2)
+			if len(values) != 2 {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a skbprio value", value)
+			}
+			major, errMajor := strconv.ParseUint(values[0], 16, 16)
+			minor, errMinor := strconv.ParseUint(values[1], 16, 16)
+			if errMajor != nil || errMinor != nil {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a skbprio value", value)
+			}
+			out = append(out, uint32Attribute(ipsetAttrSKBPrio, uint32(major<<16|minor)))
+
+		case "skbqueue":
+			number, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return nil, 0, fmt.Errorf("Syntax error: cannot parse %s as a skbqueue value", value)
+			}
+			out = append(out, uint16Attribute(ipsetAttrSKBQueue, uint16(number)))
+
+		case "nomatch":
+			flags |= ipsetFlagNoMatch
+
+		default:
+			return nil, 0, fmt.Errorf("Syntax error: unknown argument %s", name)
+		}
+	}
+	return out, flags, nil
+}
+
+// Create options that store a 32 bits value.
+var createOptionAttributes = map[string]uint16{
+	"hashsize": ipsetAttrHashSize,
+	"maxelem":  ipsetAttrMaxElem,
+	"timeout":  ipsetAttrTimeout,
+	"size":     ipsetAttrSize,
+	"markmask": ipsetAttrMarkMask,
+}
+
+// Entry options that store a 64 bits value.
+var entryOptionAttributes = map[string]uint16{
+	"packets": ipsetAttrPackets,
+	"bytes":   ipsetAttrBytes,
+}
+
+// Options that do not require a value.
+var createFlagOptions = []string{"counters", "comment", "forceadd", "skbinfo"}
+var entryFlagOptions = []string{"nomatch"}
+
+// optionHasValue returns true if option name is followed by a value, given the list of flag options that do not.
+func optionHasValue(name string, flagOptions []string) bool {
+	for _, flag := range flagOptions {
+		if name == flag {
+			return false
+		}
+	}
+	return true
+}
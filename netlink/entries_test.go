@@ -0,0 +1,69 @@
+package netlink
+
+import (
+	"testing"
+)
+
+func TestParseFormatElement(t *testing.T) {
+	type test struct {
+		setType string
+		element string
+		expects string
+	}
+
+	tests := []test{
+		{"hash:ip", "1.2.3.4", "1.2.3.4"},
+		{"hash:ip", "2001:db8::1", "2001:db8::1"},
+		{"hash:net", "10.0.0.0/8", "10.0.0.0/8"},
+		{"hash:ip,port", "1.2.3.4,80", "1.2.3.4,tcp:80"},
+		{"hash:ip,port", "1.2.3.4,udp:53", "1.2.3.4,udp:53"},
+		{"hash:net,iface", "10.0.0.0/8,eth0", "10.0.0.0/8,eth0"},
+		{"hash:mac", "00:11:22:33:44:55", "00:11:22:33:44:55"},
+		{"hash:ip,mark", "1.2.3.4,0x10", "1.2.3.4,0x00000010"},
+		{"bitmap:port", "8080", "8080"},
+		{"list:set", "other", "other"},
+	}
+
+	for i, test := range tests {
+		attributes, err := parseElement(test.setType, test.element)
+		if err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+		} else if result := formatElement(test.setType, attributes); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		}
+	}
+
+	invalid := []test{
+		{"hash:ip", "1.2.3", ""},
+		{"hash:ip,port", "1.2.3.4", ""},
+		{"hash:mac", "00:11", ""},
+	}
+
+	for i, test := range invalid {
+		if _, err := parseElement(test.setType, test.element); err == nil {
+			t.Errorf("expectation %d failed: element %s should be rejected", i+1, test.element)
+		}
+	}
+}
+
+func TestParseEntryOptions(t *testing.T) {
+	attributes, flags, err := parseEntryOptions([]string{"timeout", "60", "comment", "a comment", "nomatch"})
+	if err != nil {
+		t.Errorf("cannot parse options: %v", err)
+		return
+	}
+
+	if a, ok := findAttribute(attributes, ipsetAttrTimeout); !ok || a.uint32() != 60 {
+		t.Errorf("unexpected timeout: %v", attributes)
+	}
+	if a, ok := findAttribute(attributes, ipsetAttrComment); !ok || a.string() != "a comment" {
+		t.Errorf("unexpected comment: %v", attributes)
+	}
+	if flags&ipsetFlagNoMatch == 0 {
+		t.Errorf("unexpected flags: %d", flags)
+	}
+
+	if _, _, err := parseEntryOptions([]string{"timeout"}); err == nil {
+		t.Error("expectation failed: missing option value should be rejected")
+	}
+}
@@ -0,0 +1,159 @@
+package netlink
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// ipset specific error codes (see linux/netfilter/ipset/ip_set.h).
+const (
+	ipsetErrPrivate         = 4096
+	ipsetErrProtocol        = 4097
+	ipsetErrFindType        = 4098
+	ipsetErrMaxSets         = 4099
+	ipsetErrBusy            = 4100
+	ipsetErrExistSetName2   = 4101
+	ipsetErrTypeMismatch    = 4102
+	ipsetErrExist           = 4103
+	ipsetErrInvalidCIDR     = 4104
+	ipsetErrInvalidNetmask  = 4105
+	ipsetErrInvalidFamily   = 4106
+	ipsetErrTimeout         = 4107
+	ipsetErrReferenced      = 4108
+	ipsetErrIPAddrIPv4      = 4109
+	ipsetErrIPAddrIPv6      = 4110
+	ipsetErrCounter         = 4111
+	ipsetErrComment         = 4112
+	ipsetErrInvalidMarkmask = 4113
+	ipsetErrSKBInfo         = 4114
+
+	// Type specific error codes share the same range.
+	ipsetErrTypeSpecific = 4352
+)
+
+// errorMessage returns the message printed by ipset when the kernel returns an error for a given command.
+// Messages mirror those of libipset, so that errors are reported the same way by all executors.
+func errorMessage(command ipsetCommand, setType string, err error) string {
+	kernelErr, ok := err.(*kernelError)
+	if !ok {
+		return err.Error()
+	}
+
+	switch kernelErr.Code {
+	case int(syscall.ENOENT):
+		return "The set with the given name does not exist"
+	case int(syscall.EPERM):
+		return "Kernel error received: Operation not permitted"
+	case ipsetErrProtocol:
+		return "Kernel error received: ipset protocol error"
+	case ipsetErrFindType:
+		return "Kernel error received: set type not supported"
+	case ipsetErrMaxSets:
+		return "Kernel error received: maximal number of sets reached, cannot create more."
+	case ipsetErrInvalidCIDR:
+		return "The value of the CIDR parameter of the IP address is invalid"
+	case ipsetErrInvalidNetmask:
+		return "The value of the netmask parameter is invalid"
+	case ipsetErrInvalidMarkmask:
+		return "The value of the markmask parameter is invalid"
+	case ipsetErrInvalidFamily:
+		return "Protocol family not supported by the set type"
+	case ipsetErrTimeout:
+		return "Timeout cannot be used: set was created without timeout support"
+	case ipsetErrCounter:
+		return "Packet/byte counters cannot be used: set was created without counter support"
+	case ipsetErrComment:
+		return "Comment cannot be used: set was created without comment support"
+	case ipsetErrSKBInfo:
+		return "Skbinfo mapping cannot be used: set was created without skbinfo support"
+	case ipsetErrIPAddrIPv4:
+		return "An IPv4 address is expected, but not received"
+	case ipsetErrIPAddrIPv6:
+		return "An IPv6 address is expected, but not received"
+	}
+
+	switch command {
+	case ipsetCmdCreate:
+		if kernelErr.Code == ipsetErrExist {
+			return "Set cannot be created: set with the same name already exists"
+		} else if kernelErr.Code == ipsetErrTypeSpecific+1 && isBitmap(setType) {
+			return "The range you specified exceeds the size limit of the set type"
+		}
+	case ipsetCmdDestroy:
+		if kernelErr.Code == ipsetErrBusy {
+			return "Set cannot be destroyed: it is in use by a kernel component"
+		}
+	case ipsetCmdRename:
+		if kernelErr.Code == ipsetErrExistSetName2 {
+			return "Set cannot be renamed: a set with the new name already exists"
+		} else if kernelErr.Code == ipsetErrReferenced {
+			return "Set cannot be renamed: it is in use by another system"
+		}
+	case ipsetCmdSwap:
+		if kernelErr.Code == ipsetErrExistSetName2 {
+			return "Sets cannot be swapped: the second set does not exist"
+		} else if kernelErr.Code == ipsetErrTypeMismatch {
+			return "The sets cannot be swapped: their type does not match"
+		}
+	case ipsetCmdAdd:
+		if kernelErr.Code == ipsetErrExist {
+			return "Element cannot be added to the set: it's already added"
+		}
+	case ipsetCmdDel:
+		if kernelErr.Code == ipsetErrExist {
+			return "Element cannot be deleted from the set: it's not added"
+		}
+	}
+
+	if kernelErr.Code >= ipsetErrTypeSpecific {
+		if message := typeSpecificErrorMessage(setType, kernelErr.Code-ipsetErrTypeSpecific); message != "" {
+			return message
+		}
+	}
+
+	return fmt.Sprintf("Kernel error received: %s", kernelErr.Error())
+}
+
+// typeSpecificErrorMessage returns the message printed by ipset for a type specific error code.
+func typeSpecificErrorMessage(setType string, code int) string {
+	switch {
+	case isHash(setType):
+		switch code {
+		case 0:
+			return "Hash is full, cannot add more elements"
+		case 1:
+			return "Null-valued element, cannot be stored in a hash type of set"
+		case 2:
+			return "Invalid protocol specified"
+		case 3:
+			return "Protocol missing, but must be specified"
+		case 4:
+			return "Range is not supported in the \"net\" component of the element"
+		case 5:
+			return "Invalid range, covers the whole address space"
+		}
+	case isBitmap(setType):
+		switch code {
+		case 0:
+			return "Element is out of the range of the set"
+		case 1:
+			return "The range you specified exceeds the size limit of the set type"
+		}
+	case setType == "list:set":
+		switch code {
+		case 0:
+			return "Set to be added/deleted/tested as element does not exist."
+		case 1:
+			return "Sets with list:set type cannot be added to the set."
+		case 2:
+			return "No reference set specified."
+		case 3:
+			return "The set to which you referred with 'before' or 'after' does not exist."
+		case 4:
+			return "The set is full, more elements cannot be added."
+		case 5:
+			return "The element is already in the set before/after the reference set."
+		}
+	}
+	return ""
+}
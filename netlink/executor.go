@@ -0,0 +1,440 @@
+package netlink
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// Executor runs go-ipset commands by sending netlink messages to the kernel ipset subsystem.
+// Executor implements utilities.Executor: it accepts the same arguments of ipset and emulates its output.
+type Executor struct {
+	dial     func() (conn, error)
+	sequence uint32
+}
+
+// Executor must be usable wherever an ipset executor is expected, including ipset restore.
+var _ utilities.InputExecutor = &Executor{}
+
+// NewExecutor returns an Executor that communicates with the kernel of the running system.
+func NewExecutor() *Executor {
+	return &Executor{dial: dialKernel}
+}
+
+// invocation defines the arguments of an ipset command line, split into command, arguments and global options.
+type invocation struct {
+	Command string
+	Args    []string
+	Exist   bool
+	Output  string // plain, xml or save.
+	Terse   bool
+}
+
+// parseInvocation splits a list of ipset arguments into command, arguments and global options.
+func parseInvocation(args []string) (invocation, error) {
+	out := invocation{Output: "plain"}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-exist", "-!":
+			out.Exist = true
+		case "-output", "-o":
+			if i+1 >= len(args) {
+				return out, fmt.Errorf("Syntax error: argument %s requires a value", arg)
+			}
+			out.Output = args[i+1]
+			i++
+		case "-terse", "-t":
+			out.Terse = true
+		case "-quiet", "-q", "-resolve", "-r", "-sorted", "-s", "-name", "-n":
+			// Options not relevant for netlink, ignored.
+		default:
+			if out.Command == "" {
+				out.Command = commandAliases[arg]
+				if out.Command == "" {
+					return out, fmt.Errorf("No command specified: unknown argument %s", arg)
+				}
+			} else {
+				out.Args = append(out.Args, arg)
+			}
+		}
+	}
+
+	if out.Command == "" {
+		return out, fmt.Errorf("No command specified.")
+	}
+	return out, nil
+}
+
+// Command names and aliases accepted by ipset.
+var commandAliases = map[string]string{
+	"create": "create", "n": "create", "-N": "create",
+	"add": "add", "-A": "add",
+	"del": "del", "-D": "del",
+	"test": "test", "-T": "test",
+	"destroy": "destroy", "x": "destroy", "-X": "destroy",
+	"list": "list", "-L": "list",
+	"save": "save", "-S": "save",
+	"flush": "flush", "-F": "flush",
+	"rename": "rename", "e": "rename", "-E": "rename",
+	"swap": "swap", "w": "swap", "-W": "swap",
+	"version": "version", "-v": "version", "-V": "version",
+	"restore": "restore", "-R": "restore",
+}
+
+// Execute implementation of utilities.Executor.
+// Outputs and error messages mirror those of ipset, so that they can be parsed the same way.
+func (e *Executor) Execute(ctx context.Context, args ...string) ([]byte, error) {
+	return e.ExecuteWithInput(ctx, nil, args...)
+}
+
+// ExecuteWithInput implementation of utilities.InputExecutor.
+// Input is read only by command restore, whose lines are all run over the same connection.
+func (e *Executor) ExecuteWithInput(ctx context.Context, input io.Reader, args ...string) ([]byte, error) {
+	inv, err := parseInvocation(args)
+	if err != nil {
+		return []byte(err.Error() + "\n"), err
+	}
+
+	c, err := e.dial()
+	if err != nil {
+		return []byte("Cannot open session to kernel.\n"), err
+	}
+	defer c.close()
+
+	s := &session{conn: c, executor: e}
+
+	var out string
+	if inv.Command == "restore" {
+		out, err = s.restore(ctx, input, inv.Exist)
+	} else {
+		out, err = s.run(ctx, inv)
+	}
+
+	if err != nil && out == "" {
+		out = err.Error()
+	}
+	if out != "" && out[len(out)-1] != '\n' {
+		out += "\n"
+	}
+	return []byte(out), err
+}
+
+// nextSequence returns the sequence number of the next request.
+func (e *Executor) nextSequence() uint32 {
+	return atomic.AddUint32(&e.sequence, 1)
+}
+
+// session runs a single ipset command over a connection.
+type session struct {
+	conn     conn
+	executor *Executor
+}
+
+// request sends m and returns all messages received in response.
+func (s *session) request(ctx context.Context, m message) ([]message, error) {
+	m.Sequence = s.executor.nextSequence()
+	return roundTrip(ctx, s.conn, m)
+}
+
+// run runs the command described by inv and returns its output.
+// Errors are returned along with the message that ipset would print.
+func (s *session) run(ctx context.Context, inv invocation) (string, error) {
+	flags := []attribute{}
+	if inv.Exist {
+		flags = append(flags, uint32Attribute(ipsetAttrFlags, ipsetFlagExist))
+	}
+
+	switch inv.Command {
+	case "version":
+		return s.version(ctx)
+	case "create":
+		return s.create(ctx, inv.Args, flags)
+	case "add", "del", "test":
+		return s.addTestDelete(ctx, inv.Command, inv.Args, flags)
+	case "list":
+		return s.list(ctx, inv)
+	case "save":
+		inv.Output = "save"
+		return s.list(ctx, inv)
+	case "destroy", "flush":
+		return s.destroyFlush(ctx, inv.Command, inv.Args)
+	case "rename", "swap":
+		return s.renameSwap(ctx, inv.Command, inv.Args)
+	default:
+		err := fmt.Errorf("Command %s is not supported by the netlink executor", inv.Command)
+		return err.Error(), err
+	}
+}
+
+// version returns the version of the kernel ipset protocol.
+func (s *session) version(ctx context.Context) (string, error) {
+	messages, err := s.request(ctx, newRequest(ipsetCmdProtocol))
+	if err != nil {
+		return errorMessage(ipsetCmdProtocol, "", err), err
+	}
+
+	protocol := uint8(0)
+	for _, m := range messages {
+		if a, ok := m.attribute(ipsetAttrProtocol); ok {
+			protocol = a.uint8()
+		}
+	}
+	return fmt.Sprintf("ipset netlink executor, protocol version: %d", protocol), nil
+}
+
+// create runs command create: create SETNAME TYPENAME [ CREATE-OPTIONS ].
+func (s *session) create(ctx context.Context, args []string, flags []attribute) (string, error) {
+	if len(args) < 2 {
+		err := fmt.Errorf("Syntax error: missing mandatory argument(s) of command create")
+		return err.Error(), err
+	}
+
+	name, setType := args[0], args[1]
+	if err := validateSetName(name); err != nil {
+		return err.Error(), err
+	}
+
+	family, data, err := parseCreateOptions(setType, args[2:])
+	if err != nil {
+		return err.Error(), err
+	}
+
+	// Select the highest revision of the set type supported by the kernel.
+	typeMessages, err := s.request(ctx, newRequest(ipsetCmdType,
+		stringAttribute(ipsetAttrTypeName, setType),
+		uint8Attribute(ipsetAttrFamily, family),
+	))
+	if err != nil {
+		return errorMessage(ipsetCmdCreate, setType, err), err
+	}
+
+	revision := uint8(0)
+	for _, m := range typeMessages {
+		if a, ok := m.attribute(ipsetAttrRevision); ok {
+			revision = a.uint8()
+		}
+	}
+
+	attributes := []attribute{
+		stringAttribute(ipsetAttrSetName, name),
+		stringAttribute(ipsetAttrTypeName, setType),
+		uint8Attribute(ipsetAttrRevision, revision),
+		uint8Attribute(ipsetAttrFamily, family),
+	}
+	attributes = append(attributes, flags...)
+	attributes = append(attributes, nestedAttribute(ipsetAttrData, data...))
+
+	if _, err := s.request(ctx, newRequest(ipsetCmdCreate, attributes...)); err != nil {
+		return errorMessage(ipsetCmdCreate, setType, err), err
+	}
+	return "", nil
+}
+
+// addTestDelete runs commands add, del and test: command SETNAME ENTRY [ ADD-OPTIONS ].
+func (s *session) addTestDelete(ctx context.Context, command string, args []string, flags []attribute) (string, error) {
+	if len(args) < 2 {
+		err := fmt.Errorf("Syntax error: missing mandatory argument(s) of command %s", command)
+		return err.Error(), err
+	}
+
+	cmd := map[string]ipsetCommand{"add": ipsetCmdAdd, "del": ipsetCmdDel, "test": ipsetCmdTest}[command]
+	name, entry, options := args[0], args[1], args[2:]
+
+	setType, err := s.setType(ctx, name)
+	if err != nil {
+		return errorMessage(cmd, "", err), err
+	}
+
+	data, err := parseElement(setType, entry)
+	if err != nil {
+		return err.Error(), err
+	}
+
+	cadtFlags := uint32(0)
+	if setType == "list:set" && len(options) >= 2 && (options[0] == "before" || options[0] == "after") {
+		data = append(data, stringAttribute(ipsetAttrNameRef, options[1]))
+		if options[0] == "before" {
+			cadtFlags |= ipsetFlagBefore
+		}
+		options = options[2:]
+	}
+
+	optionAttributes, optionFlags, err := parseEntryOptions(options)
+	if err != nil {
+		return err.Error(), err
+	}
+	data = append(data, optionAttributes...)
+	cadtFlags |= optionFlags
+
+	data = mergeCADTFlags(data, cadtFlags)
+
+	attributes := []attribute{stringAttribute(ipsetAttrSetName, name)}
+	attributes = append(attributes, flags...)
+	attributes = append(attributes, nestedAttribute(ipsetAttrData, data...))
+
+	if _, err := s.request(ctx, newRequest(cmd, attributes...)); err != nil {
+		if kernelErr, ok := err.(*kernelError); ok && cmd == ipsetCmdTest && kernelErr.Code == ipsetErrExist {
+			return fmt.Sprintf("%s is NOT in set %s.", entry, name), err
+		}
+		return errorMessage(cmd, setType, err), err
+	}
+
+	if cmd == ipsetCmdTest {
+		return fmt.Sprintf("%s is in set %s.", entry, name), nil
+	}
+	return "", nil
+}
+
+// list runs commands list and save: command [ SETNAME ].
+func (s *session) list(ctx context.Context, inv invocation) (string, error) {
+	attributes := []attribute{}
+	if len(inv.Args) > 0 {
+		attributes = append(attributes, stringAttribute(ipsetAttrSetName, inv.Args[0]))
+	}
+
+	messages, err := s.request(ctx, newDumpRequest(ipsetCmdList, attributes...))
+	if err != nil {
+		return errorMessage(ipsetCmdList, "", err), err
+	}
+
+	sets := decodeListing(messages)
+	if inv.Terse {
+		for _, set := range sets {
+			set.Members = nil
+		}
+	}
+
+	switch inv.Output {
+	case "xml":
+		return formatListingXML(sets), nil
+	case "plain":
+		return formatListingPlain(sets), nil
+	case "save":
+		return formatListingSave(sets), nil
+	default:
+		err := fmt.Errorf("Syntax error: output format %s is not supported", inv.Output)
+		return err.Error(), err
+	}
+}
+
+// destroyFlush runs commands destroy and flush: command [ SETNAME ].
+func (s *session) destroyFlush(ctx context.Context, command string, args []string) (string, error) {
+	cmd := map[string]ipsetCommand{"destroy": ipsetCmdDestroy, "flush": ipsetCmdFlush}[command]
+
+	attributes := []attribute{}
+	if len(args) > 0 {
+		attributes = append(attributes, stringAttribute(ipsetAttrSetName, args[0]))
+	}
+
+	if _, err := s.request(ctx, newRequest(cmd, attributes...)); err != nil {
+		return errorMessage(cmd, "", err), err
+	}
+	return "", nil
+}
+
+// renameSwap runs commands rename and swap: command FROM-SETNAME TO-SETNAME.
+func (s *session) renameSwap(ctx context.Context, command string, args []string) (string, error) {
+	cmd := map[string]ipsetCommand{"rename": ipsetCmdRename, "swap": ipsetCmdSwap}[command]
+	if len(args) < 2 {
+		err := fmt.Errorf("Syntax error: missing mandatory argument(s) of command %s", command)
+		return err.Error(), err
+	}
+
+	if err := validateSetName(args[1]); err != nil {
+		return err.Error(), err
+	}
+
+	attributes := []attribute{
+		stringAttribute(ipsetAttrSetName, args[0]),
+		stringAttribute(ipsetAttrSetName2, args[1]),
+	}
+	if _, err := s.request(ctx, newRequest(cmd, attributes...)); err != nil {
+		return errorMessage(cmd, "", err), err
+	}
+	return "", nil
+}
+
+// restore runs command restore, reading one command per line from input.
+// Execution stops at the first failed command, reported along with its line number.
+func (s *session) restore(ctx context.Context, input io.Reader, exist bool) (string, error) {
+	if input == nil {
+		return "", nil
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text == "COMMIT" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := utilities.SplitIPSetLine(text)
+		if err != nil {
+			err = fmt.Errorf("Syntax error: %w", err)
+			return fmt.Sprintf("Error in line %d: %s", line, err.Error()), err
+		}
+
+		inv, err := parseInvocation(args)
+		if err == nil && inv.Command == "restore" {
+			err = fmt.Errorf("Command restore cannot be used in restore input")
+		}
+		if err != nil {
+			return fmt.Sprintf("Error in line %d: %s", line, err.Error()), err
+		}
+		inv.Exist = inv.Exist || exist
+
+		if out, err := s.run(ctx, inv); err != nil {
+			return fmt.Sprintf("Error in line %d: %s", line, strings.TrimSpace(out)), err
+		}
+	}
+	return "", scanner.Err()
+}
+
+// setType returns the type of the set named name.
+func (s *session) setType(ctx context.Context, name string) (string, error) {
+	messages, err := s.request(ctx, newRequest(ipsetCmdHeader, stringAttribute(ipsetAttrSetName, name)))
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range messages {
+		if a, ok := m.attribute(ipsetAttrTypeName); ok {
+			return a.string(), nil
+		}
+	}
+	return "", fmt.Errorf("Kernel error received: set type of %s is unknown", name)
+}
+
+// mergeCADTFlags merges all CADT flags attributes of data with flags into a single attribute.
+func mergeCADTFlags(data []attribute, flags uint32) []attribute {
+	out := []attribute{}
+	for _, a := range data {
+		if a.kind() == ipsetAttrCADTFlags {
+			flags |= a.uint32()
+		} else {
+			out = append(out, a)
+		}
+	}
+
+	if flags != 0 {
+		out = append(out, uint32Attribute(ipsetAttrCADTFlags, flags))
+	}
+	return out
+}
+
+// validateSetName returns an error if name cannot be used as a set name.
+func validateSetName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Syntax error: set name is empty")
+	} else if len(name) >= ipsetMaxNameLen {
+		return fmt.Errorf("Syntax error: setname '%s' is longer than %d characters", name, ipsetMaxNameLen-1)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package netlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/set"
+)
+
+func TestParseInvocation(t *testing.T) {
+	type test struct {
+		args    []string
+		expects string
+	}
+
+	tests := []test{
+		{[]string{"create", "test", "hash:ip"}, "{create [test hash:ip] false plain false}"},
+		{[]string{"add", "-exist", "test", "1.1.1.1"}, "{add [test 1.1.1.1] true plain false}"},
+		{[]string{"list", "test", "-output", "xml", "-terse"}, "{list [test] false xml true}"},
+		{[]string{"-N", "test", "hash:net"}, "{create [test hash:net] false plain false}"},
+	}
+
+	for i, test := range tests {
+		inv, err := parseInvocation(test.args)
+		if err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+		} else if result := fmt.Sprintf("%v", inv); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		}
+	}
+
+	for _, args := range [][]string{{}, {"unknown"}, {"list", "-output"}} {
+		if _, err := parseInvocation(args); err == nil {
+			t.Errorf("expectation failed: arguments %v should be rejected", args)
+		}
+	}
+}
+
+func TestExecutorCreate(t *testing.T) {
+	skipIfBigEndian(t)
+
+	c := &fakeConn{handler: func(m message) [][]byte {
+		if m.Command == ipsetCmdType {
+			return [][]byte{replyDatagram(m, uint8Attribute(ipsetAttrRevision, 6)), ackDatagram(m, 0)}
+		}
+		return [][]byte{ackDatagram(m, 0)}
+	}}
+
+	out, err := newFakeExecutor(c).Execute(testContext(), "create", "test", "hash:ip", "hashsize", "1024")
+	if err != nil {
+		t.Errorf("create failed: %v (%s)", err, out)
+		return
+	}
+
+	if result := fmt.Sprintf("%v", c.commands()); result != "[13 2]" {
+		t.Errorf("unexpected requests: %s", result)
+		return
+	}
+
+	// Compare with the fixture, ignoring the sequence number.
+	create := c.requests[1]
+	create.Sequence = 2
+	if result, expects := create.encode(), fixtureBytes(createHashSizeRequestFixture); string(result) != string(expects) {
+		t.Errorf("unexpected create request: %x", result)
+	}
+}
+
+func TestExecutorAddTestDelete(t *testing.T) {
+	skipIfBigEndian(t)
+
+	type test struct {
+		args    []string
+		code    int
+		output  string
+		failure bool
+	}
+
+	tests := []test{
+		{[]string{"add", "test", "1.2.3.4"}, 0, "", false},
+		{[]string{"add", "test", "1.2.3.4"}, ipsetErrExist, "Element cannot be added to the set: it's already added\n", true},
+		{[]string{"del", "test", "1.2.3.4"}, ipsetErrExist, "Element cannot be deleted from the set: it's not added\n", true},
+		{[]string{"test", "test", "1.2.3.4"}, 0, "1.2.3.4 is in set test.\n", false},
+		{[]string{"test", "test", "1.2.3.4"}, ipsetErrExist, "1.2.3.4 is NOT in set test.\n", true},
+		{[]string{"add", "missing", "1.2.3.4"}, int(syscall.ENOENT), "The set with the given name does not exist\n", true},
+	}
+
+	for i, test := range tests {
+		c := &fakeConn{handler: func(m message) [][]byte {
+			if m.Command == ipsetCmdHeader {
+				if name, _ := m.attribute(ipsetAttrSetName); name.string() == "missing" {
+					return [][]byte{ackDatagram(m, int(syscall.ENOENT))}
+				}
+				return [][]byte{replyDatagram(m, stringAttribute(ipsetAttrTypeName, "hash:ip")), ackDatagram(m, 0)}
+			}
+			return [][]byte{ackDatagram(m, test.code)}
+		}}
+
+		out, err := newFakeExecutor(c).Execute(testContext(), test.args...)
+		if (err != nil) != test.failure || string(out) != test.output {
+			t.Errorf("expectation %d failed: %q, %v", i+1, out, err)
+			continue
+		}
+
+		if test.args[1] == "missing" {
+			continue
+		}
+
+		// The element must be sent as a nested IPv4 address.
+		data, _ := c.requests[len(c.requests)-1].attribute(ipsetAttrData)
+		if ip, ok := findAttribute(data.Children, ipsetAttrIP); !ok || !ip.ip().Equal(net.ParseIP("1.2.3.4")) {
+			t.Errorf("expectation %d failed: unexpected data %v", i+1, data.Children)
+		}
+	}
+}
+
+func TestExecutorListSet(t *testing.T) {
+	skipIfBigEndian(t)
+
+	c := &fakeConn{datagrams: [][]byte{fixtureBytes(listResponseFixture)}}
+	e := newFakeExecutor(c)
+	e.sequence = 0 // The fixture answers sequence 1.
+
+	members, err := commands.NewListSet("test").RunWith(testContext(), e)
+	if err != nil {
+		t.Errorf("list failed: %v", err)
+	} else if result := fmt.Sprintf("%v", members); result != "[1.2.3.4]" {
+		t.Errorf("unexpected members: %s", result)
+	}
+
+	if len(c.requests) != 1 || !c.requests[0].isDump() {
+		t.Errorf("expectation failed: list should send a single dump request")
+	}
+
+	c = &fakeConn{datagrams: [][]byte{fixtureBytes(listResponseFixture)}}
+	e = newFakeExecutor(c)
+
+	info, err := commands.NewListSet("test").InfoWith(testContext(), e)
+	if err != nil {
+		t.Errorf("info failed: %v", err)
+	} else if result := fmt.Sprintf("%d %d %d %d %d", info.Revision, info.Header.HashSize, info.Header.MaxElements, info.Header.MemSize, info.Header.NumEntries); result != "6 1024 65536 256 1" {
+		t.Errorf("unexpected set info: %s", result)
+	}
+}
+
+func TestExecutorRestore(t *testing.T) {
+	skipIfBigEndian(t)
+
+	adds := 0
+	c := &fakeConn{handler: func(m message) [][]byte {
+		if m.Command == ipsetCmdHeader {
+			return [][]byte{replyDatagram(m, stringAttribute(ipsetAttrTypeName, "hash:ip")), ackDatagram(m, 0)}
+		} else if adds++; adds == 2 {
+			return [][]byte{ackDatagram(m, ipsetErrExist)}
+		}
+		return [][]byte{ackDatagram(m, 0)}
+	}}
+
+	b := commands.NewBatch().
+		Entry(commands.NewAddEntry("test", set.SetTypeHashIP, "1.1.1.1")).
+		Entry(commands.NewAddEntry("test", set.SetTypeHashIP, "2.2.2.2")).
+		Entry(commands.NewAddEntry("test", set.SetTypeHashIP, "3.3.3.3"))
+
+	var batchErr *commands.BatchError
+	if err := b.RunWith(testContext(), newFakeExecutor(c)); !errors.As(err, &batchErr) {
+		t.Errorf("expectation failed: batch should return a BatchError, received %v", err)
+	} else if batchErr.Line != 2 {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+
+	if result := fmt.Sprintf("%v", c.commands()); result != "[12 9 12 9]" {
+		t.Errorf("unexpected requests: %s", result)
+	}
+}
+
+func TestExecutorContext(t *testing.T) {
+	c := &fakeConn{handler: func(m message) [][]byte { return nil }}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := newFakeExecutor(c).Execute(ctx, "flush", "test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expectation failed: %v", err)
+	}
+}
+
+// Support.
+func testContext() context.Context {
+	return context.Background()
+}
+
+// newFakeExecutor returns an Executor whose connections are served by c.
+func newFakeExecutor(c *fakeConn) *Executor {
+	return &Executor{dial: func() (conn, error) { return c, nil }}
+}
+
+// fakeConn replays datagrams, then returns the datagrams built by handler in response to each request.
+// When no datagrams are left, receive blocks until ctx is done.
+type fakeConn struct {
+	datagrams [][]byte
+	handler   func(m message) [][]byte
+	requests  []message
+}
+
+// fakeConn implementation of send.
+func (c *fakeConn) send(b []byte) error {
+	sequence := nativeEndian.Uint32(b[8:12])
+	r, err := decodeResponse(b, sequence)
+	if err != nil {
+		return err
+	} else if len(r.Messages) != 1 {
+		return fmt.Errorf("unexpected request: %x", b)
+	}
+
+	m := r.Messages[0]
+	m.Flags = nativeEndian.Uint16(b[6:8])
+	c.requests = append(c.requests, m)
+
+	if c.handler != nil {
+		c.datagrams = append(c.datagrams, c.handler(m)...)
+	}
+	return nil
+}
+
+// fakeConn implementation of receive.
+func (c *fakeConn) receive(ctx context.Context) ([]byte, error) {
+	if len(c.datagrams) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	out := c.datagrams[0]
+	c.datagrams = c.datagrams[1:]
+	return out, nil
+}
+
+// fakeConn implementation of close.
+func (c *fakeConn) close() error {
+	return nil
+}
+
+// commands returns the commands of all requests received by c.
+func (c *fakeConn) commands() []ipsetCommand {
+	out := []ipsetCommand{}
+	for _, m := range c.requests {
+		out = append(out, m.Command)
+	}
+	return out
+}
+
+// replyDatagram returns a kernel reply to request containing attributes.
+func replyDatagram(request message, attributes ...attribute) []byte {
+	reply := message{Command: request.Command, Sequence: request.Sequence, Attributes: attributes}
+	return reply.encode()
+}
+
+// ackDatagram returns an acknowledgement of request, carrying error code if not 0.
+func ackDatagram(request message, code int) []byte {
+	out := make([]byte, nlmsgHeaderLen+4)
+	nativeEndian.PutUint32(out[0:4], uint32(len(out)))
+	nativeEndian.PutUint16(out[4:6], nlmsgError)
+	nativeEndian.PutUint32(out[8:12], request.Sequence)
+	nativeEndian.PutUint32(out[16:20], uint32(int32(-code)))
+	return out
+}
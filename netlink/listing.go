@@ -0,0 +1,298 @@
+package netlink
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// setListing defines the content of a set as returned by the kernel in response to a list command.
+type setListing struct {
+	Name     string
+	Type     string
+	Revision uint8
+	Family   uint8
+	Header   []attribute   // Data attributes of the set header.
+	Members  [][]attribute // Data attributes of each member.
+}
+
+// decodeListing merges the messages received in response to a list command into a list of sets.
+// The kernel may split sets across multiple messages: the header is sent only with the first one.
+func decodeListing(messages []message) []*setListing {
+	out := []*setListing{}
+	byName := map[string]*setListing{}
+
+	for _, m := range messages {
+		nameAttribute, ok := m.attribute(ipsetAttrSetName)
+		if !ok {
+			continue
+		}
+
+		name := nameAttribute.string()
+		listing, found := byName[name]
+		if !found {
+			listing = &setListing{Name: name}
+			byName[name] = listing
+			out = append(out, listing)
+		}
+
+		if a, ok := m.attribute(ipsetAttrTypeName); ok {
+			listing.Type = a.string()
+		}
+		if a, ok := m.attribute(ipsetAttrRevision); ok {
+			listing.Revision = a.uint8()
+		}
+		if a, ok := m.attribute(ipsetAttrFamily); ok {
+			listing.Family = a.uint8()
+		}
+		if a, ok := m.attribute(ipsetAttrData); ok {
+			listing.Header = a.Children
+		}
+		if a, ok := m.attribute(ipsetAttrADT); ok {
+			for _, member := range a.Children {
+				if member.kind() == ipsetAttrData {
+					listing.Members = append(listing.Members, member.Children)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// headerOption defines a formatted option of a set header.
+type headerOption struct {
+	Name  string
+	Value string // Empty for flags.
+}
+
+// headerOptions returns the options of the header of s, in the same order used by ipset.
+func (s *setListing) headerOptions() []headerOption {
+	out := []headerOption{}
+	if s.Family != familyUnspec {
+		out = append(out, headerOption{"family", familyName(s.Family)})
+	}
+
+	if from, ok := findAttribute(s.Header, ipsetAttrIP); ok {
+		rangeDef := from.ip().String()
+		if to, ok := findAttribute(s.Header, ipsetAttrIPTo); ok {
+			rangeDef += "-" + to.ip().String()
+		} else if cidr, ok := findAttribute(s.Header, ipsetAttrCIDR); ok {
+			rangeDef += fmt.Sprintf("/%d", cidr.uint8())
+		}
+		out = append(out, headerOption{"range", rangeDef})
+	} else if from, ok := findAttribute(s.Header, ipsetAttrPort); ok {
+		to, _ := findAttribute(s.Header, ipsetAttrPortTo)
+		out = append(out, headerOption{"range", fmt.Sprintf("%d-%d", from.uint16(), to.uint16())})
+	}
+
+	numeric := []struct {
+		name      string
+		attribute uint16
+	}{
+		{"hashsize", ipsetAttrHashSize},
+		{"maxelem", ipsetAttrMaxElem},
+		{"bucketsize", ipsetAttrBucketSize},
+		{"size", ipsetAttrSize},
+		{"netmask", ipsetAttrNetMask},
+		{"markmask", ipsetAttrMarkMask},
+		{"timeout", ipsetAttrTimeout},
+	}
+	for _, option := range numeric {
+		a, ok := findAttribute(s.Header, option.attribute)
+		if !ok {
+			continue
+		}
+
+		switch option.attribute {
+		case ipsetAttrNetMask, ipsetAttrBucketSize:
+			out = append(out, headerOption{option.name, strconv.Itoa(int(a.uint8()))})
+		case ipsetAttrMarkMask:
+			out = append(out, headerOption{option.name, fmt.Sprintf("0x%08x", a.uint32())})
+		default:
+			out = append(out, headerOption{option.name, strconv.FormatUint(uint64(a.uint32()), 10)})
+		}
+	}
+
+	if a, ok := findAttribute(s.Header, ipsetAttrCADTFlags); ok {
+		flags := a.uint32()
+		if flags&ipsetFlagWithCounters != 0 {
+			out = append(out, headerOption{Name: "counters"})
+		}
+		if flags&ipsetFlagWithComment != 0 {
+			out = append(out, headerOption{Name: "comment"})
+		}
+		if flags&ipsetFlagWithForceAdd != 0 {
+			out = append(out, headerOption{Name: "forceadd"})
+		}
+		if flags&ipsetFlagWithSKBInfo != 0 {
+			out = append(out, headerOption{Name: "skbinfo"})
+		}
+	}
+	return out
+}
+
+// headerValue returns the value of a numeric attribute of the header of s.
+func (s *setListing) headerValue(t uint16) uint32 {
+	a, _ := findAttribute(s.Header, t)
+	return a.uint32()
+}
+
+// numEntries returns the number of entries of s.
+func (s *setListing) numEntries() int {
+	if a, ok := findAttribute(s.Header, ipsetAttrElements); ok {
+		return int(a.uint32())
+	}
+	return len(s.Members)
+}
+
+// memberOptions returns the formatted extensions of a member (ex.: timeout, counters, comment).
+func memberOptions(member []attribute) []headerOption {
+	out := []headerOption{}
+	if a, ok := findAttribute(member, ipsetAttrTimeout); ok {
+		out = append(out, headerOption{"timeout", strconv.FormatUint(uint64(a.uint32()), 10)})
+	}
+	if a, ok := findAttribute(member, ipsetAttrPackets); ok {
+		out = append(out, headerOption{"packets", strconv.FormatUint(a.uint64(), 10)})
+	}
+	if a, ok := findAttribute(member, ipsetAttrBytes); ok {
+		out = append(out, headerOption{"bytes", strconv.FormatUint(a.uint64(), 10)})
+	}
+	if a, ok := findAttribute(member, ipsetAttrComment); ok {
+		out = append(out, headerOption{"comment", a.string()})
+	}
+	if a, ok := findAttribute(member, ipsetAttrSKBMark); ok {
+		value := a.uint64()
+		out = append(out, headerOption{"skbmark", fmt.Sprintf("0x%x/0x%x", value>>32, value&0xffffffff)})
+	}
+	if a, ok := findAttribute(member, ipsetAttrSKBPrio); ok {
+		value := a.uint32()
+		out = append(out, headerOption{"skbprio", fmt.Sprintf("%x:%x", value>>16, value&0xffff)})
+	}
+	if a, ok := findAttribute(member, ipsetAttrSKBQueue); ok {
+		out = append(out, headerOption{"skbqueue", strconv.Itoa(int(a.uint16()))})
+	}
+	if a, ok := findAttribute(member, ipsetAttrCADTFlags); ok && a.uint32()&ipsetFlagNoMatch != 0 {
+		out = append(out, headerOption{Name: "nomatch"})
+	}
+	return out
+}
+
+// Output formats.
+
+// formatListingXML returns the representation of a list of sets produced by ipset with option -output xml.
+func formatListingXML(sets []*setListing) string {
+	var b strings.Builder
+	b.WriteString("<ipsets>\n")
+
+	for _, s := range sets {
+		fmt.Fprintf(&b, "<ipset name=\"%s\">\n", escapeXML(s.Name))
+		fmt.Fprintf(&b, "<type>%s</type>\n", escapeXML(s.Type))
+		fmt.Fprintf(&b, "<revision>%d</revision>\n", s.Revision)
+
+		b.WriteString("<header>\n")
+		for _, option := range s.headerOptions() {
+			writeXMLOption(&b, option)
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "<memsize>%d</memsize>\n", s.headerValue(ipsetAttrMemSize))
+		fmt.Fprintf(&b, "<references>%d</references>\n", s.headerValue(ipsetAttrReferences))
+		fmt.Fprintf(&b, "<numentries>%d</numentries>\n", s.numEntries())
+		b.WriteString("</header>\n")
+
+		b.WriteString("<members>\n")
+		for _, member := range s.Members {
+			fmt.Fprintf(&b, "<member><elem>%s</elem>", escapeXML(formatElement(s.Type, member)))
+			for _, option := range memberOptions(member) {
+				writeXMLOption(&b, option)
+			}
+			b.WriteString("</member>\n")
+		}
+		b.WriteString("</members>\n")
+		b.WriteString("</ipset>\n")
+	}
+
+	b.WriteString("</ipsets>\n")
+	return b.String()
+}
+
+// formatListingPlain returns the representation of a list of sets produced by ipset with option -output plain.
+func formatListingPlain(sets []*setListing) string {
+	var b strings.Builder
+	for i, s := range sets {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "Name: %s\n", s.Name)
+		fmt.Fprintf(&b, "Type: %s\n", s.Type)
+		fmt.Fprintf(&b, "Revision: %d\n", s.Revision)
+		fmt.Fprintf(&b, "Header: %s\n", formatPlainOptions(s.headerOptions()))
+		fmt.Fprintf(&b, "Size in memory: %d\n", s.headerValue(ipsetAttrMemSize))
+		fmt.Fprintf(&b, "References: %d\n", s.headerValue(ipsetAttrReferences))
+		fmt.Fprintf(&b, "Number of entries: %d\n", s.numEntries())
+		b.WriteString("Members:\n")
+
+		for _, member := range s.Members {
+			b.WriteString(formatElement(s.Type, member))
+			if options := memberOptions(member); len(options) > 0 {
+				b.WriteString(" " + formatPlainOptions(options))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// formatListingSave returns the representation of a list of sets produced by ipset save (ipset list -output save).
+func formatListingSave(sets []*setListing) string {
+	var b strings.Builder
+	for _, s := range sets {
+		fmt.Fprintf(&b, "create %s %s", s.Name, s.Type)
+		if options := s.headerOptions(); len(options) > 0 {
+			b.WriteString(" " + formatPlainOptions(options))
+		}
+		b.WriteString("\n")
+
+		for _, member := range s.Members {
+			fmt.Fprintf(&b, "add %s %s", s.Name, formatElement(s.Type, member))
+			if options := memberOptions(member); len(options) > 0 {
+				b.WriteString(" " + formatPlainOptions(options))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// formatPlainOptions returns the plain text representation of a list of options.
+func formatPlainOptions(options []headerOption) string {
+	out := []string{}
+	for _, option := range options {
+		switch {
+		case option.Value == "":
+			out = append(out, option.Name)
+		case option.Name == "comment":
+			out = append(out, fmt.Sprintf(`%s "%s"`, option.Name, option.Value))
+		default:
+			out = append(out, option.Name+" "+option.Value)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// writeXMLOption writes the XML representation of option to b.
+func writeXMLOption(b *strings.Builder, option headerOption) {
+	if option.Value == "" {
+		fmt.Fprintf(b, "<%s/>", option.Name)
+	} else {
+		fmt.Fprintf(b, "<%s>%s</%s>", option.Name, escapeXML(option.Value), option.Name)
+	}
+}
+
+// escapeXML returns in with XML special characters escaped.
+func escapeXML(in string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(in))
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// message defines an ipset netlink message.
+type message struct {
+	Command    ipsetCommand
+	Flags      uint16
+	Sequence   uint32
+	Attributes []attribute
+}
+
+// newRequest returns a request message for a given command, including the protocol attribute.
+func newRequest(command ipsetCommand, attributes ...attribute) message {
+	all := []attribute{uint8Attribute(ipsetAttrProtocol, ipsetProtocol)}
+	all = append(all, attributes...)
+	return message{Command: command, Flags: nlmFlagRequest | nlmFlagAck, Attributes: all}
+}
+
+// newDumpRequest returns a dump request message for a given command, including the protocol attribute.
+func newDumpRequest(command ipsetCommand, attributes ...attribute) message {
+	out := newRequest(command, attributes...)
+	out.Flags = nlmFlagRequest | nlmFlagDump
+	return out
+}
+
+// isDump returns true if m is a dump request.
+func (m message) isDump() bool {
+	return m.Flags&nlmFlagDump == nlmFlagDump
+}
+
+// attribute returns the first attribute of m whose type matches t.
+func (m message) attribute(t uint16) (attribute, bool) {
+	return findAttribute(m.Attributes, t)
+}
+
+// encode returns the wire representation of m, including netlink and nfnetlink headers.
+func (m message) encode() []byte {
+	payload := encodeAttributes(m.Attributes)
+	out := make([]byte, nlmsgHeaderLen+nfgenHeaderLen, nlmsgHeaderLen+nfgenHeaderLen+len(payload))
+
+	nativeEndian.PutUint32(out[0:4], uint32(nlmsgHeaderLen+nfgenHeaderLen+len(payload)))
+	nativeEndian.PutUint16(out[4:6], m.Command.messageType())
+	nativeEndian.PutUint16(out[6:8], m.Flags)
+	nativeEndian.PutUint32(out[8:12], m.Sequence)
+	nativeEndian.PutUint32(out[12:16], 0) // Port ID, assigned by the kernel.
+
+	out[16] = familyINet // nfgen_family.
+	out[17] = nfnetlinkV0
+	// Bytes 18-19 (res_id) are always 0.
+
+	return append(out, payload...)
+}
+
+// kernelError defines an error returned by the kernel in response to a request.
+type kernelError struct {
+	Code int // Positive errno or ipset specific error code.
+}
+
+// Error returns a description of e.
+func (e *kernelError) Error() string {
+	if e.Code >= ipsetErrPrivate {
+		return fmt.Sprintf("ipset kernel error %d", e.Code)
+	} else {
+		return syscall.Errno(e.Code).Error()
+	}
+}
+
+// response defines the outcome of decoding a netlink datagram.
+type response struct {
+	Messages []message
+	Done     bool  // true if NLMSG_DONE or an acknowledgement was received.
+	Err      error // Kernel error, if any.
+}
+
+// decodeResponse parses all netlink messages contained in a datagram received from the kernel.
+// Messages whose sequence number does not match sequence are ignored.
+func decodeResponse(in []byte, sequence uint32) (response, error) {
+	out := response{}
+	for len(in) > 0 {
+		if len(in) < nlmsgHeaderLen {
+			return out, fmt.Errorf("netlink message is truncated (%d bytes)", len(in))
+		}
+
+		length := int(nativeEndian.Uint32(in[0:4]))
+		if length < nlmsgHeaderLen || length > len(in) {
+			return out, fmt.Errorf("netlink message has invalid length %d", length)
+		}
+
+		msgType := nativeEndian.Uint16(in[4:6])
+		flags := nativeEndian.Uint16(in[6:8])
+		msgSequence := nativeEndian.Uint32(in[8:12])
+		payload := in[nlmsgHeaderLen:length]
+
+		if next := align(length); next < len(in) {
+			in = in[next:]
+		} else {
+			in = nil
+		}
+
+		if msgSequence != sequence {
+			continue
+		}
+
+		switch msgType {
+		case nlmsgDone:
+			out.Done = true
+		case nlmsgError:
+			if len(payload) < 4 {
+				return out, fmt.Errorf("netlink error message is truncated (%d bytes)", len(payload))
+			}
+			if code := int32(nativeEndian.Uint32(payload[0:4])); code != 0 {
+				out.Err = &kernelError{Code: int(-code)}
+			}
+			out.Done = true
+		default:
+			if msgType>>8 != nfnlSubsysIPSet || len(payload) < nfgenHeaderLen {
+				continue
+			}
+
+			attributes, err := decodeAttributes(payload[nfgenHeaderLen:])
+			if err != nil {
+				return out, err
+			}
+
+			out.Messages = append(out.Messages, message{
+				Command:    ipsetCommand(msgType & 0xff),
+				Flags:      flags,
+				Sequence:   msgSequence,
+				Attributes: attributes,
+			})
+		}
+	}
+	return out, nil
+}
+
+// conn defines a datagram connection to the kernel ipset subsystem.
+type conn interface {
+	// send sends a datagram to the kernel.
+	send(b []byte) error
+
+	// receive returns the next datagram received from the kernel.
+	// Implementations must return ctx.Err() once ctx is done.
+	receive(ctx context.Context) ([]byte, error)
+
+	// close releases all resources used by the connection.
+	close() error
+}
+
+// roundTrip sends request m through c and returns all messages received in response.
+// Dump requests are read until NLMSG_DONE, all other requests until their acknowledgement.
+func roundTrip(ctx context.Context, c conn, m message) ([]message, error) {
+	if err := c.send(m.encode()); err != nil {
+		return nil, err
+	}
+
+	out := []message{}
+	for {
+		datagram, err := c.receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := decodeResponse(datagram, m.Sequence)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, r.Messages...)
+		if r.Err != nil {
+			return out, r.Err
+		} else if r.Done {
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Package rest exposes sets and entries managed by go-ipset through an HTTP API.
+//
+// Resources served by Handler:
+//
+//	GET    /sets                          List all sets (headers only, ?members=true includes members).
+//	POST   /sets                          Create a set described by a CreateRequest.
+//	GET    /sets/{set}                    Get header and members of a set.
+//	DELETE /sets/{set}                    Destroy a set.
+//	POST   /sets/{set}/flush              Flush a set.
+//	POST   /sets/{set}/entries            Add entries described by an EntriesRequest.
+//	PUT    /sets/{set}/entries            Atomically replace all entries with those of an EntriesRequest.
+//	DELETE /sets/{set}/entries            Delete entries described by an EntriesRequest.
+//	GET    /sets/{set}/entries/{entry}    Test an entry, 404 if it is not in the set.
+//	PUT    /sets/{set}/entries/{entry}    Add an entry.
+//	DELETE /sets/{set}/entries/{entry}    Delete an entry.
+//
+// Entries in paths must be escaped (ex.: 10.0.0.0%2F8). Errors are returned as an Error object,
+// with a status code derived from the typed errors of go-ipset.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// MaxRequestSize is the maximum size of request bodies, in bytes.
+const MaxRequestSize = 64 << 20
+
+// Handler serves the API using a given client; mount it with http.StripPrefix to serve it below a path.
+type Handler struct {
+	client *commands.Client
+}
+
+// NewHandler returns a Handler that runs all commands with client.
+func NewHandler(client *commands.Client) *Handler {
+	return &Handler{client: client}
+}
+
+// errNotFound is returned for unknown resources.
+var errNotFound = errors.New("resource not found")
+
+// errBadRequest is returned for requests that cannot be decoded.
+var errBadRequest = errors.New("bad request")
+
+// statusCodes maps errors to HTTP status codes; the first matching error is used.
+var statusCodes = []struct {
+	err    error
+	status int
+}{
+	{errNotFound, http.StatusNotFound},
+	{errBadRequest, http.StatusBadRequest},
+	{liberrors.ErrIPSetCommandIsInvalid, http.StatusBadRequest},
+	{liberrors.ErrIPSetOptionNotSupported, http.StatusBadRequest},
+	{liberrors.ErrIPSetNoSuchSet, http.StatusNotFound},
+	{liberrors.ErrIPSetElementMissing, http.StatusNotFound},
+	{liberrors.ErrIPSetSetExists, http.StatusConflict},
+	{liberrors.ErrIPSetElementExists, http.StatusConflict},
+	{liberrors.ErrIPSetSetInUse, http.StatusConflict},
+	{liberrors.ErrIPSetIncompatibleSets, http.StatusConflict},
+	{liberrors.ErrIPSetSetIsFull, http.StatusInsufficientStorage},
+	{liberrors.ErrIPSetKernelModuleMissing, http.StatusServiceUnavailable},
+	{liberrors.ErrIPSetPermissionDenied, http.StatusServiceUnavailable},
+	{liberrors.ErrIPSetTimeout, http.StatusGatewayTimeout},
+	{liberrors.ErrIPSetCanceled, http.StatusServiceUnavailable},
+}
+
+// ServeHTTP implementation of http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, err := splitPath(r.URL.EscapedPath())
+	if err != nil || len(path) == 0 || path[0] != "sets" {
+		writeError(w, errNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	switch {
+	case len(path) == 1:
+		h.serveSets(w, r)
+	case len(path) == 2:
+		h.serveSet(w, r, path[1])
+	case len(path) == 3 && path[2] == "flush":
+		h.serveFlush(w, r, path[1])
+	case len(path) == 3 && path[2] == "entries":
+		h.serveEntries(w, r, path[1])
+	case len(path) == 4 && path[2] == "entries":
+		h.serveEntry(w, r, path[1], path[3])
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+// serveSets serves /sets.
+func (h *Handler) serveSets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list := commands.NewListSet("")
+		list.Terse = r.URL.Query().Get("members") != "true"
+
+		infos, err := list.SetsWith(r.Context(), utilities.ExecutorOrDefault(h.client.Executor))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		out := make([]Set, len(infos))
+		for i, info := range infos {
+			out[i] = NewSet(info)
+		}
+		writeJSON(w, http.StatusOK, out)
+
+	case http.MethodPost:
+		var request CreateRequest
+		if err := decodeRequest(r, &request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		create, err := newCreate(request)
+		if err == nil {
+			create.Exist = request.Exist || h.client.Exist
+			err = create.RunWith(r.Context(), utilities.ExecutorOrDefault(h.client.Executor))
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		h.writeSet(w, r.Context(), create.Name, http.StatusCreated)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// serveSet serves /sets/{set}.
+func (h *Handler) serveSet(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeSet(w, r.Context(), name, http.StatusOK)
+	case http.MethodDelete:
+		if err := commands.NewDestroySet(name).RunWith(r.Context(), utilities.ExecutorOrDefault(h.client.Executor)); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// serveFlush serves /sets/{set}/flush.
+func (h *Handler) serveFlush(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	if err := commands.NewFlushSet(name).RunWith(r.Context(), utilities.ExecutorOrDefault(h.client.Executor)); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveEntries serves /sets/{set}/entries.
+func (h *Handler) serveEntries(w http.ResponseWriter, r *http.Request, name string) {
+	var command commands.CommandName
+	switch r.Method {
+	case http.MethodPost:
+		command = commands.CommandNameAdd
+	case http.MethodDelete:
+		command = commands.CommandNameDelete
+	case http.MethodPut:
+		// Entries are replaced.
+	default:
+		writeMethodNotAllowed(w, http.MethodPost, http.MethodPut, http.MethodDelete)
+		return
+	}
+
+	var request EntriesRequest
+	if err := decodeRequest(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var err error
+	if r.Method == http.MethodPut {
+		err = h.replaceEntries(r.Context(), name, request)
+	} else {
+		err = h.runEntries(r.Context(), command, name, request)
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveEntry serves /sets/{set}/entries/{entry}.
+func (h *Handler) serveEntry(w http.ResponseWriter, r *http.Request, name, entry string) {
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		var setType set.SetType
+		if setType, err = h.setType(r.Context(), name); err != nil {
+			break
+		}
+
+		var contains bool
+		if contains, err = commands.NewTestEntry(name, setType, entry).ContainsWith(r.Context(), utilities.ExecutorOrDefault(h.client.Executor)); err != nil {
+			break
+		} else if contains {
+			writeJSON(w, http.StatusOK, TestResult{Set: name, Entry: entry, Contains: true})
+		} else {
+			writeJSON(w, http.StatusNotFound, TestResult{Set: name, Entry: entry, Contains: false})
+		}
+		return
+
+	case http.MethodPut:
+		err = h.runEntries(r.Context(), commands.CommandNameAdd, name, EntriesRequest{Entries: []string{entry}})
+	case http.MethodDelete:
+		err = h.runEntries(r.Context(), commands.CommandNameDelete, name, EntriesRequest{Entries: []string{entry}})
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+		return
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Support.
+
+// setType returns the type of the set named name, as listed by ipset.
+func (h *Handler) setType(ctx context.Context, name string) (set.SetType, error) {
+	list := commands.NewListSet(name)
+	list.Terse = true
+
+	info, err := list.InfoWith(ctx, utilities.ExecutorOrDefault(h.client.Executor))
+	if err != nil {
+		return set.SetTypeUnsupported, err
+	}
+	return info.Type, nil
+}
+
+// runEntries adds or deletes entries of a request with a single batch; nothing is run if any entry is invalid.
+func (h *Handler) runEntries(ctx context.Context, command commands.CommandName, name string, request EntriesRequest) error {
+	if len(request.Entries) == 0 {
+		return fmt.Errorf("%w: entries are missing", errBadRequest)
+	}
+
+	setType, err := h.setType(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	batch := commands.NewBatch()
+	batch.Exist = request.Exist
+	for _, entry := range request.Entries {
+		e := &commands.AddTestDeleteEntry{Command: command, Name: name, Type: setType, Entry: entry}
+		if command == commands.CommandNameAdd {
+			e.Timeout = request.Timeout
+			e.Comment = request.Comment
+			e.NoMatch = request.NoMatch
+		}
+		batch.Entry(e)
+	}
+	return h.client.RunBatch(ctx, batch)
+}
+
+// replaceEntries atomically replaces all entries of the set named name.
+func (h *Handler) replaceEntries(ctx context.Context, name string, request EntriesRequest) error {
+	if request.Timeout != 0 || request.Comment != "" || request.NoMatch {
+		return fmt.Errorf("%w: entry options are not supported when entries are replaced", errBadRequest)
+	}
+	return h.client.ReplaceSetContents(ctx, name, request.Entries)
+}
+
+// writeSet writes header and members of the set named name.
+func (h *Handler) writeSet(w http.ResponseWriter, ctx context.Context, name string, status int) {
+	info, err := commands.NewListSet(name).InfoWith(ctx, utilities.ExecutorOrDefault(h.client.Executor))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, NewSet(*info))
+}
+
+// newCreate returns the create command described by request.
+func newCreate(request CreateRequest) (*commands.CreateSet, error) {
+	out := &commands.CreateSet{
+		Command: commands.CommandNameCreate, Name: request.Name, Type: set.SetTypeWithString(request.Type),
+		HashSize: request.HashSize, MaxElements: request.MaxElem, Size: request.Size,
+		NetMask: request.NetMask, MarkMask: request.MarkMask, Timeout: request.Timeout,
+		UseCounters: request.Counters, AllowsComments: request.Comment, UseSKBInfo: request.SKBInfo, ForceAdd: request.ForceAdd,
+	}
+
+	switch request.Family {
+	case "":
+	case "inet":
+		out.ProtocolFamily = commands.ProtocolFamilyINet
+	case "inet6":
+		out.ProtocolFamily = commands.ProtocolFamilyINet6
+	default:
+		return nil, fmt.Errorf("%w: unsupported family %s", errBadRequest, request.Family)
+	}
+
+	if out.Type == set.SetTypeBitmapPort {
+		out.PortRange = request.Range
+	} else {
+		out.IPRange = request.Range
+	}
+	return out, out.Validate()
+}
+
+// splitPath returns the unescaped components of an escaped path.
+func splitPath(escaped string) ([]string, error) {
+	out := []string{}
+	for _, component := range strings.Split(strings.Trim(escaped, "/"), "/") {
+		unescaped, err := url.PathUnescape(component)
+		if err != nil {
+			return nil, err
+		} else if unescaped != "" {
+			out = append(out, unescaped)
+		}
+	}
+	return out, nil
+}
+
+// decodeRequest decodes the JSON body of r into v.
+func decodeRequest(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return nil
+}
+
+// statusCode returns the HTTP status code matching err.
+func statusCode(err error) int {
+	for _, code := range statusCodes {
+		if errors.Is(err, code.err) {
+			return code.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes v as the JSON body of a response with a given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as an Error object, with the status code matching err.
+func writeError(w http.ResponseWriter, err error) {
+	out := Error{Error: err.Error()}
+	if errors.Is(err, liberrors.ErrIPSetPermissionDenied) {
+		// Not a failure of the request: the server is misconfigured.
+		out.Error = fmt.Sprintf("%s (the server is not allowed to run ipset, CAP_NET_ADMIN is required)", out.Error)
+	}
+	var ipsetErr *liberrors.IPSetError
+	if errors.As(err, &ipsetErr) {
+		out.Command = ipsetErr.Command
+		out.Set = ipsetErr.Set
+	}
+	writeJSON(w, statusCode(err), out)
+}
+
+// writeMethodNotAllowed writes a 405 response listing allowed methods.
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, Error{Error: "method not allowed"})
+}
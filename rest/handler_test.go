@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestHandler(t *testing.T) {
+	type test struct {
+		method  string
+		path    string
+		body    string
+		outputs []string
+		errors  []error
+		calls   []string
+		input   string
+		status  int
+		reply   string
+	}
+
+	exitErr := errors.New("exit status 1")
+	tests := []test{
+		{
+			method:  http.MethodGet,
+			path:    "/sets",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("a", "hash:ip", 0), utilitiestest.SetOutput("b", "list:set", 0))},
+			calls:   []string{"list -terse -output xml"},
+			status:  http.StatusOK,
+			reply: `[{"name":"a","type":"hash:ip","memsize":0,"references":0,"numentries":0},` +
+				`{"name":"b","type":"list:set","memsize":0,"references":0,"numentries":0}]`,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/sets/a",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("a", "hash:ip", 0, utilitiestest.Members("1.1.1.1")...))},
+			calls:   []string{"list a -output xml"},
+			status:  http.StatusOK,
+			reply:   `{"name":"a","type":"hash:ip","memsize":0,"references":0,"numentries":0,"members":[{"entry":"1.1.1.1"}]}`,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/sets/a",
+			outputs: []string{"ipset v7.1: The set with the given name does not exist\n"},
+			errors:  []error{exitErr},
+			calls:   []string{"list a -output xml"},
+			status:  http.StatusNotFound,
+			reply:   `{"error":"ipset returned error \"The set with the given name does not exist\"","command":"list","set":"a"}`,
+		},
+		{
+			method:  http.MethodPost,
+			path:    "/sets",
+			body:    `{"name":"ports","type":"bitmap:port","range":"0-1024"}`,
+			outputs: []string{"", utilitiestest.ListOutput(utilitiestest.SetOutput("ports", "bitmap:port", 0))},
+			calls:   []string{"create ports bitmap:port range 0-1024", "list ports -output xml"},
+			status:  http.StatusCreated,
+			reply:   `{"name":"ports","type":"bitmap:port","memsize":0,"references":0,"numentries":0}`,
+		},
+		{
+			method: http.MethodPost,
+			path:   "/sets",
+			body:   `{"name":"test","type":"hash:ip","netmask":40}`,
+			status: http.StatusBadRequest,
+		},
+		{method: http.MethodPost, path: "/sets", body: `{"name":"test","type":"hash:ip","family":"ipx"}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/sets", body: `{"name":"test","unknown":1}`, status: http.StatusBadRequest},
+		{
+			method:  http.MethodPost,
+			path:    "/sets",
+			body:    `{"name":"test","type":"hash:ip"}`,
+			outputs: []string{"ipset v7.1: Set cannot be created: set with the same name already exists\n"},
+			errors:  []error{exitErr},
+			calls:   []string{"create test hash:ip"},
+			status:  http.StatusConflict,
+		},
+		{method: http.MethodDelete, path: "/sets/test", calls: []string{"destroy test"}, status: http.StatusNoContent},
+		{
+			method:  http.MethodDelete,
+			path:    "/sets/test",
+			outputs: []string{"ipset v7.1: Set cannot be destroyed: it is in use by a kernel component\n"},
+			errors:  []error{exitErr},
+			calls:   []string{"destroy test"},
+			status:  http.StatusConflict,
+		},
+		{method: http.MethodPost, path: "/sets/test/flush", calls: []string{"flush test"}, status: http.StatusNoContent},
+		{
+			method:  http.MethodPost,
+			path:    "/sets/test/entries",
+			body:    `{"entries":["1.1.1.1","2.2.2.2"],"timeout":10}`,
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0))},
+			calls:   []string{"list test -terse -output xml", "restore"},
+			input:   "add test 1.1.1.1 timeout 10\nadd test 2.2.2.2 timeout 10\n",
+			status:  http.StatusNoContent,
+		},
+		{
+			method:  http.MethodPost,
+			path:    "/sets/test/entries",
+			body:    `{"entries":["1.1.1.1","invalid"]}`,
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0))},
+			calls:   []string{"list test -terse -output xml"},
+			status:  http.StatusBadRequest,
+		},
+		{method: http.MethodPost, path: "/sets/test/entries", body: `{"entries":[]}`, status: http.StatusBadRequest},
+		{
+			method:  http.MethodDelete,
+			path:    "/sets/test/entries",
+			body:    `{"entries":["1.1.1.1"],"timeout":10}`,
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0))},
+			calls:   []string{"list test -terse -output xml", "restore"},
+			input:   "del test 1.1.1.1\n",
+			status:  http.StatusNoContent,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/sets/nets/entries/10.0.0.0%2F8",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("nets", "hash:net", 0)), ""},
+			calls:   []string{"list nets -terse -output xml", "test nets 10.0.0.0/8"},
+			status:  http.StatusOK,
+			reply:   `{"set":"nets","entry":"10.0.0.0/8","contains":true}`,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/sets/test/entries/1.1.1.1",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0)), "ipset v7.1: 1.1.1.1 is NOT in set test.\n"},
+			errors:  []error{nil, exitErr},
+			calls:   []string{"list test -terse -output xml", "test test 1.1.1.1"},
+			status:  http.StatusNotFound,
+			reply:   `{"set":"test","entry":"1.1.1.1","contains":false}`,
+		},
+		{
+			method:  http.MethodPut,
+			path:    "/sets/test/entries/1.1.1.1",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0))},
+			calls:   []string{"list test -terse -output xml", "restore"},
+			input:   "add test 1.1.1.1\n",
+			status:  http.StatusNoContent,
+		},
+		{
+			method:  http.MethodDelete,
+			path:    "/sets/test/entries/1.1.1.1",
+			outputs: []string{utilitiestest.ListOutput(utilitiestest.SetOutput("test", "hash:ip", 0)), "ipset v7.1: Kernel error received: Operation not permitted\n"},
+			errors:  []error{nil, exitErr},
+			calls:   []string{"list test -terse -output xml", "restore"},
+			input:   "del test 1.1.1.1\n",
+			status:  http.StatusServiceUnavailable,
+			reply: `{"error":"ipset returned error \"Kernel error received: Operation not permitted\"` +
+				` (the server is not allowed to run ipset, CAP_NET_ADMIN is required)","command":"restore"}`,
+		},
+		{method: http.MethodPatch, path: "/sets/test", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/sets/test/unknown", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/unknown", status: http.StatusNotFound},
+	}
+
+	for i, test := range tests {
+		executor := &utilitiestest.InputExecutor{Executor: utilitiestest.Executor{Outputs: test.outputs, Errors: test.errors}}
+		handler := NewHandler(commands.NewClient(executor))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+		calls := make([]string, len(executor.Calls))
+		for j, call := range executor.Calls {
+			calls[j] = strings.Join(call, " ")
+		}
+
+		if recorder.Code != test.status {
+			t.Errorf("expectation %d failed: status %d != %d (expected), %s", i+1, recorder.Code, test.status, recorder.Body.String())
+		} else if result, expects := fmt.Sprintf("%q", calls), fmt.Sprintf("%q", test.calls); len(calls)+len(test.calls) > 0 && result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		} else if strings.Join(executor.Inputs, "") != test.input {
+			t.Errorf("expectation %d failed: input %q != %q (expected)", i+1, strings.Join(executor.Inputs, ""), test.input)
+		} else if reply := strings.TrimSpace(recorder.Body.String()); test.reply != "" && reply != test.reply {
+			t.Errorf("expectation %d failed: reply %s != %s (expected)", i+1, reply, test.reply)
+		}
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := map[error]int{
+		errors.New("unknown"):                                    http.StatusInternalServerError,
+		liberrors.ErrIPSetCommandIsInvalid:                       http.StatusBadRequest,
+		liberrors.ErrIPSetElementMissing:                         http.StatusNotFound,
+		liberrors.ErrIPSetIncompatibleSets:                       http.StatusConflict,
+		liberrors.ErrIPSetSetIsFull:                              http.StatusInsufficientStorage,
+		liberrors.ErrIPSetKernelModuleMissing:                    http.StatusServiceUnavailable,
+		liberrors.ErrIPSetPermissionDenied:                       http.StatusServiceUnavailable,
+		liberrors.ErrIPSetTimeout:                                http.StatusGatewayTimeout,
+		&liberrors.IPSetError{Kind: liberrors.ErrIPSetNoSuchSet}: http.StatusNotFound,
+	}
+
+	for err, expects := range tests {
+		if result := statusCode(err); result != expects {
+			t.Errorf("expectation failed for %v: %d != %d (expected)", err, result, expects)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+)
+
+// Set describes a set listed by ipset, as returned by the API.
+type Set struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Family     string   `json:"family,omitempty"`
+	Range      string   `json:"range,omitempty"`
+	HashSize   int      `json:"hashsize,omitempty"`
+	MaxElem    int      `json:"maxelem,omitempty"`
+	Size       int      `json:"size,omitempty"`
+	NetMask    int      `json:"netmask,omitempty"`
+	MarkMask   int      `json:"markmask,omitempty"`
+	Timeout    int      `json:"timeout,omitempty"`
+	Counters   bool     `json:"counters,omitempty"`
+	Comment    bool     `json:"comment,omitempty"`
+	SKBInfo    bool     `json:"skbinfo,omitempty"`
+	ForceAdd   bool     `json:"forceadd,omitempty"`
+	MemSize    int      `json:"memsize"`
+	References int      `json:"references"`
+	NumEntries int      `json:"numentries"`
+	Members    []Member `json:"members,omitempty"`
+}
+
+// Member describes an entry of a set listed by ipset, as returned by the API.
+type Member struct {
+	Entry    string `json:"entry"`
+	Timeout  int    `json:"timeout,omitempty"`
+	Packets  uint64 `json:"packets,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+	SKBMark  string `json:"skbmark,omitempty"` // Formatted as mark/mask.
+	SKBPrio  string `json:"skbprio,omitempty"`
+	SKBQueue int    `json:"skbqueue,omitempty"`
+	NoMatch  bool   `json:"nomatch,omitempty"`
+}
+
+// CreateRequest describes a set created through the API; options follow the naming of ipset.
+type CreateRequest struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Family   string `json:"family,omitempty"` // inet or inet6.
+	Range    string `json:"range,omitempty"`  // IP range of bitmap:ip and bitmap:ip,mac sets, port range of bitmap:port sets.
+	HashSize int    `json:"hashsize,omitempty"`
+	MaxElem  int    `json:"maxelem,omitempty"`
+	Size     int    `json:"size,omitempty"`
+	NetMask  int    `json:"netmask,omitempty"`
+	MarkMask int    `json:"markmask,omitempty"`
+	Timeout  int    `json:"timeout,omitempty"`
+	Counters bool   `json:"counters,omitempty"`
+	Comment  bool   `json:"comment,omitempty"`
+	SKBInfo  bool   `json:"skbinfo,omitempty"`
+	ForceAdd bool   `json:"forceadd,omitempty"`
+	Exist    bool   `json:"exist,omitempty"`
+}
+
+// EntriesRequest describes entries added, deleted or replaced through the API.
+// Options are used only when entries are added.
+type EntriesRequest struct {
+	Entries []string `json:"entries"`
+	Timeout int      `json:"timeout,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+	NoMatch bool     `json:"nomatch,omitempty"`
+	Exist   bool     `json:"exist,omitempty"`
+}
+
+// TestResult describes the outcome of a membership test.
+type TestResult struct {
+	Set      string `json:"set"`
+	Entry    string `json:"entry"`
+	Contains bool   `json:"contains"`
+}
+
+// Error describes a failed request.
+type Error struct {
+	Error   string `json:"error"`
+	Command string `json:"command,omitempty"` // ipset command, if ipset failed.
+	Set     string `json:"set,omitempty"`
+}
+
+// NewSet returns the description of a set listed by ipset.
+func NewSet(info commands.SetInfo) Set {
+	header := info.Header
+	out := Set{
+		Name: info.Name, Type: info.Type.String(), Range: header.Range,
+		HashSize: header.HashSize, MaxElem: header.MaxElements, Size: header.Size,
+		NetMask: header.NetMask, MarkMask: header.MarkMask, Timeout: header.Timeout,
+		Counters: header.UseCounters, Comment: header.AllowsComments, SKBInfo: header.UseSKBInfo, ForceAdd: header.ForceAdd,
+		MemSize: header.MemSize, References: header.References, NumEntries: header.NumEntries,
+	}
+	if header.ProtocolFamily != commands.ProtocolFamilyDefault {
+		out.Family = header.ProtocolFamily.String()
+	}
+
+	for _, member := range info.Members {
+		m := Member{
+			Entry: member.Entry, Timeout: member.Timeout, Packets: member.Packets, Bytes: member.Bytes,
+			Comment: member.Comment, SKBPrio: member.SKBPrio, SKBQueue: member.SKBQueue, NoMatch: member.NoMatch,
+		}
+		if member.SKBMark != 0 || member.SKBMarkMask != 0 {
+			m.SKBMark = fmt.Sprintf("0x%x/0x%x", member.SKBMark, member.SKBMarkMask)
+		}
+		out.Members = append(out.Members, m)
+	}
+	return out
+}
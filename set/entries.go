@@ -0,0 +1,560 @@
+package set
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Entry defines a typed entry of a set, formatted with the syntax of ipset.
+// Each set type has its own Entry, so that entries cannot be added to sets of other types.
+type Entry interface {
+	// SetType returns the type of sets that can contain the entry.
+	SetType() SetType
+
+	// String returns the entry formatted as an argument of ipset add, del and test commands.
+	String() string
+}
+
+// BitmapIPEntry defines an entry of bitmap:ip sets.
+type BitmapIPEntry struct {
+	Addr netip.Addr
+}
+
+// BitmapIPMACEntry defines an entry of bitmap:ip,mac sets; MAC is optional.
+type BitmapIPMACEntry struct {
+	Addr netip.Addr
+	MAC  net.HardwareAddr
+}
+
+// BitmapPortEntry defines an entry of bitmap:port sets; Proto is optional (tcp or udp), and it is not stored by the kernel.
+type BitmapPortEntry struct {
+	Proto string
+	Port  uint16
+}
+
+// HashIPEntry defines an entry of hash:ip sets.
+type HashIPEntry struct {
+	Addr netip.Addr
+}
+
+// HashIPMACEntry defines an entry of hash:ip,mac sets.
+type HashIPMACEntry struct {
+	Addr netip.Addr
+	MAC  net.HardwareAddr
+}
+
+// HashIPPortEntry defines an entry of hash:ip,port sets; Proto is optional (tcp if empty).
+// For icmp and icmpv6, Port is the type and code of messages, as returned by ICMPPort.
+type HashIPPortEntry struct {
+	Addr  netip.Addr
+	Proto string
+	Port  uint16
+}
+
+// HashIPPortIPEntry defines an entry of hash:ip,port,ip sets; Proto and Port are defined as in HashIPPortEntry.
+type HashIPPortIPEntry struct {
+	Addr      netip.Addr
+	Proto     string
+	Port      uint16
+	OtherAddr netip.Addr
+}
+
+// HashIPPortNetEntry defines an entry of hash:ip,port,net sets; Proto and Port are defined as in HashIPPortEntry.
+type HashIPPortNetEntry struct {
+	Addr   netip.Addr
+	Proto  string
+	Port   uint16
+	Prefix netip.Prefix
+}
+
+// HashIPMarkEntry defines an entry of hash:ip,mark sets.
+type HashIPMarkEntry struct {
+	Addr netip.Addr
+	Mark uint32
+}
+
+// HashMACEntry defines an entry of hash:mac sets.
+type HashMACEntry struct {
+	MAC net.HardwareAddr
+}
+
+// HashNetEntry defines an entry of hash:net sets.
+type HashNetEntry struct {
+	Prefix netip.Prefix
+}
+
+// HashNetNetEntry defines an entry of hash:net,net sets.
+type HashNetNetEntry struct {
+	Prefix      netip.Prefix
+	OtherPrefix netip.Prefix
+}
+
+// HashNetPortEntry defines an entry of hash:net,port sets; Proto and Port are defined as in HashIPPortEntry.
+type HashNetPortEntry struct {
+	Prefix netip.Prefix
+	Proto  string
+	Port   uint16
+}
+
+// HashNetPortNetEntry defines an entry of hash:net,port,net sets; Proto and Port are defined as in HashIPPortEntry.
+type HashNetPortNetEntry struct {
+	Prefix      netip.Prefix
+	Proto       string
+	Port        uint16
+	OtherPrefix netip.Prefix
+}
+
+// HashNetIFaceEntry defines an entry of hash:net,iface sets.
+type HashNetIFaceEntry struct {
+	Prefix  netip.Prefix
+	PhysDev bool // Match the physical device of bridged traffic.
+	IFace   string
+}
+
+// ListSetEntry defines an entry of list:set sets.
+type ListSetEntry struct {
+	Name string
+}
+
+// NewBitmapIPEntry returns an entry of bitmap:ip sets, or an error if addr is not an IPv4 address.
+func NewBitmapIPEntry(addr netip.Addr) (BitmapIPEntry, error) {
+	if err := validateBitmapAddr(addr); err != nil {
+		return BitmapIPEntry{}, err
+	}
+	return BitmapIPEntry{Addr: addr}, nil
+}
+
+// NewBitmapIPMACEntry returns an entry of bitmap:ip,mac sets, or an error if addr is not an IPv4 address; mac may be nil.
+func NewBitmapIPMACEntry(addr netip.Addr, mac net.HardwareAddr) (BitmapIPMACEntry, error) {
+	if err := validateBitmapAddr(addr); err != nil {
+		return BitmapIPMACEntry{}, err
+	}
+	return BitmapIPMACEntry{Addr: addr, MAC: mac}, nil
+}
+
+// NewBitmapPortEntry returns an entry of bitmap:port sets; proto may be empty.
+func NewBitmapPortEntry(proto string, port uint16) BitmapPortEntry {
+	return BitmapPortEntry{Proto: proto, Port: port}
+}
+
+// NewHashIPEntry returns an entry of hash:ip sets.
+func NewHashIPEntry(addr netip.Addr) HashIPEntry {
+	return HashIPEntry{Addr: addr}
+}
+
+// NewHashIPMACEntry returns an entry of hash:ip,mac sets.
+func NewHashIPMACEntry(addr netip.Addr, mac net.HardwareAddr) HashIPMACEntry {
+	return HashIPMACEntry{Addr: addr, MAC: mac}
+}
+
+// ICMPPort returns the value of Port of entries matching icmp or icmpv6 messages of a given type and code.
+func ICMPPort(icmpType, code uint8) uint16 {
+	return uint16(icmpType)<<8 | uint16(code)
+}
+
+// NewHashIPPortEntry returns an entry of hash:ip,port sets; proto may be empty.
+func NewHashIPPortEntry(addr netip.Addr, proto string, port uint16) HashIPPortEntry {
+	return HashIPPortEntry{Addr: addr, Proto: proto, Port: port}
+}
+
+// NewHashIPPortIPEntry returns an entry of hash:ip,port,ip sets; proto may be empty.
+func NewHashIPPortIPEntry(addr netip.Addr, proto string, port uint16, otherAddr netip.Addr) HashIPPortIPEntry {
+	return HashIPPortIPEntry{Addr: addr, Proto: proto, Port: port, OtherAddr: otherAddr}
+}
+
+// NewHashIPPortNetEntry returns an entry of hash:ip,port,net sets; proto may be empty.
+func NewHashIPPortNetEntry(addr netip.Addr, proto string, port uint16, prefix netip.Prefix) HashIPPortNetEntry {
+	return HashIPPortNetEntry{Addr: addr, Proto: proto, Port: port, Prefix: prefix}
+}
+
+// NewHashIPMarkEntry returns an entry of hash:ip,mark sets.
+func NewHashIPMarkEntry(addr netip.Addr, mark uint32) HashIPMarkEntry {
+	return HashIPMarkEntry{Addr: addr, Mark: mark}
+}
+
+// NewHashMACEntry returns an entry of hash:mac sets.
+func NewHashMACEntry(mac net.HardwareAddr) HashMACEntry {
+	return HashMACEntry{MAC: mac}
+}
+
+// NewHashNetEntry returns an entry of hash:net sets.
+func NewHashNetEntry(prefix netip.Prefix) HashNetEntry {
+	return HashNetEntry{Prefix: prefix}
+}
+
+// NewHashNetNetEntry returns an entry of hash:net,net sets.
+func NewHashNetNetEntry(prefix, otherPrefix netip.Prefix) HashNetNetEntry {
+	return HashNetNetEntry{Prefix: prefix, OtherPrefix: otherPrefix}
+}
+
+// NewHashNetPortEntry returns an entry of hash:net,port sets; proto may be empty.
+func NewHashNetPortEntry(prefix netip.Prefix, proto string, port uint16) HashNetPortEntry {
+	return HashNetPortEntry{Prefix: prefix, Proto: proto, Port: port}
+}
+
+// NewHashNetPortNetEntry returns an entry of hash:net,port,net sets; proto may be empty.
+func NewHashNetPortNetEntry(prefix netip.Prefix, proto string, port uint16, otherPrefix netip.Prefix) HashNetPortNetEntry {
+	return HashNetPortNetEntry{Prefix: prefix, Proto: proto, Port: port, OtherPrefix: otherPrefix}
+}
+
+// NewHashNetIFaceEntry returns an entry of hash:net,iface sets.
+func NewHashNetIFaceEntry(prefix netip.Prefix, physDev bool, iface string) HashNetIFaceEntry {
+	return HashNetIFaceEntry{Prefix: prefix, PhysDev: physDev, IFace: iface}
+}
+
+// NewListSetEntry returns an entry of list:set sets.
+func NewListSetEntry(name string) ListSetEntry {
+	return ListSetEntry{Name: name}
+}
+
+// SetType implementations.
+func (e BitmapIPEntry) SetType() SetType       { return SetTypeBitmapIP }
+func (e BitmapIPMACEntry) SetType() SetType    { return SetTypeBitmapIPMAC }
+func (e BitmapPortEntry) SetType() SetType     { return SetTypeBitmapPort }
+func (e HashIPEntry) SetType() SetType         { return SetTypeHashIP }
+func (e HashIPMACEntry) SetType() SetType      { return SetTypeHashIPMAC }
+func (e HashIPPortEntry) SetType() SetType     { return SetTypeHashIPPort }
+func (e HashIPPortIPEntry) SetType() SetType   { return SetTypeHashIPPortIP }
+func (e HashIPPortNetEntry) SetType() SetType  { return SetTypeHashIPPortNet }
+func (e HashIPMarkEntry) SetType() SetType     { return SetTypeHashIPMark }
+func (e HashMACEntry) SetType() SetType        { return SetTypeHashMAC }
+func (e HashNetEntry) SetType() SetType        { return SetTypeHashNet }
+func (e HashNetNetEntry) SetType() SetType     { return SetTypeHashNetNet }
+func (e HashNetPortEntry) SetType() SetType    { return SetTypeHashNetPort }
+func (e HashNetPortNetEntry) SetType() SetType { return SetTypeHashNetPortNet }
+func (e HashNetIFaceEntry) SetType() SetType   { return SetTypeHashNetIFace }
+func (e ListSetEntry) SetType() SetType        { return SetTypeListSet }
+
+// String implementations.
+func (e BitmapIPEntry) String() string {
+	return e.Addr.String()
+}
+func (e BitmapIPMACEntry) String() string {
+	if len(e.MAC) == 0 {
+		return e.Addr.String()
+	} else {
+		return e.Addr.String() + "," + formatMAC(e.MAC)
+	}
+}
+func (e BitmapPortEntry) String() string {
+	return strconv.Itoa(int(e.Port)) // Listed without protocol.
+}
+func (e HashIPEntry) String() string {
+	return e.Addr.String()
+}
+func (e HashIPMACEntry) String() string {
+	return e.Addr.String() + "," + formatMAC(e.MAC)
+}
+func (e HashIPPortEntry) String() string {
+	return e.Addr.String() + "," + formatPort(e.Proto, e.Port)
+}
+func (e HashIPPortIPEntry) String() string {
+	return e.Addr.String() + "," + formatPort(e.Proto, e.Port) + "," + e.OtherAddr.String()
+}
+func (e HashIPPortNetEntry) String() string {
+	return e.Addr.String() + "," + formatPort(e.Proto, e.Port) + "," + formatPrefix(e.Prefix)
+}
+func (e HashIPMarkEntry) String() string {
+	return fmt.Sprintf("%s,0x%08x", e.Addr, e.Mark)
+}
+func (e HashMACEntry) String() string {
+	return formatMAC(e.MAC)
+}
+func (e HashNetEntry) String() string {
+	return formatPrefix(e.Prefix)
+}
+func (e HashNetNetEntry) String() string {
+	return formatPrefix(e.Prefix) + "," + formatPrefix(e.OtherPrefix)
+}
+func (e HashNetPortEntry) String() string {
+	return formatPrefix(e.Prefix) + "," + formatPort(e.Proto, e.Port)
+}
+func (e HashNetPortNetEntry) String() string {
+	return formatPrefix(e.Prefix) + "," + formatPort(e.Proto, e.Port) + "," + formatPrefix(e.OtherPrefix)
+}
+func (e HashNetIFaceEntry) String() string {
+	if e.PhysDev {
+		return formatPrefix(e.Prefix) + ",physdev:" + e.IFace
+	} else {
+		return formatPrefix(e.Prefix) + "," + e.IFace
+	}
+}
+func (e ListSetEntry) String() string {
+	return e.Name
+}
+
+// ParseEntry parses an entry of a set of type setType, formatted as listed by ipset (ex.: 1.1.1.1,tcp:80 for hash:ip,port).
+func ParseEntry(setType SetType, in string) (Entry, error) {
+	parts := strings.Split(strings.TrimSpace(in), ",")
+	requireParts := func(counts ...int) error {
+		for _, count := range counts {
+			if len(parts) == count {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected number of components")
+	}
+
+	var err error
+	switch setType {
+	case SetTypeBitmapIP:
+		var out BitmapIPEntry
+		if err = requireParts(1); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				err = validateBitmapAddr(out.Addr)
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeBitmapIPMAC:
+		var out BitmapIPMACEntry
+		if err = requireParts(1, 2); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				err = validateBitmapAddr(out.Addr)
+			}
+			if err == nil && len(parts) == 2 {
+				out.MAC, err = net.ParseMAC(parts[1])
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeBitmapPort:
+		var out BitmapPortEntry
+		if err = requireParts(1); err == nil {
+			if out.Proto, out.Port, err = parsePort(parts[0]); err == nil && out.Proto != "" && out.Proto != "tcp" && out.Proto != "udp" {
+				err = fmt.Errorf("unsupported protocol %s", out.Proto)
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIP:
+		var out HashIPEntry
+		if err = requireParts(1); err == nil {
+			out.Addr, err = netip.ParseAddr(parts[0])
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIPMAC:
+		var out HashIPMACEntry
+		if err = requireParts(2); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				out.MAC, err = net.ParseMAC(parts[1])
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIPPort:
+		var out HashIPPortEntry
+		if err = requireParts(2); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				out.Proto, out.Port, err = parsePort(parts[1])
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIPPortIP:
+		var out HashIPPortIPEntry
+		if err = requireParts(3); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				if out.Proto, out.Port, err = parsePort(parts[1]); err == nil {
+					out.OtherAddr, err = netip.ParseAddr(parts[2])
+				}
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIPPortNet:
+		var out HashIPPortNetEntry
+		if err = requireParts(3); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				if out.Proto, out.Port, err = parsePort(parts[1]); err == nil {
+					out.Prefix, err = parsePrefix(parts[2])
+				}
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashIPMark:
+		var out HashIPMarkEntry
+		if err = requireParts(2); err == nil {
+			if out.Addr, err = netip.ParseAddr(parts[0]); err == nil {
+				var mark uint64
+				mark, err = strconv.ParseUint(parts[1], 0, 32)
+				out.Mark = uint32(mark)
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashMAC:
+		var out HashMACEntry
+		if err = requireParts(1); err == nil {
+			out.MAC, err = net.ParseMAC(parts[0])
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashNet:
+		var out HashNetEntry
+		if err = requireParts(1); err == nil {
+			out.Prefix, err = parsePrefix(parts[0])
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashNetNet:
+		var out HashNetNetEntry
+		if err = requireParts(2); err == nil {
+			if out.Prefix, err = parsePrefix(parts[0]); err == nil {
+				out.OtherPrefix, err = parsePrefix(parts[1])
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashNetPort:
+		var out HashNetPortEntry
+		if err = requireParts(2); err == nil {
+			if out.Prefix, err = parsePrefix(parts[0]); err == nil {
+				out.Proto, out.Port, err = parsePort(parts[1])
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashNetPortNet:
+		var out HashNetPortNetEntry
+		if err = requireParts(3); err == nil {
+			if out.Prefix, err = parsePrefix(parts[0]); err == nil {
+				if out.Proto, out.Port, err = parsePort(parts[1]); err == nil {
+					out.OtherPrefix, err = parsePrefix(parts[2])
+				}
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeHashNetIFace:
+		var out HashNetIFaceEntry
+		if err = requireParts(2); err == nil {
+			if out.Prefix, err = parsePrefix(parts[0]); err == nil {
+				out.IFace = parts[1]
+				if strings.HasPrefix(out.IFace, "physdev:") {
+					out.PhysDev = true
+					out.IFace = strings.TrimPrefix(out.IFace, "physdev:")
+				}
+				if out.IFace == "" {
+					err = fmt.Errorf("missing interface")
+				}
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	case SetTypeListSet:
+		var out ListSetEntry
+		if err = requireParts(1); err == nil {
+			if out.Name = parts[0]; out.Name == "" {
+				err = fmt.Errorf("missing set name")
+			}
+		}
+		return parsedEntry(setType, in, out, err)
+
+	default:
+		return nil, fmt.Errorf("unsupported set type %v", setType)
+	}
+}
+
+// Support.
+
+// parsedEntry returns out, or an error describing why entry in of a set of type setType cannot be parsed if err is not nil.
+func parsedEntry(setType SetType, in string, out Entry, err error) (Entry, error) {
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v entry %s: %w", setType, in, err)
+	}
+	return out, nil
+}
+
+// formatMAC returns a MAC address formatted as listed by ipset (ex.: A1:2B:C3:4D:E5:6F).
+func formatMAC(mac net.HardwareAddr) string {
+	return strings.ToUpper(mac.String())
+}
+
+// formatPort returns a port formatted as listed by ipset (ex.: tcp:80, icmp:8/0); proto defaults to tcp.
+func formatPort(proto string, port uint16) string {
+	if proto == "" {
+		proto = "tcp"
+	}
+
+	if isICMP(proto) {
+		return fmt.Sprintf("%s:%d/%d", proto, port>>8, port&0xff)
+	} else {
+		return fmt.Sprintf("%s:%d", proto, port)
+	}
+}
+
+// formatPrefix returns a prefix formatted as listed by ipset: host bits are cleared and
+// prefixes including a single address are formatted without cidr.
+func formatPrefix(prefix netip.Prefix) string {
+	if prefix.Bits() == prefix.Addr().BitLen() {
+		return prefix.Addr().String()
+	} else {
+		return prefix.Masked().String()
+	}
+}
+
+// parsePort parses a port formatted as [proto:]port; the port of icmp and icmpv6 is formatted as type/code.
+func parsePort(in string) (string, uint16, error) {
+	proto := ""
+	if i := strings.LastIndex(in, ":"); i >= 0 {
+		proto, in = in[:i], in[i+1:]
+		if proto == "" {
+			return "", 0, fmt.Errorf("missing protocol")
+		}
+	}
+
+	if isICMP(proto) {
+		icmpType, code, found := strings.Cut(in, "/")
+		if !found {
+			return "", 0, fmt.Errorf("%s type and code must be formatted as type/code", proto)
+		}
+
+		t, err := strconv.ParseUint(icmpType, 10, 8)
+		if err != nil {
+			return "", 0, err
+		}
+		c, err := strconv.ParseUint(code, 10, 8)
+		if err != nil {
+			return "", 0, err
+		}
+		return proto, ICMPPort(uint8(t), uint8(c)), nil
+	}
+
+	port, err := strconv.ParseUint(in, 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	return proto, uint16(port), nil
+}
+
+// isICMP returns true if proto is icmp or icmpv6 (listed as ipv6-icmp by some systems).
+func isICMP(proto string) bool {
+	return proto == "icmp" || proto == "icmpv6" || proto == "ipv6-icmp"
+}
+
+// validateBitmapAddr returns an error if addr cannot be stored by bitmap sets, which support only IPv4 addresses.
+func validateBitmapAddr(addr netip.Addr) error {
+	if !addr.Is4() {
+		return fmt.Errorf("address %v is not an IPv4 address", addr)
+	}
+	return nil
+}
+
+// parsePrefix parses a prefix formatted as ip[/cidr]; addresses without cidr are single address prefixes.
+func parsePrefix(in string) (netip.Prefix, error) {
+	if strings.Contains(in, "/") {
+		return netip.ParsePrefix(in)
+	}
+
+	addr, err := netip.ParseAddr(in)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,115 @@
+package set
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestEntry_String(t *testing.T) {
+	type test struct {
+		entry   Entry
+		setType SetType
+		expects string
+	}
+
+	addr := netip.MustParseAddr("1.1.1.1")
+	otherAddr := netip.MustParseAddr("2.2.2.2")
+	prefix := netip.MustParsePrefix("10.0.0.1/24")
+	mac, _ := net.ParseMAC("a1:2b:c3:4d:e5:6f")
+	bitmapIP, _ := NewBitmapIPEntry(addr)
+	bitmapIPMAC, _ := NewBitmapIPMACEntry(addr, nil)
+	bitmapIPWithMAC, _ := NewBitmapIPMACEntry(addr, mac)
+
+	tests := []test{
+		{bitmapIP, SetTypeBitmapIP, "1.1.1.1"},
+		{bitmapIPMAC, SetTypeBitmapIPMAC, "1.1.1.1"},
+		{bitmapIPWithMAC, SetTypeBitmapIPMAC, "1.1.1.1,A1:2B:C3:4D:E5:6F"},
+		{NewBitmapPortEntry("", 80), SetTypeBitmapPort, "80"},
+		{NewBitmapPortEntry("udp", 53), SetTypeBitmapPort, "53"},
+		{NewHashIPEntry(netip.MustParseAddr("2001:db8::1")), SetTypeHashIP, "2001:db8::1"},
+		{NewHashIPMACEntry(addr, mac), SetTypeHashIPMAC, "1.1.1.1,A1:2B:C3:4D:E5:6F"},
+		{NewHashIPPortEntry(addr, "tcp", 80), SetTypeHashIPPort, "1.1.1.1,tcp:80"},
+		{NewHashIPPortEntry(addr, "", 80), SetTypeHashIPPort, "1.1.1.1,tcp:80"},
+		{NewHashIPPortEntry(addr, "icmp", ICMPPort(8, 0)), SetTypeHashIPPort, "1.1.1.1,icmp:8/0"},
+		{NewHashIPPortIPEntry(addr, "udp", 53, otherAddr), SetTypeHashIPPortIP, "1.1.1.1,udp:53,2.2.2.2"},
+		{NewHashIPPortNetEntry(addr, "", 22, prefix), SetTypeHashIPPortNet, "1.1.1.1,tcp:22,10.0.0.0/24"},
+		{NewHashIPMarkEntry(addr, 10), SetTypeHashIPMark, "1.1.1.1,0x0000000a"},
+		{NewHashMACEntry(mac), SetTypeHashMAC, "A1:2B:C3:4D:E5:6F"},
+		{NewHashNetEntry(prefix), SetTypeHashNet, "10.0.0.0/24"},
+		{NewHashNetEntry(netip.PrefixFrom(addr, 32)), SetTypeHashNet, "1.1.1.1"},
+		{NewHashNetNetEntry(prefix, netip.MustParsePrefix("2001:db8::/32")), SetTypeHashNetNet, "10.0.0.0/24,2001:db8::/32"},
+		{NewHashNetPortEntry(prefix, "tcp", 443), SetTypeHashNetPort, "10.0.0.0/24,tcp:443"},
+		{NewHashNetPortEntry(netip.MustParsePrefix("2001:db8::/32"), "icmpv6", ICMPPort(128, 0)), SetTypeHashNetPort, "2001:db8::/32,icmpv6:128/0"},
+		{NewHashNetPortNetEntry(prefix, "tcp", 443, netip.PrefixFrom(otherAddr, 32)), SetTypeHashNetPortNet, "10.0.0.0/24,tcp:443,2.2.2.2"},
+		{NewHashNetIFaceEntry(prefix, false, "eth0"), SetTypeHashNetIFace, "10.0.0.0/24,eth0"},
+		{NewHashNetIFaceEntry(prefix, true, "eth0"), SetTypeHashNetIFace, "10.0.0.0/24,physdev:eth0"},
+		{NewListSetEntry("other"), SetTypeListSet, "other"},
+	}
+
+	for i, test := range tests {
+		if result := test.entry.String(); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		} else if test.entry.SetType() != test.setType {
+			t.Errorf("expectation %d failed: %v != %v (expected)", i+1, test.entry.SetType(), test.setType)
+		}
+
+		// Entries formatted by String must be parsed back to the same entry.
+		if parsed, err := ParseEntry(test.setType, test.expects); err != nil {
+			t.Errorf("expectation %d failed: cannot parse %s: %v", i+1, test.expects, err)
+		} else if parsed.String() != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, parsed.String(), test.expects)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	type test struct {
+		setType SetType
+		in      string
+		valid   bool
+	}
+
+	tests := []test{
+		{SetTypeHashIP, "1.1.1.1", true},
+		{SetTypeHashIP, " 1.1.1.1 ", true},
+		{SetTypeHashIP, "1.1.1", false},
+		{SetTypeHashIP, "1.1.1.1,80", false},
+		{SetTypeHashIPPort, "1.1.1.1,80", true},
+		{SetTypeHashIPPort, "1.1.1.1,:80", false},
+		{SetTypeHashIPPort, "1.1.1.1,tcp:65536", false},
+		{SetTypeHashIPPort, "1.1.1.1,icmp:8/0", true},
+		{SetTypeHashIPPort, "1.1.1.1,icmp:8", false},
+		{SetTypeHashIPPort, "1.1.1.1,icmp:256/0", false},
+		{SetTypeBitmapIP, "2001:db8::1", false},
+		{SetTypeBitmapIPMAC, "2001:db8::1,A1:2B:C3:4D:E5:6F", false},
+		{SetTypeBitmapPort, "udp:53", true},
+		{SetTypeBitmapPort, "icmp:8/0", false},
+		{SetTypeHashIPMark, "1.1.1.1,0xffffffff", true},
+		{SetTypeHashIPMark, "1.1.1.1,0x100000000", false},
+		{SetTypeHashNet, "10.0.0.0/33", false},
+		{SetTypeHashMAC, "A1:2B:C3:4D:E5", false},
+		{SetTypeHashNetIFace, "10.0.0.0/8,physdev:", false},
+		{SetTypeListSet, "", false},
+		{SetTypeUnsupported, "1.1.1.1", false},
+	}
+
+	for i, test := range tests {
+		entry, err := ParseEntry(test.setType, test.in)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("expectation %d failed: %v != %v (expected), error: %v", i+1, valid, test.valid, err)
+		} else if !valid && entry != nil {
+			t.Errorf("expectation %d failed: invalid entries must be nil", i+1)
+		}
+	}
+}
+
+func TestBitmapEntries(t *testing.T) {
+	addr := netip.MustParseAddr("2001:db8::1")
+	if _, err := NewBitmapIPEntry(addr); err == nil {
+		t.Error("expectation failed: bitmap:ip entries must reject IPv6 addresses")
+	}
+	if _, err := NewBitmapIPMACEntry(addr, nil); err == nil {
+		t.Error("expectation failed: bitmap:ip,mac entries must reject IPv6 addresses")
+	}
+}
@@ -0,0 +1,68 @@
+package set
+
+// Feature defines a dimension of the entries stored by a set type, as defined by the kernel.
+// Sets can be swapped only if their types have the same features.
+type Feature int
+
+const (
+	FeatureIP Feature = 1 << iota
+	FeaturePort
+	FeatureMAC
+	FeatureIP2
+	FeatureName
+	FeatureIFace
+	FeatureMark
+	FeatureNoMatch
+)
+
+// Features returns the features of a given SetType s, or 0 if s is not supported.
+func (s SetType) Features() Feature {
+	switch s {
+	// Bitmap.
+	case SetTypeBitmapIP:
+		return FeatureIP
+	case SetTypeBitmapIPMAC:
+		return FeatureIP | FeatureMAC
+	case SetTypeBitmapPort:
+		return FeaturePort
+
+		// Hash.
+	case SetTypeHashIP:
+		return FeatureIP
+	case SetTypeHashMAC:
+		return FeatureMAC
+	case SetTypeHashIPMAC:
+		return FeatureIP | FeatureMAC
+	case SetTypeHashNet:
+		return FeatureIP | FeatureNoMatch
+	case SetTypeHashNetNet:
+		return FeatureIP | FeatureIP2 | FeatureNoMatch
+	case SetTypeHashIPPort:
+		return FeatureIP | FeaturePort
+	case SetTypeHashNetPort:
+		return FeatureIP | FeaturePort | FeatureNoMatch
+	case SetTypeHashIPPortIP:
+		return FeatureIP | FeaturePort | FeatureIP2
+	case SetTypeHashIPPortNet:
+		return FeatureIP | FeaturePort | FeatureIP2 | FeatureNoMatch
+	case SetTypeHashIPMark:
+		return FeatureIP | FeatureMark
+	case SetTypeHashNetPortNet:
+		return FeatureIP | FeaturePort | FeatureIP2 | FeatureNoMatch
+	case SetTypeHashNetIFace:
+		return FeatureIP | FeatureIFace | FeatureNoMatch
+
+		// List.
+	case SetTypeListSet:
+		return FeatureName
+
+	default:
+		return 0 // Unsupported type
+	}
+}
+
+// IsSwappableWith returns true if sets of type s can be swapped with sets of type other.
+// The kernel also requires that both sets have the same protocol family.
+func (s SetType) IsSwappableWith(other SetType) bool {
+	return s.Features() != 0 && s.Features() == other.Features()
+}
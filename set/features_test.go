@@ -0,0 +1,33 @@
+package set
+
+import (
+	"testing"
+)
+
+func TestSetType_IsSwappableWith(t *testing.T) {
+	type test struct {
+		a, b    SetType
+		expects bool
+	}
+
+	tests := []test{
+		{SetTypeHashIP, SetTypeHashIP, true},
+		{SetTypeHashIP, SetTypeBitmapIP, true},
+		{SetTypeHashIPMAC, SetTypeBitmapIPMAC, true},
+		{SetTypeHashIPPortNet, SetTypeHashNetPortNet, true},
+		{SetTypeHashIP, SetTypeHashNet, false},
+		{SetTypeHashNet, SetTypeHashNetNet, false},
+		{SetTypeBitmapPort, SetTypeHashIPPort, false},
+		{SetTypeListSet, SetTypeHashIP, false},
+		{SetTypeUnsupported, SetTypeUnsupported, false},
+	}
+
+	for i, test := range tests {
+		if result := test.a.IsSwappableWith(test.b); result != test.expects {
+			t.Errorf("expectation %d failed for %v and %v: %v != %v (expected)", i+1, test.a, test.b, result, test.expects)
+		}
+		if result := test.b.IsSwappableWith(test.a); result != test.expects {
+			t.Errorf("expectation %d failed for %v and %v: %v != %v (expected)", i+1, test.b, test.a, result, test.expects)
+		}
+	}
+}
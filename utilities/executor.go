@@ -0,0 +1,76 @@
+package utilities
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+// Executor defines the interface of any component that can run ipset on behalf of go-ipset.
+// Custom executors can be used to wrap ipset (for example with sudo or in a network namespace),
+// to log invocations or to replace ipset entirely with fakes or alternative backends.
+type Executor interface {
+	// Execute runs ipset followed by a list of arguments and returns its combined output.
+	// Implementations must stop ipset and return as soon as possible when ctx is done.
+	Execute(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// InputExecutor defines an Executor that can also feed data to the standard input of ipset,
+// as required by commands like restore.
+type InputExecutor interface {
+	Executor
+
+	// ExecuteWithInput runs ipset followed by a list of arguments, reading its standard input from input,
+	// and returns its combined output.
+	ExecuteWithInput(ctx context.Context, input io.Reader, args ...string) ([]byte, error)
+}
+
+// BinaryExecutor runs ipset by spawning the executable found at Path.
+type BinaryExecutor struct {
+	Path string // Defaults to "ipset" (resolved through PATH) if empty.
+}
+
+// DefaultExecutor is the executor used by RunIPSet and by commands that are run without an explicit executor.
+var DefaultExecutor Executor = &BinaryExecutor{}
+
+// NewBinaryExecutor returns a BinaryExecutor that runs the ipset executable found at path.
+func NewBinaryExecutor(path string) *BinaryExecutor {
+	return &BinaryExecutor{Path: path}
+}
+
+// ExecutorOrDefault returns executor, or DefaultExecutor if executor is nil.
+func ExecutorOrDefault(executor Executor) Executor {
+	if executor == nil {
+		return DefaultExecutor
+	} else {
+		return executor
+	}
+}
+
+// BinaryExecutor implementation of Execute.
+// The ipset process is killed if ctx is done before it exits.
+func (e *BinaryExecutor) Execute(ctx context.Context, args ...string) ([]byte, error) {
+	return runCommand(ctx, e.path(), args...)
+}
+
+// BinaryExecutor implementation of ExecuteWithInput.
+// The ipset process is killed if ctx is done before it exits.
+func (e *BinaryExecutor) ExecuteWithInput(ctx context.Context, input io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, e.path(), args...)
+	cmd.Stdin = input
+	return cmd.CombinedOutput()
+}
+
+// path returns the path of the ipset executable run by e.
+func (e *BinaryExecutor) path() string {
+	if e.Path == "" {
+		return "ipset"
+	} else {
+		return e.Path
+	}
+}
+
+// runCommand runs a generic command followed by a list of arguments.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
@@ -3,9 +3,11 @@
 package utilities
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"io"
 	"regexp"
+	"strconv"
 	"strings"
 
 	liberrors "github.com/francescocolleoni/go-ipset/errors"
@@ -43,15 +45,81 @@ func IPSetIsAvailable() bool {
 	}
 }
 
-// RunIPSet runs ipset command followed by a list of arguments.
+// RunIPSet runs ipset command followed by a list of arguments using DefaultExecutor.
 func RunIPSet(args ...string) (IPSetOutput, error) {
-	if out, err := runCommand("ipset", args...); err != nil {
+	return RunIPSetWith(context.Background(), DefaultExecutor, args...)
+}
+
+// RunIPSetContext runs ipset command followed by a list of arguments using DefaultExecutor.
+// ipset is killed if ctx is done before it exits; in that case, the returned error
+// is either ErrIPSetTimeout or ErrIPSetCanceled.
+func RunIPSetContext(ctx context.Context, args ...string) (IPSetOutput, error) {
+	return RunIPSetWith(ctx, DefaultExecutor, args...)
+}
+
+// RunIPSetWith runs ipset command followed by a list of arguments using a given executor.
+// ipset is killed if ctx is done before it exits; in that case, the returned error
+// is either ErrIPSetTimeout or ErrIPSetCanceled.
+func RunIPSetWith(ctx context.Context, executor Executor, args ...string) (IPSetOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return newIPSetContextErrorOutput(err, args...), contextError(err)
+	}
+
+	if out, err := executor.Execute(ctx, args...); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return newIPSetContextErrorOutput(ctxErr, args...), contextError(ctxErr)
+		}
 		return newIPSetErrorOutput(out, err, args...), err
 	} else {
 		return newIPSetOutput(out, args...), nil
 	}
 }
 
+// RunIPSetWithInput runs ipset command followed by a list of arguments using a given executor,
+// feeding input to its standard input (ex.: ipset restore).
+// ipset is killed if ctx is done before it exits; in that case, the returned error
+// is either ErrIPSetTimeout or ErrIPSetCanceled.
+func RunIPSetWithInput(ctx context.Context, executor InputExecutor, input io.Reader, args ...string) (IPSetOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return newIPSetContextErrorOutput(err, args...), contextError(err)
+	}
+
+	if out, err := executor.ExecuteWithInput(ctx, input, args...); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return newIPSetContextErrorOutput(ctxErr, args...), contextError(ctxErr)
+		}
+		return newIPSetErrorOutput(out, err, args...), err
+	} else {
+		return newIPSetOutput(out, args...), nil
+	}
+}
+
+// SplitIPSetLine splits a line of ipset save output or ipset restore input into arguments.
+// Arguments are separated by white spaces, unless enclosed in double quotes (ex.: comments).
+func SplitIPSetLine(line string) ([]string, error) {
+	out := []string{}
+	for line = strings.TrimLeft(line, " \t"); line != ""; line = strings.TrimLeft(line, " \t") {
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			out = append(out, line[:end])
+			line = line[end:]
+			continue
+		}
+
+		quoted, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("unterminated quoted argument")
+		}
+		arg, _ := strconv.Unquote(quoted)
+		out = append(out, arg)
+		line = line[len(quoted):]
+	}
+	return out, nil
+}
+
 // Support functions.
 
 // newIPSetOutput returns an IPSetOutput instance representing a successful run of ipset command.
@@ -70,22 +138,93 @@ func newIPSetOutput(out []byte, args ...string) IPSetOutput {
 // newIPSetErrorOutput returns an IPSetOutput instance representing a run of ipset command that returned an error.
 func newIPSetErrorOutput(out []byte, err error, args ...string) IPSetOutput {
 	result := newIPSetOutput(out, args...)
-	result.Error = rewriteIPSetErrorFromCombinedOutput(out, err)
+	result.Error = rewriteIPSetErrorFromCombinedOutput(out, err, args...)
 	return result
 }
 
-// rewriteIPSetErrorFromCombinedOutput formats ipset output received along with an error as a human readable error.
-func rewriteIPSetErrorFromCombinedOutput(out []byte, err error) error {
+// newIPSetContextErrorOutput returns an IPSetOutput instance representing a run of ipset command
+// that was interrupted because its context is done.
+func newIPSetContextErrorOutput(err error, args ...string) IPSetOutput {
+	result := newIPSetOutput(nil, args...)
+	result.Error = contextError(err)
+	return result
+}
+
+// contextError returns the go-ipset error matching a given context error.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return liberrors.ErrIPSetTimeout
+	} else {
+		return liberrors.ErrIPSetCanceled
+	}
+}
+
+// rewriteIPSetErrorFromCombinedOutput formats ipset output received along with an error as an IPSetError,
+// whose kind is derived from the message reported by ipset.
+func rewriteIPSetErrorFromCombinedOutput(out []byte, err error, args ...string) error {
 	if out == nil {
 		return err
 	}
 
 	reason := regexp.MustCompile(`^ipset v[0-9]+[\.0-9]*[\.0-9]*:\s`).ReplaceAll(out, []byte{})
 	reason = regexp.MustCompile("Try `ipset help' for more information.").ReplaceAll(reason, []byte{})
-	return fmt.Errorf(`ipset returned error "%s"`, strings.Trim(string(reason), "\n"))
+
+	command, setName := ipsetCommandAndSet(args)
+	message := strings.Trim(string(reason), "\n")
+	return &liberrors.IPSetError{Kind: ipsetErrorKind(message), Set: setName, Command: command, Message: message, Err: err}
 }
 
-// runCommand runs a generic command followed by a list of arguments.
-func runCommand(name string, args ...string) ([]byte, error) {
-	return exec.Command(name, args...).CombinedOutput()
+// ipsetErrorKinds maps fragments of messages reported by ipset to errors; the first matching fragment is used.
+var ipsetErrorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{"Operation not permitted", liberrors.ErrIPSetPermissionDenied},
+	{"Permission denied", liberrors.ErrIPSetPermissionDenied},
+	{"Cannot open session to kernel", liberrors.ErrIPSetKernelModuleMissing},
+	{"Kernel support protocol versions", liberrors.ErrIPSetKernelModuleMissing},
+	{"set type not supported", liberrors.ErrIPSetKernelModuleMissing},
+	{"already exists", liberrors.ErrIPSetSetExists},
+	{"it's already added", liberrors.ErrIPSetElementExists},
+	{"it's not added", liberrors.ErrIPSetElementMissing},
+	{"is NOT in set", liberrors.ErrIPSetElementMissing},
+	{"is full", liberrors.ErrIPSetSetIsFull},
+	{"in use", liberrors.ErrIPSetSetInUse},
+	{"referenced", liberrors.ErrIPSetSetInUse},
+	{"type does not match", liberrors.ErrIPSetIncompatibleSets},
+	{"family does not match", liberrors.ErrIPSetIncompatibleSets},
+	{"does not exist", liberrors.ErrIPSetNoSuchSet},
+}
+
+// ipsetErrorKind returns the error matching a message reported by ipset, or nil if unknown.
+func ipsetErrorKind(message string) error {
+	for _, kind := range ipsetErrorKinds {
+		if strings.Contains(message, kind.fragment) {
+			return kind.kind
+		}
+	}
+	return nil
+}
+
+// ipsetCommandAndSet returns command and target set of a list of ipset arguments, skipping global options.
+func ipsetCommandAndSet(args []string) (string, string) {
+	positional := []string{}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-output", "-o", "-file", "-f":
+			i++ // Options followed by a value.
+		case "-exist", "-!", "-terse", "-t", "-quiet", "-q", "-resolve", "-r", "-sorted", "-s", "-name", "-n":
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+
+	switch {
+	case len(positional) == 0:
+		return "", ""
+	case len(positional) == 1 || positional[0] == "restore" || positional[0] == "-R":
+		return positional[0], ""
+	default:
+		return positional[0], positional[1]
+	}
 }
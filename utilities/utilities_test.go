@@ -1,6 +1,16 @@
 package utilities
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
 
 func TestVersion(t *testing.T) {
 	// Important: this test relies on ipset being available on the environment running tests.
@@ -44,7 +54,7 @@ func TestRunCommand(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := runCommand(test.cmd)
+		_, err := runCommand(context.Background(), test.cmd)
 		if err != nil && !test.expectsError {
 			t.Errorf("expectation failed for command %s: err != nil, %v", test.cmd, err)
 		} else if err == nil && test.expectsError {
@@ -52,3 +62,151 @@ func TestRunCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestRunIPSetWith(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{"ipset v7.1: The set with the given name does not exist\n"}, Errors: []error{errors.New("exit status 1")}}
+
+	if out, err := RunIPSetWith(context.Background(), executor, "list", "testset"); err == nil {
+		t.Error("expectation failed: executor should return an error")
+	} else if out.Error.Error() != `ipset returned error "The set with the given name does not exist"` {
+		t.Errorf(`unexpected error message: received %s`, out.Error.Error())
+	} else if out.In != "ipset list testset" {
+		t.Errorf("unexpected input: received %s", out.In)
+	}
+
+	if fmt.Sprintf("%v", executor.Calls) != "[[list testset]]" {
+		t.Errorf("unexpected executor arguments: received %v", executor.Calls)
+	}
+
+	executor = utilitiestest.NewExecutor("output")
+	if out, err := RunIPSetWith(context.Background(), executor, "list"); err != nil {
+		t.Errorf("expectation failed: executor returned an error: %v", err)
+	} else if out.Out != "output" {
+		t.Errorf("unexpected output: received %s", out.Out)
+	}
+}
+
+func TestIPSetErrorKinds(t *testing.T) {
+	type test struct {
+		args    []string
+		out     string
+		kind    error
+		command string
+		set     string
+	}
+
+	tests := []test{
+		{[]string{"list", "testset", "-output", "xml"}, "The set with the given name does not exist", liberrors.ErrIPSetNoSuchSet, "list", "testset"},
+		{[]string{"create", "testset", "hash:ip"}, "Set cannot be created: set with the same name already exists", liberrors.ErrIPSetSetExists, "create", "testset"},
+		{[]string{"rename", "a", "b"}, "Set cannot be renamed: a set with the new name already exists", liberrors.ErrIPSetSetExists, "rename", "a"},
+		{[]string{"add", "testset", "1.1.1.1"}, "Element cannot be added to the set: it's already added", liberrors.ErrIPSetElementExists, "add", "testset"},
+		{[]string{"del", "testset", "1.1.1.1"}, "Element cannot be deleted from the set: it's not added", liberrors.ErrIPSetElementMissing, "del", "testset"},
+		{[]string{"test", "testset", "1.1.1.1"}, "1.1.1.1 is NOT in set testset.", liberrors.ErrIPSetElementMissing, "test", "testset"},
+		{[]string{"-exist", "add", "testset", "1.1.1.1"}, "Hash is full, cannot add more elements", liberrors.ErrIPSetSetIsFull, "add", "testset"},
+		{[]string{"destroy", "testset"}, "Set cannot be destroyed: it is in use by a kernel component", liberrors.ErrIPSetSetInUse, "destroy", "testset"},
+		{[]string{"swap", "a", "b"}, "The sets cannot be swapped: their type does not match", liberrors.ErrIPSetIncompatibleSets, "swap", "a"},
+		{[]string{"-L", "testset"}, "Kernel error received: set type not supported", liberrors.ErrIPSetKernelModuleMissing, "-L", "testset"},
+		{[]string{"list"}, "Cannot open session to kernel.", liberrors.ErrIPSetKernelModuleMissing, "list", ""},
+		{[]string{"flush"}, "Kernel error received: Operation not permitted", liberrors.ErrIPSetPermissionDenied, "flush", ""},
+		{[]string{"restore", "-exist"}, "Error in line 2: Element cannot be added to the set: it's already added", liberrors.ErrIPSetElementExists, "restore", ""},
+		{[]string{"dummycommand"}, "No command specified: unknown argument dummycommand", nil, "dummycommand", ""},
+	}
+
+	exitErr := errors.New("exit status 1")
+	for i, test := range tests {
+		executor := &utilitiestest.Executor{Outputs: []string{"ipset v7.1: " + test.out + "\n"}, Errors: []error{exitErr}}
+		out, _ := RunIPSetWith(context.Background(), executor, test.args...)
+
+		var ipsetErr *liberrors.IPSetError
+		if !errors.As(out.Error, &ipsetErr) {
+			t.Errorf("expectation %d failed: unexpected error %v", i+1, out.Error)
+			continue
+		}
+
+		result := fmt.Sprintf("%v %s %s %s", ipsetErr.Kind, ipsetErr.Command, ipsetErr.Set, ipsetErr.Message)
+		expects := fmt.Sprintf("%v %s %s %s", test.kind, test.command, test.set, test.out)
+		if result != expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, expects)
+		} else if test.kind != nil && !errors.Is(out.Error, test.kind) {
+			t.Errorf("expectation %d failed: error should match %v", i+1, test.kind)
+		} else if !errors.Is(out.Error, liberrors.ErrIPSetDidFail) || !errors.Is(out.Error, exitErr) {
+			t.Errorf("expectation %d failed: error should match ErrIPSetDidFail and the executor error", i+1)
+		} else if errors.Is(out.Error, liberrors.ErrIPSetTimeout) {
+			t.Errorf("expectation %d failed: error should not match other kinds", i+1)
+		}
+	}
+}
+
+func TestSplitIPSetLine(t *testing.T) {
+	type test struct {
+		line    string
+		expects string
+	}
+
+	tests := []test{
+		{"add test 1.1.1.1", "[add test 1.1.1.1]"},
+		{"  add\ttest   1.1.1.1  ", "[add test 1.1.1.1]"},
+		{`add test 1.1.1.1 comment "a \"quoted\" comment"`, `[add test 1.1.1.1 comment a "quoted" comment]`},
+	}
+
+	for i, test := range tests {
+		if args, err := SplitIPSetLine(test.line); err != nil {
+			t.Errorf("expectation %d failed: %v", i+1, err)
+		} else if result := fmt.Sprintf("%v", args); result != test.expects {
+			t.Errorf("expectation %d failed: %s != %s (expected)", i+1, result, test.expects)
+		}
+	}
+
+	if _, err := SplitIPSetLine(`add test 1.1.1.1 comment "unterminated`); err == nil {
+		t.Error("expectation failed: unterminated quotes should be rejected")
+	}
+}
+
+func TestBinaryExecutor(t *testing.T) {
+	if out, err := NewBinaryExecutor("echo").Execute(context.Background(), "test"); err != nil {
+		t.Errorf("expectation failed: executor returned an error: %v", err)
+	} else if string(out) != "test\n" {
+		t.Errorf("unexpected output: received %s", string(out))
+	}
+
+	if _, err := NewBinaryExecutor("dummycommand").Execute(context.Background()); err == nil {
+		t.Error("expectation failed: executor should return an error")
+	}
+}
+
+func TestRunIPSetWithInput(t *testing.T) {
+	input := "add testset 1.1.1.1\nadd testset 2.2.2.2\n"
+	if out, err := RunIPSetWithInput(context.Background(), NewBinaryExecutor("cat"), strings.NewReader(input)); err != nil {
+		t.Errorf("expectation failed: executor returned an error: %v", err)
+	} else if out.Out != input {
+		t.Errorf("unexpected output: received %s", out.Out)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunIPSetWithInput(ctx, NewBinaryExecutor("cat"), strings.NewReader(input)); err != liberrors.ErrIPSetCanceled {
+		t.Errorf("unexpected error: %v != %v (expected)", err, liberrors.ErrIPSetCanceled)
+	}
+}
+
+func TestRunIPSetWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if out, err := RunIPSetWith(ctx, NewBinaryExecutor("sleep"), "5"); err != liberrors.ErrIPSetTimeout {
+		t.Errorf("unexpected error: %v != %v (expected)", err, liberrors.ErrIPSetTimeout)
+	} else if out.Error != liberrors.ErrIPSetTimeout {
+		t.Errorf("unexpected output error: %v != %v (expected)", out.Error, liberrors.ErrIPSetTimeout)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("command was not killed on timeout, returned after %v", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunIPSetWith(ctx, NewBinaryExecutor("sleep"), "5"); err != liberrors.ErrIPSetCanceled {
+		t.Errorf("unexpected error: %v != %v (expected)", err, liberrors.ErrIPSetCanceled)
+	}
+}
@@ -0,0 +1,39 @@
+package utilitiestest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Member describes a member of a set listed by SetOutput.
+type Member struct {
+	Entry   string
+	Timeout int // Listed only if not 0.
+}
+
+// Members returns members without timeout for each of entries.
+func Members(entries ...string) []Member {
+	out := make([]Member, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, Member{Entry: entry})
+	}
+	return out
+}
+
+// ListOutput returns the XML output of ipset list for a list of sets returned by SetOutput.
+func ListOutput(sets ...string) string {
+	return "<ipsets>" + strings.Join(sets, "") + "</ipsets>"
+}
+
+// SetOutput returns the XML representation of a set with a given name, type, number of references and list of members.
+func SetOutput(name, setType string, references int, members ...Member) string {
+	out := fmt.Sprintf(`<ipset name="%s"><type>%s</type><header><references>%d</references></header><members>`, name, setType, references)
+	for _, member := range members {
+		if member.Timeout != 0 {
+			out += fmt.Sprintf("<member><elem>%s</elem><timeout>%d</timeout></member>", member.Entry, member.Timeout)
+		} else {
+			out += fmt.Sprintf("<member><elem>%s</elem></member>", member.Entry)
+		}
+	}
+	return out + "</members></ipset>"
+}
@@ -0,0 +1,63 @@
+// Package utilitiestest provides fake executors for tests of packages that run ipset.
+package utilitiestest
+
+import (
+	"context"
+	"io"
+)
+
+// Executor implements utilities.Executor without running ipset: it records the arguments of each run,
+// and returns Outputs and Errors in order. Once Outputs are exhausted the last output is returned again,
+// while runs past the end of Errors succeed.
+type Executor struct {
+	Calls [][]string // Arguments of each run.
+
+	Outputs []string
+	Errors  []error
+}
+
+// NewExecutor returns an executor that returns outputs in order.
+func NewExecutor(outputs ...string) *Executor {
+	return &Executor{Outputs: outputs}
+}
+
+// Executor implementation of Execute.
+func (e *Executor) Execute(ctx context.Context, args ...string) ([]byte, error) {
+	i := len(e.Calls)
+	e.Calls = append(e.Calls, args)
+
+	var out []byte
+	var err error
+	if i < len(e.Outputs) {
+		out = []byte(e.Outputs[i])
+	} else if len(e.Outputs) > 0 {
+		out = []byte(e.Outputs[len(e.Outputs)-1]) // The last output is repeated.
+	}
+	if i < len(e.Errors) {
+		err = e.Errors[i]
+	}
+	return out, err
+}
+
+// InputExecutor is an Executor that also implements utilities.InputExecutor, recording the input of each run.
+type InputExecutor struct {
+	Executor
+
+	Inputs []string // Input of each run of ExecuteWithInput.
+}
+
+// NewInputExecutor returns an input executor that returns outputs in order.
+func NewInputExecutor(outputs ...string) *InputExecutor {
+	return &InputExecutor{Executor: Executor{Outputs: outputs}}
+}
+
+// InputExecutor implementation of ExecuteWithInput.
+func (e *InputExecutor) ExecuteWithInput(ctx context.Context, input io.Reader, args ...string) ([]byte, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Inputs = append(e.Inputs, string(data))
+	return e.Execute(ctx, args...)
+}
@@ -0,0 +1,240 @@
+// Package watch reports changes of sets made by other processes, comparing periodic snapshots of ipset list.
+package watch
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/francescocolleoni/go-ipset/commands"
+	"github.com/francescocolleoni/go-ipset/set"
+	"github.com/francescocolleoni/go-ipset/utilities"
+)
+
+// DefaultInterval is the default interval between snapshots.
+const DefaultInterval = 10 * time.Second
+
+// EventType defines the change described by an Event.
+type EventType int
+
+const (
+	EventTypeSetCreated    = iota // A set was created; its entries are reported with EventTypeEntryAdded.
+	EventTypeSetDestroyed         // A set was destroyed; its entries are not reported.
+	EventTypeEntryAdded           // An entry was added.
+	EventTypeEntryRemoved         // An entry was deleted (or the set was flushed).
+	EventTypeEntryExpired         // An entry was removed after its timeout elapsed.
+	EventTypeHeaderChanged        // Options or references of a set changed.
+)
+
+// String returns the name of a given EventType t.
+func (t EventType) String() string {
+	switch t {
+	case EventTypeSetCreated:
+		return "SetCreated"
+	case EventTypeSetDestroyed:
+		return "SetDestroyed"
+	case EventTypeEntryAdded:
+		return "EntryAdded"
+	case EventTypeEntryRemoved:
+		return "EntryRemoved"
+	case EventTypeEntryExpired:
+		return "EntryExpired"
+	case EventTypeHeaderChanged:
+		return "HeaderChanged"
+
+	default:
+		return "" // Unsupported event type
+	}
+}
+
+// Event describes a change of a set between two snapshots.
+type Event struct {
+	Type    EventType
+	Time    time.Time // Time of the snapshot that observed the change.
+	Set     string
+	SetType set.SetType
+	Header  commands.SetHeader // Current header, or last observed header of destroyed sets.
+	Member  commands.SetMember // Only for entry events; last observed member of removed entries.
+	Changes []string           // Only for EventTypeHeaderChanged, names of the options that changed.
+}
+
+// Watcher takes snapshots of sets at a given interval, and reports their changes as events.
+//
+// Snapshots index members by entry, so that each comparison runs in linear time with the number of members;
+// hashsize, memsize and the number of entries are not compared, since they change together with entries.
+type Watcher struct {
+	Executor utilities.Executor // Defaults to utilities.DefaultExecutor if nil.
+	Interval time.Duration      // Defaults to DefaultInterval if not positive.
+	Filter   *regexp.Regexp     // Only sets whose name matches Filter are watched; all sets if nil.
+
+	previous map[string]*snapshot
+	order    []string // Names of sets of previous, as listed.
+	now      func() time.Time
+}
+
+// NewWatcher returns a watcher of all sets, that runs commands using a given executor.
+func NewWatcher(executor utilities.Executor) *Watcher {
+	return &Watcher{Executor: executor, Interval: DefaultInterval}
+}
+
+// Run takes a snapshot every w.Interval and sends events to events, until ctx is done or sets cannot be listed.
+// Sets listed by the first snapshot are not reported as created. Run returns the error of ctx, or the error of ipset list.
+func (w *Watcher) Run(ctx context.Context, events chan<- Event) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		changes, err := w.Poll(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range changes {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll takes a snapshot and returns the changes since the previous one; the first snapshot returns no events.
+func (w *Watcher) Poll(ctx context.Context) ([]Event, error) {
+	sets, err := w.listSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := time.Now()
+	if w.now != nil {
+		t = w.now()
+	}
+
+	current := make(map[string]*snapshot, len(sets))
+	order := make([]string, 0, len(sets))
+	for _, info := range sets {
+		current[info.Name] = newSnapshot(info, t)
+		order = append(order, info.Name)
+	}
+
+	out := []Event{}
+	if w.previous != nil {
+		for _, name := range w.order {
+			if s, found := current[name]; !found || s.info.Type != w.previous[name].info.Type {
+				out = append(out, w.previous[name].event(EventTypeSetDestroyed, t))
+			}
+		}
+
+		for _, name := range order {
+			s := current[name]
+			if p, found := w.previous[name]; found && p.info.Type == s.info.Type {
+				out = s.appendChanges(out, p)
+			} else {
+				out = s.appendChanges(append(out, s.event(EventTypeSetCreated, t)), emptySnapshot)
+			}
+		}
+	}
+
+	w.previous, w.order = current, order
+	return out, nil
+}
+
+// Support.
+
+// snapshot is the state of a set at a given time, with members indexed by entry.
+type snapshot struct {
+	info    commands.SetInfo
+	time    time.Time
+	members map[string]int // Indexes of info.Members.
+}
+
+// emptySnapshot is compared with snapshots of created sets.
+var emptySnapshot = &snapshot{members: map[string]int{}}
+
+// newSnapshot returns the snapshot of a set listed at a given time.
+func newSnapshot(info commands.SetInfo, t time.Time) *snapshot {
+	out := &snapshot{info: info, time: t, members: make(map[string]int, len(info.Members))}
+	for i, member := range info.Members {
+		out.members[member.Entry] = i
+	}
+	return out
+}
+
+// event returns an event of a given type for the set of s, observed at time t.
+func (s *snapshot) event(eventType EventType, t time.Time) Event {
+	return Event{Type: eventType, Time: t, Set: s.info.Name, SetType: s.info.Type, Header: s.info.Header}
+}
+
+// appendChanges appends to events the changes of s since snapshot p of the same set.
+func (s *snapshot) appendChanges(events []Event, p *snapshot) []Event {
+	if p != emptySnapshot {
+		if changes := headerChanges(p.info.Header, s.info.Header); len(changes) > 0 {
+			event := s.event(EventTypeHeaderChanged, s.time)
+			event.Changes = changes
+			events = append(events, event)
+		}
+	}
+
+	for _, member := range p.info.Members {
+		if _, found := s.members[member.Entry]; !found {
+			event := s.event(EventTypeEntryRemoved, s.time)
+			if member.Timeout > 0 && time.Duration(member.Timeout)*time.Second <= s.time.Sub(p.time)+time.Second {
+				event.Type = EventTypeEntryExpired // Remaining seconds are rounded down by ipset.
+			}
+			event.Member = member
+			events = append(events, event)
+		}
+	}
+
+	for _, member := range s.info.Members {
+		if _, found := p.members[member.Entry]; !found {
+			event := s.event(EventTypeEntryAdded, s.time)
+			event.Member = member
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// headerChanges returns the names of the options of header current that differ from header previous.
+func headerChanges(previous, current commands.SetHeader) []string {
+	out := []string{}
+	appendIf := func(name string, changed bool) {
+		if changed {
+			out = append(out, name)
+		}
+	}
+
+	appendIf("family", previous.ProtocolFamily != current.ProtocolFamily)
+	appendIf("range", previous.Range != current.Range)
+	appendIf("maxelem", previous.MaxElements != current.MaxElements)
+	appendIf("size", previous.Size != current.Size)
+	appendIf("netmask", previous.NetMask != current.NetMask)
+	appendIf("markmask", previous.MarkMask != current.MarkMask)
+	appendIf("timeout", previous.Timeout != current.Timeout)
+	appendIf("counters", previous.UseCounters != current.UseCounters)
+	appendIf("comment", previous.AllowsComments != current.AllowsComments)
+	appendIf("skbinfo", previous.UseSKBInfo != current.UseSKBInfo)
+	appendIf("forceadd", previous.ForceAdd != current.ForceAdd)
+	appendIf("references", previous.References != current.References)
+	return out
+}
+
+// listSets returns the sets matching the filter of w, with their members.
+// All sets are listed at once without a filter; otherwise headers are listed first, then each matching set.
+func (w *Watcher) listSets(ctx context.Context) ([]commands.SetInfo, error) {
+	list := commands.NewListSet("")
+	list.Filter = w.Filter
+	return list.SetsWith(ctx, utilities.ExecutorOrDefault(w.Executor))
+}
@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	liberrors "github.com/francescocolleoni/go-ipset/errors"
+	"github.com/francescocolleoni/go-ipset/utilities/utilitiestest"
+)
+
+func TestPoll(t *testing.T) {
+	first := utilitiestest.ListOutput(
+		utilitiestest.SetOutput("a", "hash:ip", 0, utilitiestest.Member{Entry: "1.1.1.1", Timeout: 5}, utilitiestest.Member{Entry: "2.2.2.2"}, utilitiestest.Member{Entry: "5.5.5.5"}),
+		utilitiestest.SetOutput("b", "hash:net", 0),
+		utilitiestest.SetOutput("d", "hash:ip", 0),
+	)
+	second := utilitiestest.ListOutput(
+		utilitiestest.SetOutput("a", "hash:ip", 1, utilitiestest.Members("2.2.2.2", "3.3.3.3")...),
+		utilitiestest.SetOutput("c", "hash:ip", 0, utilitiestest.Members("4.4.4.4")...),
+		utilitiestest.SetOutput("d", "hash:net", 0),
+	)
+
+	start := time.Unix(1000, 0)
+	executor := &utilitiestest.Executor{Outputs: []string{first, second, second}}
+	watcher := NewWatcher(executor)
+	watcher.now = func() time.Time { return start.Add(time.Duration(len(executor.Calls)-1) * 10 * time.Second) }
+
+	expects := [][]string{
+		{},
+		{
+			"SetDestroyed b", "SetDestroyed d", "HeaderChanged a [references]", "EntryExpired a 1.1.1.1", "EntryRemoved a 5.5.5.5",
+			"EntryAdded a 3.3.3.3", "SetCreated c", "EntryAdded c 4.4.4.4", "SetCreated d",
+		},
+		{},
+	}
+
+	for i, expect := range expects {
+		events, err := watcher.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("expectation %d failed: %v", i+1, err)
+		}
+
+		result := make([]string, len(events))
+		for j, event := range events {
+			result[j] = describe(event)
+		}
+
+		if fmt.Sprintf("%q", result) != fmt.Sprintf("%q", expect) {
+			t.Errorf("expectation %d failed: %q != %q (expected)", i+1, result, expect)
+		}
+	}
+}
+
+func TestPollFilter(t *testing.T) {
+	terse := utilitiestest.ListOutput(utilitiestest.SetOutput("blocklist", "hash:ip", 0), utilitiestest.SetOutput("other", "hash:ip", 0))
+	executor := &utilitiestest.Executor{
+		Outputs: []string{terse, utilitiestest.ListOutput(utilitiestest.SetOutput("blocklist", "hash:ip", 0, utilitiestest.Members("1.1.1.1")...)), terse, "ipset v7.1: The set with the given name does not exist\n"},
+		Errors:  []error{nil, nil, nil, errors.New("exit status 1")},
+	}
+	watcher := NewWatcher(executor)
+	watcher.Filter = regexp.MustCompile("^block")
+
+	watcher.Poll(context.Background())
+	events, err := watcher.Poll(context.Background())
+
+	calls := make([]string, len(executor.Calls))
+	for i, call := range executor.Calls {
+		calls[i] = strings.Join(call, " ")
+	}
+
+	expectedCalls := []string{"list -terse -output xml", "list blocklist -output xml", "list -terse -output xml", "list blocklist -output xml"}
+	if err != nil {
+		t.Errorf("expectation failed: %v", err)
+	} else if fmt.Sprintf("%q", calls) != fmt.Sprintf("%q", expectedCalls) {
+		t.Errorf("expectation failed: %q != %q (expected)", calls, expectedCalls)
+	} else if len(events) != 1 || describe(events[0]) != "SetDestroyed blocklist" {
+		t.Errorf("expectation failed: unexpected events %v", events)
+	}
+}
+
+func TestRun(t *testing.T) {
+	executor := &utilitiestest.Executor{Outputs: []string{utilitiestest.ListOutput(), utilitiestest.ListOutput(utilitiestest.SetOutput("a", "hash:ip", 0))}}
+	watcher := NewWatcher(executor)
+	watcher.Interval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	done := make(chan error)
+	go func() { done <- watcher.Run(ctx, events) }()
+
+	if event := <-events; describe(event) != "SetCreated a" {
+		t.Errorf("expectation failed: %s != SetCreated a (expected)", describe(event))
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expectation failed: %v != %v (expected)", err, context.Canceled)
+	}
+
+	executor = &utilitiestest.Executor{Outputs: []string{"ipset v7.1: Kernel error received: Operation not permitted\n"}, Errors: []error{errors.New("exit status 1")}}
+	if err := NewWatcher(executor).Run(context.Background(), events); !errors.Is(err, liberrors.ErrIPSetPermissionDenied) {
+		t.Errorf("expectation failed: %v != %v (expected)", err, liberrors.ErrIPSetPermissionDenied)
+	}
+}
+
+// describe returns the type, set and entry of an event, and the changes of EventTypeHeaderChanged events.
+func describe(event Event) string {
+	switch event.Type {
+	case EventTypeEntryAdded, EventTypeEntryRemoved, EventTypeEntryExpired:
+		return fmt.Sprintf("%v %s %s", event.Type, event.Set, event.Member.Entry)
+	case EventTypeHeaderChanged:
+		return fmt.Sprintf("%v %s %v", event.Type, event.Set, event.Changes)
+	default:
+		return fmt.Sprintf("%v %s", event.Type, event.Set)
+	}
+}